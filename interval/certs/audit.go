@@ -0,0 +1,272 @@
+package certs
+
+import (
+	"bytes"
+	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// categories of audit findings, each one is reported separately
+const (
+	FindingHostnameMismatch = "hostname-mismatch"
+	FindingUntrustedChain   = "untrusted-chain"
+	FindingWeakSignature    = "weak-signature"
+	FindingWeakKey          = "weak-key"
+	FindingProtocolVersion  = "protocol-version"
+	FindingCipherSuite      = "cipher-suite"
+	FindingSelfSigned       = "self-signed"
+)
+
+const (
+	defaultMinVersion    = "TLS1.2"
+	defaultMinRSAKeySize = 2048
+	defaultMinECKeySize  = 256
+)
+
+var tlsVersions = map[string]uint16{
+	"TLS1.0": tls.VersionTLS10,
+	"TLS1.1": tls.VersionTLS11,
+	"TLS1.2": tls.VersionTLS12,
+	"TLS1.3": tls.VersionTLS13,
+}
+
+var weakSignatureAlgorithms = map[x509.SignatureAlgorithm]bool{
+	x509.MD2WithRSA:    true,
+	x509.MD5WithRSA:    true,
+	x509.SHA1WithRSA:   true,
+	x509.DSAWithSHA1:   true,
+	x509.ECDSAWithSHA1: true,
+}
+
+// single problem found by the audit
+type Finding struct {
+	Category string
+	Message  string
+}
+
+func (f Finding) String() string {
+	return "[" + f.Category + "] " + f.Message
+}
+
+// format all findings into single message
+func FormatFindings(findings []Finding) string {
+	messages := make([]string, len(findings))
+	for i, f := range findings {
+		messages[i] = f.String()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// audit options as they are set in check metadata
+type AuditOptions struct {
+	MinVersion        string   // minimal allowed protocol version (TLS1.0 - TLS1.3), default TLS1.2
+	DisallowedCiphers []string // names of cipher suites which must not be accepted, default all insecure suites
+	AllowSelfSigned   bool     // dont report self-signed certificate
+	MinRSAKeySize     int      // default 2048
+	MinECKeySize      int      // default 256
+}
+
+type Auditor struct {
+	minVersion        uint16
+	disallowedCiphers map[uint16]bool
+	allowSelfSigned   bool
+	minRSAKeySize     int
+	minECKeySize      int
+}
+
+// function doing new tls handshake with the audited server, used for active probing
+type HandshakeFunc func(tlsConfig *tls.Config) (*tls.ConnectionState, error)
+
+func NewAuditor(opts AuditOptions) (*Auditor, error) {
+	if opts.MinVersion == "" {
+		opts.MinVersion = defaultMinVersion
+	}
+	minVersion, ok := tlsVersions[opts.MinVersion]
+	if !ok {
+		return nil, errors.Wrapf(invalidConfigError, "tls version %s is not supported", opts.MinVersion)
+	}
+	if opts.MinRSAKeySize == 0 {
+		opts.MinRSAKeySize = defaultMinRSAKeySize
+	}
+	if opts.MinECKeySize == 0 {
+		opts.MinECKeySize = defaultMinECKeySize
+	}
+
+	disallowed := map[uint16]bool{}
+	if len(opts.DisallowedCiphers) == 0 {
+		for _, suite := range tls.InsecureCipherSuites() {
+			disallowed[suite.ID] = true
+		}
+	}
+	for _, name := range opts.DisallowedCiphers {
+		id, ok := cipherSuiteID(name)
+		if !ok {
+			return nil, errors.Wrapf(invalidConfigError, "cipher suite %s is not known", name)
+		}
+		disallowed[id] = true
+	}
+
+	a := &Auditor{
+		minVersion:        minVersion,
+		disallowedCiphers: disallowed,
+		allowSelfSigned:   opts.AllowSelfSigned,
+		minRSAKeySize:     opts.MinRSAKeySize,
+		minECKeySize:      opts.MinECKeySize,
+	}
+	return a, nil
+}
+
+// audit tls connection, roots set to nil means system roots are used
+// handshake is used for probing of old protocol versions and disallowed ciphers, it can be nil to skip probing
+func (a *Auditor) Audit(state *tls.ConnectionState, hostname string, roots *x509.CertPool, handshake HandshakeFunc) []Finding {
+	var findings []Finding
+	if len(state.PeerCertificates) == 0 {
+		return []Finding{{Category: FindingUntrustedChain, Message: "server did not present any certificate"}}
+	}
+	leaf := state.PeerCertificates[0]
+
+	// hostname
+	if hostname != "" {
+		if err := leaf.VerifyHostname(hostname); err != nil {
+			findings = append(findings, Finding{Category: FindingHostnameMismatch, Message: err.Error()})
+		}
+	}
+
+	// chain
+	selfSigned := isSelfSigned(leaf)
+	if selfSigned && !a.allowSelfSigned {
+		findings = append(findings, Finding{Category: FindingSelfSigned, Message: fmt.Sprintf("certificate %s is self-signed", certName(leaf))})
+	}
+	if !selfSigned || !a.allowSelfSigned {
+		intermediates := x509.NewCertPool()
+		for _, cert := range state.PeerCertificates[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err := leaf.Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+		if err != nil {
+			findings = append(findings, Finding{Category: FindingUntrustedChain, Message: err.Error()})
+		}
+	}
+
+	// signatures and keys of all presented certificates
+	for _, cert := range state.PeerCertificates {
+		// signature of self-signed root is not used for verification
+		if weakSignatureAlgorithms[cert.SignatureAlgorithm] && !(isSelfSigned(cert) && cert != leaf) {
+			findings = append(findings, Finding{Category: FindingWeakSignature, Message: fmt.Sprintf("certificate %s is signed with %s", certName(cert), cert.SignatureAlgorithm)})
+		}
+		if message := a.checkKey(cert); message != "" {
+			findings = append(findings, Finding{Category: FindingWeakKey, Message: message})
+		}
+	}
+
+	// negotiated parameters
+	if state.Version < a.minVersion {
+		findings = append(findings, Finding{Category: FindingProtocolVersion, Message: fmt.Sprintf("negotiated %s is older than %s", tls.VersionName(state.Version), tls.VersionName(a.minVersion))})
+	}
+	if a.disallowedCiphers[state.CipherSuite] {
+		findings = append(findings, Finding{Category: FindingCipherSuite, Message: fmt.Sprintf("negotiated disallowed cipher suite %s", tls.CipherSuiteName(state.CipherSuite))})
+	}
+
+	if handshake != nil {
+		findings = append(findings, a.probe(hostname, handshake)...)
+	}
+
+	return findings
+}
+
+// actively test if the server accepts old protocol versions or disallowed ciphers
+func (a *Auditor) probe(hostname string, handshake HandshakeFunc) []Finding {
+	var findings []Finding
+
+	if a.minVersion > tls.VersionTLS10 {
+		conf := &tls.Config{
+			ServerName:         hostname,
+			InsecureSkipVerify: true,
+			MinVersion:         tls.VersionTLS10,
+			MaxVersion:         a.minVersion - 1,
+		}
+		if state, err := handshake(conf); err == nil {
+			findings = append(findings, Finding{Category: FindingProtocolVersion, Message: fmt.Sprintf("server accepts %s which is older than %s", tls.VersionName(state.Version), tls.VersionName(a.minVersion))})
+		}
+	}
+
+	// cipher suites are configurable only up to TLS1.2
+	var suites []uint16
+	for id := range a.disallowedCiphers {
+		if isTLS12Suite(id) {
+			suites = append(suites, id)
+		}
+	}
+	if len(suites) > 0 {
+		conf := &tls.Config{
+			ServerName:         hostname,
+			InsecureSkipVerify: true,
+			MinVersion:         tls.VersionTLS10,
+			MaxVersion:         tls.VersionTLS12,
+			CipherSuites:       suites,
+		}
+		if state, err := handshake(conf); err == nil {
+			findings = append(findings, Finding{Category: FindingCipherSuite, Message: fmt.Sprintf("server accepts disallowed cipher suite %s", tls.CipherSuiteName(state.CipherSuite))})
+		}
+	}
+
+	return findings
+}
+
+func (a *Auditor) checkKey(cert *x509.Certificate) string {
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if key.N.BitLen() < a.minRSAKeySize {
+			return fmt.Sprintf("certificate %s uses %d bit RSA key, minimum is %d", certName(cert), key.N.BitLen(), a.minRSAKeySize)
+		}
+	case *ecdsa.PublicKey:
+		if key.Curve.Params().BitSize < a.minECKeySize {
+			return fmt.Sprintf("certificate %s uses %d bit ECDSA key, minimum is %d", certName(cert), key.Curve.Params().BitSize, a.minECKeySize)
+		}
+	case *dsa.PublicKey:
+		return fmt.Sprintf("certificate %s uses deprecated DSA key", certName(cert))
+	}
+	return ""
+}
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawSubject, cert.RawIssuer) && cert.CheckSignatureFrom(cert) == nil
+}
+
+func isTLS12Suite(id uint16) bool {
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		if suite.ID != id {
+			continue
+		}
+		for _, version := range suite.SupportedVersions {
+			if version == tls.VersionTLS12 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func cipherSuiteID(name string) (uint16, bool) {
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		if suite.Name == name {
+			return suite.ID, true
+		}
+	}
+	return 0, false
+}
+
+// human readable name of the certificate used in messages
+func certName(cert *x509.Certificate) string {
+	if cert.Subject.CommonName != "" {
+		return cert.Subject.CommonName
+	}
+	return "serial " + cert.SerialNumber.Text(16)
+}
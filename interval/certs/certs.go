@@ -0,0 +1,135 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/exmonitor/watcher/interval/secret"
+)
+
+// how long is loaded tls material kept in memory before its loaded again,
+// so rotated certificates are picked up without restart
+const materialCacheTTL = time.Minute * 10
+
+// references to tls material used by a check, see secret package for reference format
+type Config struct {
+	ClientCert string // PEM encoded client certificate (chain)
+	ClientKey  string // PEM encoded private key of the client certificate
+	CABundle   string // PEM encoded CA certificates used instead of system roots
+}
+
+// returns true if there is any tls material configured
+func (c Config) Enabled() bool {
+	return c.ClientCert != "" || c.ClientKey != "" || c.CABundle != ""
+}
+
+// loaded tls material ready to be used in tls.Config
+type Material struct {
+	ClientCertificate *tls.Certificate
+	ClientLeaf        *x509.Certificate
+	RootCAs           *x509.CertPool
+}
+
+// apply material to tls config
+func (m *Material) Apply(tlsConfig *tls.Config) {
+	if m.ClientCertificate != nil {
+		tlsConfig.Certificates = []tls.Certificate{*m.ClientCertificate}
+	}
+	if m.RootCAs != nil {
+		tlsConfig.RootCAs = m.RootCAs
+	}
+}
+
+type cacheEntry struct {
+	conf     Config
+	material *Material
+	loaded   time.Time
+}
+
+// material is cached per check id as checks are re-created on every interval
+var materialCache = struct {
+	sync.Mutex
+	entries map[int]cacheEntry
+}{entries: map[int]cacheEntry{}}
+
+// load tls material for the check, material is loaded from cache if possible
+func Load(checkId int, conf Config) (*Material, error) {
+	materialCache.Lock()
+	defer materialCache.Unlock()
+
+	entry, ok := materialCache.entries[checkId]
+	if ok && entry.conf == conf && time.Since(entry.loaded) < materialCacheTTL {
+		return entry.material, nil
+	}
+
+	material, err := load(conf)
+	if err != nil {
+		// dont keep old material for changed config
+		delete(materialCache.entries, checkId)
+		return nil, err
+	}
+	materialCache.entries[checkId] = cacheEntry{conf: conf, material: material, loaded: time.Now()}
+
+	return material, nil
+}
+
+func load(conf Config) (*Material, error) {
+	if (conf.ClientCert == "") != (conf.ClientKey == "") {
+		return nil, errors.Wrap(invalidConfigError, "client certificate and client key must be set together")
+	}
+	material := &Material{}
+
+	if conf.ClientCert != "" {
+		certPEM, err := secret.Resolve(conf.ClientCert)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load client certificate")
+		}
+		keyPEM, err := secret.Resolve(conf.ClientKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load client key")
+		}
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse client certificate")
+		}
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse client certificate")
+		}
+		cert.Leaf = leaf
+		material.ClientCertificate = &cert
+		material.ClientLeaf = leaf
+	}
+
+	if conf.CABundle != "" {
+		caPEM, err := secret.Resolve(conf.CABundle)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load CA bundle")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, errors.Wrap(invalidConfigError, "CA bundle does not contain any PEM encoded certificate")
+		}
+		material.RootCAs = pool
+	}
+
+	return material, nil
+}
+
+// run tls handshake over already opened connection and close it, used for probing of servers
+func Handshake(conn net.Conn, tlsConfig *tls.Config, timeout time.Duration) (*tls.ConnectionState, error) {
+	tlsConn := tls.Client(conn, tlsConfig)
+	defer tlsConn.Close()
+
+	tlsConn.SetDeadline(time.Now().Add(timeout))
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, err
+	}
+	state := tlsConn.ConnectionState()
+	return &state, nil
+}
@@ -0,0 +1,5 @@
+package certs
+
+import "errors"
+
+var invalidConfigError error = errors.New("invalid tls config")
@@ -0,0 +1,23 @@
+package certs
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// check TTL of all peer certificates
+// returns false and message if any certificate expires within the threshold
+func CheckExpiration(conn *tls.ConnectionState, threshold time.Duration) (bool, string) {
+	var messages []string
+	// check certs
+	for _, cert := range conn.PeerCertificates {
+		// check if now() + threshold > CertExpirationDate
+		if time.Now().Add(threshold).After(cert.NotAfter) {
+			messages = append(messages, fmt.Sprintf("certificate %s will expire in less than %.0f hours", certName(cert), threshold.Hours()))
+		}
+	}
+
+	return len(messages) == 0, strings.Join(messages, "; ")
+}
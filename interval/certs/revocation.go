@@ -0,0 +1,199 @@
+package certs
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ocsp"
+)
+
+const (
+	// used when OCSP response or CRL doesnt say when next update is available
+	defaultRevocationCacheTTL = time.Hour
+	// max size of downloaded CRL
+	maxCRLSize = 32 * 1024 * 1024
+)
+
+// revocation info is cached until nextUpdate, responders and CRL servers are shared across many checks
+var revocationCache = struct {
+	sync.Mutex
+	ocsp map[string]*ocsp.Response
+	crl  map[string]*x509.RevocationList
+}{ocsp: map[string]*ocsp.Response{}, crl: map[string]*x509.RevocationList{}}
+
+// check that the leaf certificate is not revoked
+// stapled OCSP response is used if present, otherwise OCSP responder is queried with CRL as fallback,
+// responses which are not yet valid or past their nextUpdate are rejected
+func CheckRevocation(conn *tls.ConnectionState, requireStaple bool, timeout time.Duration) (bool, string) {
+	if len(conn.PeerCertificates) == 0 {
+		return false, "server did not present any certificate"
+	}
+	leaf := conn.PeerCertificates[0]
+	if len(conn.PeerCertificates) < 2 {
+		return false, fmt.Sprintf("cannot check revocation of certificate %s, issuer certificate is not presented", certName(leaf))
+	}
+	issuer := conn.PeerCertificates[1]
+
+	// stapled OCSP response
+	if len(conn.OCSPResponse) > 0 {
+		resp, err := ocsp.ParseResponseForCert(conn.OCSPResponse, leaf, issuer)
+		if err == nil {
+			err = checkFreshness(resp.ThisUpdate, resp.NextUpdate)
+		}
+		if err != nil {
+			return false, fmt.Sprintf("invalid stapled OCSP response for certificate %s, %s", certName(leaf), err)
+		}
+		return ocspResult(leaf, resp)
+	}
+	if requireStaple {
+		return false, fmt.Sprintf("OCSP staple for certificate %s is missing", certName(leaf))
+	}
+	if len(leaf.OCSPServer) == 0 && len(leaf.CRLDistributionPoints) == 0 {
+		return false, fmt.Sprintf("certificate %s has no OCSP responder or CRL distribution point", certName(leaf))
+	}
+
+	client := &http.Client{Timeout: timeout}
+	var failures []string
+	// OCSP responder, CRL is used as fallback when the responder fails
+	if len(leaf.OCSPServer) > 0 {
+		resp, err := queryOCSP(client, leaf, issuer)
+		if err == nil {
+			return ocspResult(leaf, resp)
+		}
+		failures = append(failures, err.Error())
+	}
+	// CRL distribution point
+	if len(leaf.CRLDistributionPoints) > 0 {
+		crl, err := fetchCRL(client, leaf.CRLDistributionPoints[0], issuer)
+		if err == nil {
+			return crlResult(leaf, crl)
+		}
+		failures = append(failures, err.Error())
+	}
+
+	return false, fmt.Sprintf("failed to check revocation of certificate %s, %s", certName(leaf), strings.Join(failures, "; "))
+}
+
+func ocspResult(leaf *x509.Certificate, resp *ocsp.Response) (bool, string) {
+	switch resp.Status {
+	case ocsp.Good:
+		return true, ""
+	case ocsp.Revoked:
+		return false, fmt.Sprintf("certificate %s was revoked on %s (OCSP reason %d)", certName(leaf), resp.RevokedAt.Format(time.RFC3339), resp.RevocationReason)
+	default:
+		return false, fmt.Sprintf("OCSP status of certificate %s is unknown", certName(leaf))
+	}
+}
+
+func crlResult(leaf *x509.Certificate, crl *x509.RevocationList) (bool, string) {
+	for _, entry := range crl.RevokedCertificateEntries {
+		if entry.SerialNumber.Cmp(leaf.SerialNumber) == 0 {
+			return false, fmt.Sprintf("certificate %s was revoked on %s (CRL reason %d)", certName(leaf), entry.RevocationTime.Format(time.RFC3339), entry.ReasonCode)
+		}
+	}
+	return true, ""
+}
+
+func queryOCSP(client *http.Client, leaf *x509.Certificate, issuer *x509.Certificate) (*ocsp.Response, error) {
+	cacheKey := string(issuer.RawSubjectPublicKeyInfo) + leaf.SerialNumber.String()
+	revocationCache.Lock()
+	cached, ok := revocationCache.ocsp[cacheKey]
+	revocationCache.Unlock()
+	if ok && checkFreshness(cached.ThisUpdate, cached.NextUpdate) == nil && time.Now().Before(cacheExpiration(cached.ThisUpdate, cached.NextUpdate)) {
+		return cached, nil
+	}
+
+	req, err := ocsp.CreateRequest(leaf, issuer, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create OCSP request")
+	}
+	httpResp, err := client.Post(leaf.OCSPServer[0], "application/ocsp-request", bytes.NewReader(req))
+	if err != nil {
+		return nil, errors.Wrap(err, "OCSP responder")
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OCSP responder %s returned %s", leaf.OCSPServer[0], httpResp.Status)
+	}
+	data, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "OCSP responder")
+	}
+	resp, err := ocsp.ParseResponseForCert(data, leaf, issuer)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid OCSP response")
+	}
+	if err := checkFreshness(resp.ThisUpdate, resp.NextUpdate); err != nil {
+		return nil, errors.Wrap(err, "invalid OCSP response")
+	}
+
+	revocationCache.Lock()
+	revocationCache.ocsp[cacheKey] = resp
+	revocationCache.Unlock()
+	return resp, nil
+}
+
+func fetchCRL(client *http.Client, url string, issuer *x509.Certificate) (*x509.RevocationList, error) {
+	revocationCache.Lock()
+	cached, ok := revocationCache.crl[url]
+	revocationCache.Unlock()
+	if ok && checkFreshness(cached.ThisUpdate, cached.NextUpdate) == nil && time.Now().Before(cacheExpiration(cached.ThisUpdate, cached.NextUpdate)) {
+		return cached, nil
+	}
+
+	httpResp, err := client.Get(url)
+	if err != nil {
+		return nil, errors.Wrap(err, "CRL distribution point")
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("CRL distribution point %s returned %s", url, httpResp.Status)
+	}
+	data, err := ioutil.ReadAll(http.MaxBytesReader(nil, httpResp.Body, maxCRLSize))
+	if err != nil {
+		return nil, errors.Wrap(err, "CRL distribution point")
+	}
+	crl, err := x509.ParseRevocationList(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid CRL")
+	}
+	if err := crl.CheckSignatureFrom(issuer); err != nil {
+		return nil, errors.Wrap(err, "invalid CRL signature")
+	}
+	if err := checkFreshness(crl.ThisUpdate, crl.NextUpdate); err != nil {
+		return nil, errors.Wrap(err, "invalid CRL")
+	}
+
+	revocationCache.Lock()
+	revocationCache.crl[url] = crl
+	revocationCache.Unlock()
+	return crl, nil
+}
+
+// revocation info must not be issued in the future or be past its nextUpdate
+func checkFreshness(thisUpdate time.Time, nextUpdate time.Time) error {
+	now := time.Now()
+	if thisUpdate.After(now) {
+		return fmt.Errorf("thisUpdate %s is in the future", thisUpdate.Format(time.RFC3339))
+	}
+	if !nextUpdate.IsZero() && nextUpdate.Before(now) {
+		return fmt.Errorf("nextUpdate %s is in the past", nextUpdate.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// revocation info is valid until nextUpdate, if its not set use default ttl
+func cacheExpiration(thisUpdate time.Time, nextUpdate time.Time) time.Time {
+	if nextUpdate.IsZero() {
+		return thisUpdate.Add(defaultRevocationCacheTTL)
+	}
+	return nextUpdate
+}
@@ -0,0 +1,84 @@
+package checkstate
+
+import (
+	"sync"
+	"time"
+)
+
+/*
+State of checks kept between their runs:
+	Checks are parsed again on every interval tick, so anything which must survive from one run to the next
+	lives in package level stores until watcher restart. Entries which were not updated for their expiration
+	are pruned, so deleted checks do not keep their state forever.
+*/
+
+// how often expired entries are removed
+const pruneInterval = time.Minute
+
+// state is kept at least this long, so checks which were not run for a while (ie: target unreachable) do not lose it
+const minExpiration = time.Hour
+
+// returns expiration of the state of check with the interval in seconds,
+// state is kept for three intervals after the last run so a delayed tick does not lose it
+func Expiration(interval int) time.Duration {
+	expiration := 3 * time.Duration(interval) * time.Second
+	if expiration < minExpiration {
+		return minExpiration
+	}
+	return expiration
+}
+
+type Store struct {
+	sync.Mutex
+	entries   map[string]*entry
+	nextPrune time.Time
+}
+
+type entry struct {
+	value   interface{}
+	expires time.Time
+}
+
+func NewStore() *Store {
+	return &Store{entries: make(map[string]*entry)}
+}
+
+// call f with the current value of the key, nil if it is unknown or expired,
+// returned value is stored and expires after ttl
+func (s *Store) Update(key string, ttl time.Duration, f func(value interface{}) interface{}) {
+	s.Lock()
+	defer s.Unlock()
+	now := time.Now()
+	s.prune(now)
+	var value interface{}
+	if e, ok := s.entries[key]; ok && now.Before(e.expires) {
+		value = e.value
+	}
+	s.entries[key] = &entry{value: f(value), expires: now.Add(ttl)}
+}
+
+// call f with the current value of the key without changing its expiration,
+// returns false and f is not called if the key is unknown or expired
+func (s *Store) View(key string, f func(value interface{})) bool {
+	s.Lock()
+	defer s.Unlock()
+	e, ok := s.entries[key]
+	if !ok || !time.Now().Before(e.expires) {
+		return false
+	}
+	f(e.value)
+	return true
+}
+
+// remove expired entries, at most once per prune interval
+func (s *Store) prune(now time.Time) {
+	if now.Before(s.nextPrune) {
+		return
+	}
+	for key, e := range s.entries {
+		if !now.Before(e.expires) {
+			delete(s.entries, key)
+		}
+	}
+	s.nextPrune = now.Add(pruneInterval)
+}
@@ -0,0 +1,257 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/exmonitor/exclient/database"
+	"github.com/exmonitor/exlogger"
+	"github.com/pkg/errors"
+
+	"github.com/exmonitor/watcher/interval/secret"
+	"github.com/exmonitor/watcher/interval/spec"
+	"github.com/exmonitor/watcher/interval/status"
+	"github.com/exmonitor/watcher/key"
+)
+
+const (
+	msgFailedToRun       = "failed to run command"
+	msgFailedTimeout     = "failed - command timed out"
+	msgNoOutput          = "no output"
+	msgInternalFailedEnv = "INTERNAL: failed to load environment variable"
+
+	// max size of plugin output, rest is discarded
+	maxOutputSize = 64 * 1024
+	// time to wait for closing output pipes still held by orphaned processes after the kill
+	waitDelay = time.Second
+)
+
+type CheckConfig struct {
+	Id               int
+	FailThreshold    int
+	Interval         int
+	Command          string
+	Arguments        []string
+	Environment      map[string]string // extra environment variables, values can be secret references
+	Timeout          time.Duration
+	WarningIsFailure bool // report plugin WARNING state as failed check
+
+	//db client
+	DBClient database.ClientInterface
+	Logger   *exlogger.Logger
+}
+
+type Check struct {
+	id               int
+	failThreshold    int
+	interval         int
+	requestId        string
+	command          string
+	arguments        []string
+	environment      map[string]string
+	timeout          time.Duration
+	warningIsFailure bool
+
+	// db client
+	dbClient database.ClientInterface
+	// logger
+	log *exlogger.Logger
+
+	// internals
+	spec.CheckInterface
+}
+
+func NewCheck(conf CheckConfig) (*Check, error) {
+	if conf.Id == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.id must not be zero")
+	}
+	if conf.FailThreshold == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.FailThreshold must not be zero")
+	}
+	if conf.Interval == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.Interval must not be zero")
+	}
+	if conf.Command == "" {
+		return nil, errors.Wrap(invalidConfigError, "conf.Command must not be empty")
+	}
+	if conf.Timeout == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.Timeout must not be zero")
+	}
+	if conf.DBClient == nil {
+		return nil, errors.Wrap(invalidConfigError, "conf.DBClient must not be nil")
+	}
+	if conf.Logger == nil {
+		return nil, errors.Wrap(invalidConfigError, "conf.Logger must not be nil")
+	}
+	for name := range conf.Environment {
+		if name == "" || strings.ContainsAny(name, "=\x00") {
+			return nil, errors.Wrapf(invalidConfigError, "conf.Environment has invalid variable name %q", name)
+		}
+	}
+
+	newCheck := &Check{
+		id:               conf.Id,
+		failThreshold:    conf.FailThreshold,
+		interval:         conf.Interval,
+		command:          conf.Command,
+		arguments:        conf.Arguments,
+		environment:      conf.Environment,
+		timeout:          conf.Timeout,
+		warningIsFailure: conf.WarningIsFailure,
+
+		dbClient: conf.DBClient,
+		log:      conf.Logger,
+	}
+
+	return newCheck, nil
+}
+
+// wrapper function used to run in separate thread (goroutine)
+func (c *Check) RunCheck() {
+	// generate unique request ID
+	c.requestId = key.GenerateReqId(c.id)
+	// run monitoring check
+	s := c.doCheck()
+	c.LogResult(s)
+
+	// save result to database
+	s.SaveToDB()
+}
+
+// run monitoring check
+func (c *Check) doCheck() *status.Status {
+	statusConfig := status.Config{
+		Id:            c.id,
+		ReqId:         c.requestId,
+		Interval:      c.interval,
+		FailThreshold: c.failThreshold,
+		DBClient:      c.dbClient,
+	}
+	s, err := status.New(statusConfig)
+	if err != nil {
+		c.LogRunError(err, fmt.Sprintf("failed to init new status for COMMAND service ID %d", c.id))
+	}
+	tStart := time.Now()
+	defer func() {
+		s.Duration = time.Since(tStart)
+	}()
+
+	env, err := c.env()
+	if err != nil {
+		c.LogRunError(err, msgInternalFailedEnv)
+		s.Set(false, err, msgInternalFailedEnv)
+		return s
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, c.command, c.arguments...)
+	cmd.Env = env
+	// run the plugin in its own process group, so the timeout kills also its children
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = waitDelay
+	stdout := &limitedBuffer{limit: maxOutputSize}
+	stderr := &limitedBuffer{limit: maxOutputSize}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err = cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		s.Set(false, nil, fmt.Sprintf("%s after %s", msgFailedTimeout, c.timeout))
+		return s
+	}
+	exitErr, isExitErr := err.(*exec.ExitError)
+	if err != nil && !isExitErr {
+		s.Set(false, err, msgFailedToRun)
+		return s
+	}
+	state := StateOK
+	if isExitErr {
+		state = exitErr.ExitCode()
+	}
+
+	// nagios reads only stdout, stderr is used only to explain failures without any output
+	output := stdout.String()
+	if strings.TrimSpace(output) == "" {
+		output = stderr.String()
+	}
+	text, rawPerfData := parseOutput(output)
+	if text == "" {
+		text = msgNoOutput
+	}
+	perfData, err := parsePerfData(rawPerfData)
+	if err != nil {
+		c.log.LogDebug("check-COMMAND|id %d|reqID %s|skipped performance data: %s", c.id, c.requestId, err)
+	}
+
+	msg := fmt.Sprintf("%s: %s", StateName(state), text)
+	if len(perfData) > 0 {
+		msg = fmt.Sprintf("%s, measurements: %s", msg, formatPerfData(perfData))
+	}
+	s.Set(c.stateResult(state), nil, msg)
+	return s
+}
+
+// returns check result for plugin state
+func (c *Check) stateResult(state int) bool {
+	switch state {
+	case StateOK:
+		return true
+	case StateWarning:
+		return !c.warningIsFailure
+	default:
+		return false
+	}
+}
+
+// environment of the plugin, watcher environment with extra variables from metadata
+func (c *Check) env() ([]string, error) {
+	env := os.Environ()
+	for name, value := range c.environment {
+		resolved, err := secret.Value(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "variable %s", name)
+		}
+		env = append(env, name+"="+resolved)
+	}
+	return env, nil
+}
+
+// buffer which keeps only first limit bytes and silently discards the rest
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); room > 0 {
+		if len(p) > room {
+			b.Buffer.Write(p[:room])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+func (c *Check) GetStringPort() string {
+	// command has no port
+	return ""
+}
+
+func (c *Check) LogResult(s *status.Status) {
+	c.log.Log("check-COMMAND|id %d|reqID %s|command %s|latency %sms|result '%t'|msg: %s", c.id, c.requestId, c.command, key.MsFromDuration(s.Duration), s.Result, s.Message)
+}
+
+func (c *Check) LogRunError(err error, message string) {
+	c.log.LogError(err, "running check id:%d reqID:%s type:command command:%s failed, reason: %s", c.id, c.requestId, c.command, message)
+}
@@ -0,0 +1,7 @@
+package command
+
+import "errors"
+
+var invalidConfigError error = errors.New("invalid check config")
+
+var invalidPerfDataError error = errors.New("invalid performance data")
@@ -0,0 +1,58 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/exmonitor/exclient/database"
+	"github.com/exmonitor/exclient/database/spec/service"
+	"github.com/exmonitor/exlogger"
+	"github.com/pkg/errors"
+)
+
+/*
+Example metadata:
+{
+	"id": 17,
+	"command": "/usr/lib/nagios/plugins/check_http",
+	"arguments": ["-H", "www.domain.cz", "-w", "2", "-c", "5"],
+	"environment": {"LANG": "C", "DB_PASSWORD": "secret:db-password"},
+	"timeout": 10,
+	"warningIsFailure": false
+}
+*/
+
+type RawCheck struct {
+	Id               int               `json:"id"`
+	Command          string            `json:"command"`
+	Arguments        []string          `json:"arguments"`
+	Environment      map[string]string `json:"environment"`
+	Timeout          int               `json:"timeout"`
+	WarningIsFailure bool              `json:"warningIsFailure"`
+}
+
+func ParseCheck(service *service.Service, dbClient database.ClientInterface, logger *exlogger.Logger) (*Check, error) {
+	var rawCheck RawCheck
+	err := json.Unmarshal([]byte(service.Metadata), &rawCheck)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to parse COMMAND json metadata for check id %d", service.ID))
+	} else {
+		logger.LogDebug("Successfully parsed COMMAND json metadata for check id %d", service.ID)
+	}
+
+	checkConfig := CheckConfig{
+		Id:               service.ID,
+		FailThreshold:    service.FailThreshold,
+		Interval:         service.Interval,
+		Command:          rawCheck.Command,
+		Arguments:        rawCheck.Arguments,
+		Environment:      rawCheck.Environment,
+		Timeout:          time.Second * time.Duration(rawCheck.Timeout),
+		WarningIsFailure: rawCheck.WarningIsFailure,
+		Logger:           logger,
+		DBClient:         dbClient,
+	}
+
+	return NewCheck(checkConfig)
+}
@@ -0,0 +1,186 @@
+package command
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+/*
+Nagios plugin output:
+	TEXT OUTPUT | OPTIONAL PERFDATA
+	LONG TEXT LINE 1
+	LONG TEXT LINE 2 | PERFDATA LINE 2
+	PERFDATA LINE 3
+
+Performance data item:
+	'label'=value[UOM];[warn];[crit];[min];[max]
+*/
+
+// plugin states by exit code
+const (
+	StateOK       = 0
+	StateWarning  = 1
+	StateCritical = 2
+	StateUnknown  = 3
+)
+
+var stateNames = map[int]string{
+	StateOK:       "OK",
+	StateWarning:  "WARNING",
+	StateCritical: "CRITICAL",
+	StateUnknown:  "UNKNOWN",
+}
+
+// returns name of the plugin state, unknown exit codes are reported as UNKNOWN
+func StateName(state int) string {
+	if name, ok := stateNames[state]; ok {
+		return name
+	}
+	return stateNames[StateUnknown]
+}
+
+// single numeric measurement reported by the plugin
+// thresholds are kept in the nagios range format, ie: "10", "10:20" or "@5:"
+type PerfData struct {
+	Label    string
+	Value    float64
+	Unit     string
+	Warning  string
+	Critical string
+	Min      string
+	Max      string
+}
+
+func (p PerfData) String() string {
+	return fmt.Sprintf("%s=%s%s", p.Label, strconv.FormatFloat(p.Value, 'f', -1, 64), p.Unit)
+}
+
+// split plugin output into the first line text and performance data from all lines
+func parseOutput(output string) (string, string) {
+	lines := strings.Split(strings.ReplaceAll(output, "\r\n", "\n"), "\n")
+	text := lines[0]
+	var perfData []string
+	if i := strings.Index(text, "|"); i >= 0 {
+		perfData = append(perfData, text[i+1:])
+		text = text[:i]
+	}
+	// in the long output everything after the first pipe is performance data
+	for i, line := range lines[1:] {
+		if j := strings.Index(line, "|"); j >= 0 {
+			perfData = append(perfData, line[j+1:])
+			perfData = append(perfData, lines[i+2:]...)
+			break
+		}
+	}
+	return strings.TrimSpace(text), strings.Join(perfData, " ")
+}
+
+// parse performance data, invalid items are skipped and the first error is returned along with the valid ones
+func parsePerfData(raw string) ([]PerfData, error) {
+	var result []PerfData
+	var firstErr error
+	for _, item := range splitPerfData(raw) {
+		p, ok, err := parsePerfItem(item)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if ok {
+			result = append(result, p)
+		}
+	}
+	return result, firstErr
+}
+
+// split performance data by whitespace, labels can be quoted and contain spaces
+func splitPerfData(raw string) []string {
+	var items []string
+	var item strings.Builder
+	quoted := false
+	for i := 0; i < len(raw); i++ {
+		ch := raw[i]
+		switch {
+		case ch == '\'':
+			item.WriteByte(ch)
+			if quoted && i+1 < len(raw) && raw[i+1] == '\'' {
+				// escaped quote inside quoted label
+				item.WriteByte(raw[i+1])
+				i++
+			} else {
+				quoted = !quoted
+			}
+		case (ch == ' ' || ch == '\t' || ch == '\n') && !quoted:
+			if item.Len() > 0 {
+				items = append(items, item.String())
+				item.Reset()
+			}
+		default:
+			item.WriteByte(ch)
+		}
+	}
+	if item.Len() > 0 {
+		items = append(items, item.String())
+	}
+	return items
+}
+
+// parse single performance data item, ok is false for undetermined values
+func parsePerfItem(item string) (PerfData, bool, error) {
+	i := strings.LastIndex(item, "=")
+	if i <= 0 {
+		return PerfData{}, false, errors.Wrapf(invalidPerfDataError, "item %q has no label", item)
+	}
+	label := item[:i]
+	if len(label) >= 2 && strings.HasPrefix(label, "'") && strings.HasSuffix(label, "'") {
+		label = strings.ReplaceAll(label[1:len(label)-1], "''", "'")
+	}
+
+	fields := strings.Split(item[i+1:], ";")
+	if fields[0] == "U" {
+		return PerfData{}, false, nil
+	}
+	// value is followed by optional unit of measurement
+	end := strings.IndexFunc(fields[0], func(r rune) bool {
+		return !strings.ContainsRune("0123456789.,-+eE", r)
+	})
+	if end < 0 {
+		end = len(fields[0])
+	}
+	value, err := strconv.ParseFloat(strings.ReplaceAll(fields[0][:end], ",", "."), 64)
+	if err != nil {
+		return PerfData{}, false, errors.Wrapf(invalidPerfDataError, "item %q has invalid value", item)
+	}
+
+	p := PerfData{
+		Label: label,
+		Value: value,
+		Unit:  fields[0][end:],
+	}
+	for n, field := range fields[1:] {
+		switch n {
+		case 0:
+			p.Warning = field
+		case 1:
+			p.Critical = field
+		case 2:
+			p.Min = field
+		case 3:
+			p.Max = field
+		}
+	}
+	return p, true, nil
+}
+
+// human readable list of measurements
+func formatPerfData(perfData []PerfData) string {
+	items := make([]string, len(perfData))
+	for i, p := range perfData {
+		items[i] = p.String()
+	}
+	return strings.Join(items, ", ")
+}
@@ -0,0 +1,77 @@
+package command
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseOutput(t *testing.T) {
+	tests := []struct {
+		name         string
+		output       string
+		wantText     string
+		wantPerfData string
+	}{
+		{name: "text only", output: "OK - all good\n", wantText: "OK - all good"},
+		{name: "text with perfdata", output: "OK - load 0.1 | load1=0.1;5;10", wantText: "OK - load 0.1", wantPerfData: " load1=0.1;5;10"},
+		{name: "crlf line endings", output: "DISK OK|used=10\r\n", wantText: "DISK OK", wantPerfData: "used=10"},
+		{
+			name:         "long output with perfdata",
+			output:       "OK | a=1\nline 1\nline 2 | b=2\nc=3\n",
+			wantText:     "OK",
+			wantPerfData: " a=1  b=2 c=3 ",
+		},
+		{name: "long output without perfdata", output: "WARNING\ndetail 1\ndetail 2", wantText: "WARNING"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, perfData := parseOutput(tt.output)
+			if text != tt.wantText || perfData != tt.wantPerfData {
+				t.Errorf("parseOutput(%q) = %q, %q, want %q, %q", tt.output, text, perfData, tt.wantText, tt.wantPerfData)
+			}
+		})
+	}
+}
+
+func TestParsePerfData(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    []PerfData
+		wantErr bool
+	}{
+		{name: "empty", raw: "", want: nil},
+		{
+			name: "value with unit and thresholds",
+			raw:  "time=0.25s;1;2;0;10",
+			want: []PerfData{{Label: "time", Value: 0.25, Unit: "s", Warning: "1", Critical: "2", Min: "0", Max: "10"}},
+		},
+		{
+			name: "multiple items",
+			raw:  "load1=0.5;5;10 load5=0.3",
+			want: []PerfData{{Label: "load1", Value: 0.5, Warning: "5", Critical: "10"}, {Label: "load5", Value: 0.3}},
+		},
+		{
+			name: "quoted label with spaces and escaped quote",
+			raw:  "'free space /'=10GB 'it''s'=1",
+			want: []PerfData{{Label: "free space /", Value: 10, Unit: "GB"}, {Label: "it's", Value: 1}},
+		},
+		{name: "range thresholds", raw: "x=5;10:20;@5:", want: []PerfData{{Label: "x", Value: 5, Warning: "10:20", Critical: "@5:"}}},
+		{name: "decimal comma", raw: "x=1,5%", want: []PerfData{{Label: "x", Value: 1.5, Unit: "%"}}},
+		{name: "negative value", raw: "temp=-3.5C", want: []PerfData{{Label: "temp", Value: -3.5, Unit: "C"}}},
+		{name: "undetermined value is skipped", raw: "x=U y=1", want: []PerfData{{Label: "y", Value: 1}}},
+		{name: "missing label", raw: "=1 y=2", want: []PerfData{{Label: "y", Value: 2}}, wantErr: true},
+		{name: "invalid value", raw: "x=abc y=2", want: []PerfData{{Label: "y", Value: 2}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePerfData(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePerfData(%q) error = %v, wantErr %t", tt.raw, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePerfData(%q) = %+v, want %+v", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
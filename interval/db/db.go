@@ -0,0 +1,286 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/exmonitor/exclient/database"
+	"github.com/exmonitor/exlogger"
+	"github.com/pkg/errors"
+
+	"github.com/exmonitor/watcher/interval/secret"
+	"github.com/exmonitor/watcher/interval/spec"
+	"github.com/exmonitor/watcher/interval/status"
+	"github.com/exmonitor/watcher/key"
+)
+
+const (
+	EngineMysql    = "mysql"
+	EnginePostgres = "postgres"
+	EngineRedis    = "redis"
+
+	msgSuccess            = "success"
+	msgFailedToConnect    = "failed to connect to database"
+	msgFailedQuery        = "failed - probe query"
+	msgFailedValue        = "failed - unexpected query result"
+	msgFailedLatency      = "failed - query latency too high"
+	msgFailedReplication  = "failed - replication status"
+	msgFailedReplicaLag   = "failed - replication lag too high"
+	msgInternalFailedPass = "INTERNAL: failed to load database password"
+
+	defaultSQLQuery   = "SELECT 1"
+	defaultRedisQuery = "PING"
+)
+
+// connection to the database with logged in user
+type session interface {
+	// returns first column of first row of the result, for redis the whole reply
+	query(ctx context.Context, query string) (string, error)
+	// returns how much is the replica behind its master
+	replicationLag(ctx context.Context) (time.Duration, error)
+	close() error
+}
+
+type CheckConfig struct {
+	Id            int
+	FailThreshold int
+	Interval      int
+	Engine        string
+	Target        string
+	Port          int
+	Timeout       time.Duration
+
+	// login
+	Username      string
+	Password      string // password or secret reference
+	Database      string // database name, for redis index of the db
+	Tls           bool
+	TlsSkipVerify bool
+
+	// assertions
+	Query                   string
+	ExpectedValue           string // value is not checked if empty
+	MaxQueryLatency         time.Duration
+	ReplicationCheckEnabled bool
+	MaxReplicationLag       time.Duration
+
+	//db client
+	DBClient database.ClientInterface
+	Logger   *exlogger.Logger
+}
+
+type Check struct {
+	id            int
+	failThreshold int
+	interval      int
+	requestId     string
+	engine        string
+	target        string
+	port          int
+	timeout       time.Duration
+
+	username      string
+	password      string
+	database      string
+	tls           bool
+	tlsSkipVerify bool
+
+	query                   string
+	expectedValue           string
+	maxQueryLatency         time.Duration
+	replicationCheckEnabled bool
+	maxReplicationLag       time.Duration
+
+	// db client
+	dbClient database.ClientInterface
+	// logger
+	log *exlogger.Logger
+
+	// internals
+	spec.CheckInterface
+}
+
+func NewCheck(conf CheckConfig) (*Check, error) {
+	if conf.Id == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.id must not be zero")
+	}
+	if conf.FailThreshold == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.FailThreshold must not be zero")
+	}
+	if conf.Interval == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.Interval must not be zero")
+	}
+	if conf.Target == "" {
+		return nil, errors.Wrap(invalidConfigError, "conf.Target must not be empty")
+	}
+	if conf.Port == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.Port must not be zero")
+	}
+	if conf.Timeout == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.Timeout must not be zero")
+	}
+	switch conf.Engine {
+	case EngineMysql, EnginePostgres:
+		if conf.Username == "" {
+			return nil, errors.Wrap(invalidConfigError, "conf.Username must not be empty")
+		}
+		if conf.Query == "" {
+			conf.Query = defaultSQLQuery
+		}
+	case EngineRedis:
+		if conf.Database != "" {
+			if _, err := strconv.Atoi(conf.Database); err != nil {
+				return nil, errors.Wrapf(invalidConfigError, "conf.Database %q must be a number for redis", conf.Database)
+			}
+		}
+		if conf.Query == "" {
+			conf.Query = defaultRedisQuery
+		}
+	default:
+		return nil, errors.Wrapf(invalidConfigError, "conf.Engine %q is not supported", conf.Engine)
+	}
+	if conf.ReplicationCheckEnabled && conf.MaxReplicationLag == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.MaxReplicationLag must not be zero when replication check is enabled")
+	}
+	if conf.DBClient == nil {
+		return nil, errors.Wrap(invalidConfigError, "conf.DBClient must not be nil")
+	}
+	if conf.Logger == nil {
+		return nil, errors.Wrap(invalidConfigError, "conf.Logger must not be nil")
+	}
+
+	newCheck := &Check{
+		id:            conf.Id,
+		failThreshold: conf.FailThreshold,
+		interval:      conf.Interval,
+		engine:        conf.Engine,
+		target:        conf.Target,
+		port:          conf.Port,
+		timeout:       conf.Timeout,
+
+		username:      conf.Username,
+		password:      conf.Password,
+		database:      conf.Database,
+		tls:           conf.Tls,
+		tlsSkipVerify: conf.TlsSkipVerify,
+
+		query:                   conf.Query,
+		expectedValue:           conf.ExpectedValue,
+		maxQueryLatency:         conf.MaxQueryLatency,
+		replicationCheckEnabled: conf.ReplicationCheckEnabled,
+		maxReplicationLag:       conf.MaxReplicationLag,
+
+		dbClient: conf.DBClient,
+		log:      conf.Logger,
+	}
+
+	return newCheck, nil
+}
+
+// wrapper function used to run in separate thread (goroutine)
+func (c *Check) RunCheck() {
+	// generate unique request ID
+	c.requestId = key.GenerateReqId(c.id)
+	// run monitoring check
+	s := c.doCheck()
+	c.LogResult(s)
+
+	// save result to database
+	s.SaveToDB()
+}
+
+func (c *Check) doCheck() *status.Status {
+	statusConfig := status.Config{
+		Id:            c.id,
+		ReqId:         c.requestId,
+		Interval:      c.interval,
+		FailThreshold: c.failThreshold,
+		DBClient:      c.dbClient,
+	}
+	s, err := status.New(statusConfig)
+	if err != nil {
+		c.LogRunError(err, fmt.Sprintf("failed to init new status for DB service ID %d", c.id))
+	}
+	tStart := time.Now()
+	defer func() {
+		s.Duration = time.Since(tStart)
+	}()
+
+	password, err := secret.Value(c.password)
+	if err != nil {
+		c.LogRunError(err, msgInternalFailedPass)
+		s.Set(false, err, msgInternalFailedPass)
+		return s
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	conn, err := c.open(ctx, password)
+	if err != nil {
+		s.Set(false, err, msgFailedToConnect)
+		return s
+	}
+	defer conn.close()
+
+	// probe query
+	tQuery := time.Now()
+	value, err := conn.query(ctx, c.query)
+	queryLatency := time.Since(tQuery)
+	if err != nil {
+		s.Set(false, err, msgFailedQuery)
+		return s
+	}
+	if c.expectedValue != "" && value != c.expectedValue {
+		s.Set(false, nil, fmt.Sprintf("%s, got %q expected %q", msgFailedValue, value, c.expectedValue))
+		return s
+	}
+	if c.maxQueryLatency > 0 && queryLatency > c.maxQueryLatency {
+		s.Set(false, nil, fmt.Sprintf("%s, %sms exceeds %sms", msgFailedLatency, key.MsFromDuration(queryLatency), key.MsFromDuration(c.maxQueryLatency)))
+		return s
+	}
+	msg := fmt.Sprintf("%s, query latency %sms", msgSuccess, key.MsFromDuration(queryLatency))
+
+	// replication
+	if c.replicationCheckEnabled {
+		lag, err := conn.replicationLag(ctx)
+		if err != nil {
+			s.Set(false, err, msgFailedReplication)
+			return s
+		}
+		if lag > c.maxReplicationLag {
+			s.Set(false, nil, fmt.Sprintf("%s, %.0fs exceeds %.0fs", msgFailedReplicaLag, lag.Seconds(), c.maxReplicationLag.Seconds()))
+			return s
+		}
+		msg = fmt.Sprintf("%s, replication lag %.0fs", msg, lag.Seconds())
+	}
+
+	s.Set(true, nil, msg)
+	return s
+}
+
+// open connection and log in
+func (c *Check) open(ctx context.Context, password string) (session, error) {
+	switch c.engine {
+	case EngineMysql:
+		return c.openMysql(ctx, password)
+	case EnginePostgres:
+		return c.openPostgres(ctx, password)
+	default:
+		return c.openRedis(password)
+	}
+}
+
+func (c *Check) GetStringPort() string {
+	return fmt.Sprintf(":%d", c.port)
+}
+
+func (c *Check) LogResult(s *status.Status) {
+	c.log.Log("check-DB|id %d|reqID %s|engine %s|target %s:%d|latency %sms|result '%t'|msg: %s", c.id, c.requestId, c.engine, c.target, c.port, key.MsFromDuration(s.Duration), s.Result, s.Message)
+}
+
+func (c *Check) LogRunError(err error, message string) {
+	c.log.LogError(err, "running check id:%d reqID:%s type:%s target:%s failed, reason: %s", c.id, c.requestId, c.engine, c.target, message)
+}
@@ -0,0 +1,7 @@
+package db
+
+import "errors"
+
+var invalidConfigError error = errors.New("invalid check config")
+
+var notReplicaError error = errors.New("server is not a replica")
@@ -0,0 +1,100 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/exmonitor/exclient/database"
+	"github.com/exmonitor/exclient/database/spec/service"
+	"github.com/exmonitor/exlogger"
+	"github.com/pkg/errors"
+
+	"github.com/exmonitor/watcher/key"
+)
+
+/*
+Example metadata:
+{
+	"id": 7,
+	"target": "db1.domain.cz",
+	"port": 3306,
+	"timeout": 5,
+	"username": "monitoring",
+	"password": "secret:mysql-monitoring",
+	"database": "app",
+	"tls": true,
+	"tlsSkipVerify": false,
+	"query": "SELECT COUNT(*) > 0 FROM users",
+	"expectedValue": "1",
+	"maxQueryLatency": 500,
+	"replicationCheckEnabled": true,
+	"maxReplicationLag": 30
+}
+
+type of engine is given by service type (mysql, postgres or redis),
+for redis the query is a command (default PING) and database is index of the db
+*/
+
+type RawCheck struct {
+	Id                      int    `json:"id"`
+	Target                  string `json:"target"`
+	Port                    int    `json:"port"`
+	Timeout                 int    `json:"timeout"`
+	Username                string `json:"username"`
+	Password                string `json:"password"`
+	Database                string `json:"database"`
+	Tls                     bool   `json:"tls"`
+	TlsSkipVerify           bool   `json:"tlsSkipVerify"`
+	Query                   string `json:"query"`
+	ExpectedValue           string `json:"expectedValue"`
+	MaxQueryLatency         int    `json:"maxQueryLatency"` // in milliseconds
+	ReplicationCheckEnabled bool   `json:"replicationCheckEnabled"`
+	MaxReplicationLag       int    `json:"maxReplicationLag"` // in seconds
+}
+
+func ParseCheck(service *service.Service, dbClient database.ClientInterface, logger *exlogger.Logger) (*Check, error) {
+	var engine string
+	switch service.Type {
+	case key.ServiceTypeMysql:
+		engine = EngineMysql
+	case key.ServiceTypePostgres:
+		engine = EnginePostgres
+	case key.ServiceTypeRedis:
+		engine = EngineRedis
+	default:
+		return nil, errors.Wrapf(invalidConfigError, "service type %d is not a database", service.Type)
+	}
+
+	var rawCheck RawCheck
+	err := json.Unmarshal([]byte(service.Metadata), &rawCheck)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to parse DB json metadata for check id %d", service.ID))
+	} else {
+		logger.LogDebug("Successfully parsed DB json metadata for check id %d", service.ID)
+	}
+
+	checkConfig := CheckConfig{
+		Id:                      service.ID,
+		FailThreshold:           service.FailThreshold,
+		Interval:                service.Interval,
+		Engine:                  engine,
+		Target:                  rawCheck.Target,
+		Port:                    rawCheck.Port,
+		Timeout:                 time.Second * time.Duration(rawCheck.Timeout),
+		Username:                rawCheck.Username,
+		Password:                rawCheck.Password,
+		Database:                rawCheck.Database,
+		Tls:                     rawCheck.Tls,
+		TlsSkipVerify:           rawCheck.TlsSkipVerify,
+		Query:                   rawCheck.Query,
+		ExpectedValue:           rawCheck.ExpectedValue,
+		MaxQueryLatency:         time.Millisecond * time.Duration(rawCheck.MaxQueryLatency),
+		ReplicationCheckEnabled: rawCheck.ReplicationCheckEnabled,
+		MaxReplicationLag:       time.Second * time.Duration(rawCheck.MaxReplicationLag),
+		Logger:                  logger,
+		DBClient:                dbClient,
+	}
+
+	return NewCheck(checkConfig)
+}
@@ -0,0 +1,227 @@
+package db
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// limits of replies, INFO output is far smaller, they protect the watcher against hostile or broken servers
+const (
+	maxRedisBulkSize  = 1024 * 1024
+	maxRedisArrayLen  = 1024
+	maxRedisNestLevel = 4
+)
+
+// minimal redis client speaking RESP2, enough for login, probe command and INFO
+type redisSession struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+}
+
+// error reply sent by the server
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+func (c *Check) openRedis(password string) (session, error) {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(c.target, strconv.Itoa(c.port)), c.timeout)
+	if err != nil {
+		return nil, err
+	}
+	if c.tls {
+		tlsConn := tls.Client(conn, &tls.Config{
+			ServerName:         c.target,
+			InsecureSkipVerify: c.tlsSkipVerify,
+		})
+		tlsConn.SetDeadline(time.Now().Add(c.timeout))
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+	r := &redisSession{conn: conn, reader: bufio.NewReader(conn), timeout: c.timeout}
+
+	if password != "" {
+		// username is supported since redis 6 (ACL)
+		if c.username != "" {
+			_, err = r.do("AUTH", c.username, password)
+		} else {
+			_, err = r.do("AUTH", password)
+		}
+	}
+	if err == nil && c.database != "" {
+		_, err = r.do("SELECT", c.database)
+	}
+	if err != nil {
+		r.close()
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *redisSession) query(ctx context.Context, query string) (string, error) {
+	args := strings.Fields(query)
+	if len(args) == 0 {
+		return "", errors.New("empty redis command")
+	}
+	reply, err := r.do(args...)
+	if err != nil {
+		return "", err
+	}
+	return formatRedisReply(reply), nil
+}
+
+// master_last_io_seconds_ago from INFO replication
+func (r *redisSession) replicationLag(ctx context.Context) (time.Duration, error) {
+	reply, err := r.do("INFO", "replication")
+	if err != nil {
+		return 0, err
+	}
+	info := make(map[string]string)
+	for _, line := range strings.Split(formatRedisReply(reply), "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), ":", 2)
+		if len(parts) == 2 {
+			info[parts[0]] = parts[1]
+		}
+	}
+
+	if info["role"] != "slave" {
+		return 0, notReplicaError
+	}
+	if info["master_link_status"] != "up" {
+		return 0, fmt.Errorf("link to master %s is %s", info["master_host"], info["master_link_status"])
+	}
+	seconds, err := strconv.Atoi(info["master_last_io_seconds_ago"])
+	if err != nil {
+		return 0, fmt.Errorf("invalid master_last_io_seconds_ago %q", info["master_last_io_seconds_ago"])
+	}
+
+	return time.Duration(seconds) * time.Second, nil
+}
+
+func (r *redisSession) close() error {
+	return r.conn.Close()
+}
+
+// send command and read its reply, error reply is returned as error
+func (r *redisSession) do(args ...string) (interface{}, error) {
+	r.conn.SetDeadline(time.Now().Add(r.timeout))
+
+	var command strings.Builder
+	fmt.Fprintf(&command, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&command, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(r.conn, command.String()); err != nil {
+		return nil, err
+	}
+
+	reply, err := r.readReply()
+	if err != nil {
+		return nil, err
+	}
+	if e, ok := reply.(redisError); ok {
+		return nil, fmt.Errorf("%s: %s", strings.ToUpper(args[0]), string(e))
+	}
+	return reply, nil
+}
+
+// reads one reply, types are string, redisError, int64, nil and []interface{}
+func (r *redisSession) readReply() (interface{}, error) {
+	return readRESP(r.reader, 0)
+}
+
+// reads one RESP2 value, depth is nesting level of arrays
+func readRESP(reader *bufio.Reader, depth int) (interface{}, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if len(line) == 0 {
+		return nil, errors.New("empty redis reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return redisError(line[1:]), nil
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		if size > maxRedisBulkSize {
+			return nil, fmt.Errorf("redis bulk reply of %d bytes exceeds limit of %d bytes", size, maxRedisBulkSize)
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if count < 0 {
+			return nil, nil
+		}
+		if count > maxRedisArrayLen {
+			return nil, fmt.Errorf("redis array reply of %d items exceeds limit of %d items", count, maxRedisArrayLen)
+		}
+		if depth >= maxRedisNestLevel {
+			return nil, fmt.Errorf("redis array reply nested deeper than %d levels", maxRedisNestLevel)
+		}
+		items := make([]interface{}, count)
+		for i := range items {
+			if items[i], err = readRESP(reader, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("invalid redis reply %q", line)
+	}
+}
+
+// reply as string comparable with expected value, nil is NULL and arrays are joined by space
+func formatRedisReply(reply interface{}) string {
+	switch v := reply.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case redisError:
+		return string(v)
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = formatRedisReply(item)
+		}
+		return strings.Join(items, " ")
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,48 @@
+package db
+
+import (
+	"bufio"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadRESP(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    interface{}
+		wantErr bool
+	}{
+		{name: "simple string", input: "+PONG\r\n", want: "PONG"},
+		{name: "error", input: "-ERR unknown command\r\n", want: redisError("ERR unknown command")},
+		{name: "integer", input: ":42\r\n", want: int64(42)},
+		{name: "bulk string", input: "$5\r\nhello\r\n", want: "hello"},
+		{name: "empty bulk string", input: "$0\r\n\r\n", want: ""},
+		{name: "null bulk string", input: "$-1\r\n", want: nil},
+		{name: "array", input: "*2\r\n+a\r\n:1\r\n", want: []interface{}{"a", int64(1)}},
+		{name: "null array", input: "*-1\r\n", want: nil},
+		{name: "nested array", input: "*1\r\n*1\r\n+a\r\n", want: []interface{}{[]interface{}{"a"}}},
+		{name: "empty reply", input: "\r\n", wantErr: true},
+		{name: "unknown type", input: "?x\r\n", wantErr: true},
+		{name: "invalid bulk size", input: "$x\r\n", wantErr: true},
+		{name: "truncated bulk string", input: "$10\r\nabc", wantErr: true},
+		{name: "bulk string over limit", input: "$1048577\r\n", wantErr: true},
+		{name: "huge bulk string", input: "$9999999999\r\n", wantErr: true},
+		{name: "array over limit", input: "*1025\r\n", wantErr: true},
+		{name: "huge array", input: "*9999999999\r\n", wantErr: true},
+		{name: "too deep nesting", input: strings.Repeat("*1\r\n", maxRedisNestLevel+1) + "+a\r\n", wantErr: true},
+		{name: "truncated array", input: "*2\r\n+a\r\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readRESP(bufio.NewReader(strings.NewReader(tt.input)), 0)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readRESP(%q) error = %v, wantErr %t", tt.input, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readRESP(%q) = %#v, want %#v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
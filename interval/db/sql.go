@@ -0,0 +1,214 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+)
+
+var errNoRows = errors.New("query returned no rows")
+
+// session over database/sql driver
+type sqlSession struct {
+	engine string
+	db     *sql.DB
+}
+
+func (c *Check) openMysql(ctx context.Context, password string) (session, error) {
+	conf := mysql.NewConfig()
+	conf.User = c.username
+	conf.Passwd = password
+	conf.Net = "tcp"
+	conf.Addr = net.JoinHostPort(c.target, strconv.Itoa(c.port))
+	conf.DBName = c.database
+	conf.Timeout = c.timeout
+	conf.ReadTimeout = c.timeout
+	conf.WriteTimeout = c.timeout
+	if c.tls && c.tlsSkipVerify {
+		conf.TLSConfig = "skip-verify"
+	} else if c.tls {
+		conf.TLSConfig = "true"
+	}
+
+	return openSQL(ctx, EngineMysql, conf.FormatDSN())
+}
+
+func (c *Check) openPostgres(ctx context.Context, password string) (session, error) {
+	params := url.Values{}
+	params.Set("connect_timeout", strconv.Itoa(int(c.timeout.Seconds())))
+	params.Set("application_name", "watcher")
+	if c.tls && c.tlsSkipVerify {
+		params.Set("sslmode", "require")
+	} else if c.tls {
+		params.Set("sslmode", "verify-full")
+	} else {
+		params.Set("sslmode", "disable")
+	}
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(c.username, password),
+		Host:     net.JoinHostPort(c.target, strconv.Itoa(c.port)),
+		RawQuery: params.Encode(),
+	}
+	if c.database != "" {
+		dsn.Path = "/" + c.database
+	}
+
+	return openSQL(ctx, EnginePostgres, dsn.String())
+}
+
+// open the pool with single connection and make sure the login works
+func openSQL(ctx context.Context, engine string, dsn string) (*sqlSession, error) {
+	db, err := sql.Open(engine, dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &sqlSession{engine: engine, db: db}, nil
+}
+
+func (s *sqlSession) query(ctx context.Context, query string) (string, error) {
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return "", err
+		}
+		return "", errNoRows
+	}
+	values := make([]interface{}, len(columns))
+	for i := range values {
+		values[i] = new(sql.NullString)
+	}
+	if err := rows.Scan(values...); err != nil {
+		return "", err
+	}
+	if len(values) == 0 {
+		return "", nil
+	}
+
+	return nullString(*values[0].(*sql.NullString)), nil
+}
+
+func (s *sqlSession) replicationLag(ctx context.Context) (time.Duration, error) {
+	if s.engine == EngineMysql {
+		return s.mysqlReplicationLag(ctx)
+	}
+	return s.postgresReplicationLag(ctx)
+}
+
+// Seconds_Behind_Master from replica status, multi-source replicas report the biggest lag
+func (s *sqlSession) mysqlReplicationLag(ctx context.Context) (time.Duration, error) {
+	// SHOW SLAVE STATUS was removed in MySQL 8.4, older servers know only the old name
+	rows, err := s.db.QueryContext(ctx, "SHOW REPLICA STATUS")
+	if err != nil {
+		rows, err = s.db.QueryContext(ctx, "SHOW SLAVE STATUS")
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	lagColumn := -1
+	for i, column := range columns {
+		if column == "Seconds_Behind_Master" || column == "Seconds_Behind_Source" {
+			lagColumn = i
+		}
+	}
+	if lagColumn == -1 {
+		return 0, errors.New("replica status does not contain Seconds_Behind_Master")
+	}
+
+	var lag time.Duration
+	replicas := 0
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		for i := range values {
+			values[i] = new(sql.NullString)
+		}
+		if err := rows.Scan(values...); err != nil {
+			return 0, err
+		}
+		replicas++
+		seconds := values[lagColumn].(*sql.NullString)
+		// NULL means that replication threads are not running
+		if !seconds.Valid {
+			return 0, errors.New("replication is not running, Seconds_Behind_Master is NULL")
+		}
+		n, err := strconv.Atoi(seconds.String)
+		if err != nil {
+			return 0, fmt.Errorf("invalid Seconds_Behind_Master %q", seconds.String)
+		}
+		if d := time.Duration(n) * time.Second; d > lag {
+			lag = d
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if replicas == 0 {
+		return 0, notReplicaError
+	}
+
+	return lag, nil
+}
+
+// time since last replayed transaction while replay is behind received wal, zero when the replica caught up,
+// so idle primary without write traffic does not look like lag
+func (s *sqlSession) postgresReplicationLag(ctx context.Context) (time.Duration, error) {
+	const query = `SELECT pg_is_in_recovery(),
+		CASE WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		ELSE EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()) END`
+	var inRecovery bool
+	var seconds sql.NullFloat64
+	err := s.db.QueryRowContext(ctx, query).Scan(&inRecovery, &seconds)
+	if err != nil {
+		return 0, err
+	}
+	if !inRecovery {
+		return 0, notReplicaError
+	}
+	if !seconds.Valid {
+		return 0, errors.New("no transaction replayed yet, pg_last_xact_replay_timestamp is NULL")
+	}
+	if seconds.Float64 < 0 {
+		return 0, nil
+	}
+
+	return time.Duration(seconds.Float64 * float64(time.Second)), nil
+}
+
+func (s *sqlSession) close() error {
+	return s.db.Close()
+}
+
+func nullString(value sql.NullString) string {
+	if !value.Valid {
+		return "NULL"
+	}
+	return value.String
+}
@@ -0,0 +1,284 @@
+package dns
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	// edns buffer size recommended to avoid ip fragmentation
+	ednsBufferSize = 1232
+	maxMessageSize = 65535
+
+	// header flags not exposed by dnsmessage.Header
+	flagAuthenticData    = 0x20
+	flagCheckingDisabled = 0x10
+
+	typeRRSIG dnsmessage.Type = 46
+)
+
+var recordTypes = map[string]dnsmessage.Type{
+	"A":     dnsmessage.TypeA,
+	"AAAA":  dnsmessage.TypeAAAA,
+	"CNAME": dnsmessage.TypeCNAME,
+	"MX":    dnsmessage.TypeMX,
+	"NS":    dnsmessage.TypeNS,
+	"PTR":   dnsmessage.TypePTR,
+	"SOA":   dnsmessage.TypeSOA,
+	"SRV":   dnsmessage.TypeSRV,
+	"TXT":   dnsmessage.TypeTXT,
+}
+
+// query options
+type query struct {
+	name             string
+	qtype            dnsmessage.Type
+	recursion        bool
+	dnssec           bool // set DO bit to get signatures and validation status
+	checkingDisabled bool // ask resolver to skip dnssec validation
+}
+
+// parsed dns response
+type response struct {
+	rcode         dnsmessage.RCode
+	authoritative bool
+	authenticated bool     // AD flag, resolver validated the answer
+	signed        bool     // answer contains RRSIG records
+	answers       []string // sorted records in text form, without signatures
+	soaSerial     uint32
+	hasSOA        bool
+}
+
+// returns answers or rcode name for comparison between servers
+func (r *response) String() string {
+	if r.rcode != dnsmessage.RCodeSuccess {
+		return rcodeName(r.rcode)
+	}
+	if len(r.answers) == 0 {
+		return "NODATA"
+	}
+	return "[" + strings.Join(r.answers, ", ") + "]"
+}
+
+// send query over udp and retry over tcp if the response is truncated
+func exchange(ctx context.Context, server string, q query) (*response, error) {
+	msg, id, err := buildQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := exchangeUDP(ctx, server, msg, id)
+	if err != nil {
+		return nil, err
+	}
+	if raw[2]&0x02 != 0 {
+		raw, err = exchangeTCP(ctx, server, msg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return parseResponse(raw, id, q.name)
+}
+
+func buildQuery(q query) ([]byte, uint16, error) {
+	name, err := dnsmessage.NewName(fqdn(q.name))
+	if err != nil {
+		return nil, 0, err
+	}
+	id := uint16(rand.Intn(65536))
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, RecursionDesired: q.recursion})
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil, 0, err
+	}
+	if err := b.Question(dnsmessage.Question{Name: name, Type: q.qtype, Class: dnsmessage.ClassINET}); err != nil {
+		return nil, 0, err
+	}
+	if err := b.StartAdditionals(); err != nil {
+		return nil, 0, err
+	}
+	var opt dnsmessage.ResourceHeader
+	if err := opt.SetEDNS0(ednsBufferSize, dnsmessage.RCodeSuccess, q.dnssec); err != nil {
+		return nil, 0, err
+	}
+	if err := b.OPTResource(opt, dnsmessage.OPTResource{}); err != nil {
+		return nil, 0, err
+	}
+	msg, err := b.Finish()
+	if err != nil {
+		return nil, 0, err
+	}
+	if q.checkingDisabled {
+		msg[3] |= flagCheckingDisabled
+	}
+	return msg, id, nil
+}
+
+func exchangeUDP(ctx context.Context, server string, msg []byte, id uint16) ([]byte, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if _, err := conn.Write(msg); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		// ignore late responses to other queries
+		if n >= 12 && binary.BigEndian.Uint16(buf) == id {
+			return buf[:n], nil
+		}
+	}
+}
+
+func exchangeTCP(ctx context.Context, server string, msg []byte) ([]byte, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	// tcp messages are prefixed with their length
+	packet := make([]byte, 2, 2+len(msg))
+	binary.BigEndian.PutUint16(packet, uint16(len(msg)))
+	if _, err := conn.Write(append(packet, msg...)); err != nil {
+		return nil, err
+	}
+	var length uint16
+	if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+		return nil, err
+	}
+	raw := make([]byte, length)
+	if _, err := io.ReadFull(conn, raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// parse response, only records owned by the queried name are kept so cname chains followed by resolvers do not differ from authoritative answers
+func parseResponse(raw []byte, id uint16, name string) (*response, error) {
+	var p dnsmessage.Parser
+	h, err := p.Start(raw)
+	if err != nil {
+		return nil, errors.Wrap(invalidResponseError, err.Error())
+	}
+	if h.ID != id || !h.Response {
+		return nil, errors.Wrap(invalidResponseError, "response does not match the query")
+	}
+	r := &response{
+		rcode:         h.RCode,
+		authoritative: h.Authoritative,
+		authenticated: raw[3]&flagAuthenticData != 0,
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return nil, errors.Wrap(invalidResponseError, err.Error())
+	}
+
+	for {
+		rh, err := p.AnswerHeader()
+		if err == dnsmessage.ErrSectionDone {
+			break
+		} else if err != nil {
+			return nil, errors.Wrap(invalidResponseError, err.Error())
+		}
+		value, err := parseRecord(&p, rh, r)
+		if err != nil {
+			return nil, errors.Wrap(invalidResponseError, err.Error())
+		}
+		if value != "" && strings.EqualFold(rh.Name.String(), fqdn(name)) {
+			r.answers = append(r.answers, typeName(rh.Type)+" "+value)
+		}
+	}
+	sort.Strings(r.answers)
+	return r, nil
+}
+
+// returns record value in text form, empty value for records which are not compared
+func parseRecord(p *dnsmessage.Parser, rh dnsmessage.ResourceHeader, r *response) (string, error) {
+	switch rh.Type {
+	case dnsmessage.TypeA:
+		rr, err := p.AResource()
+		return net.IP(rr.A[:]).String(), err
+	case dnsmessage.TypeAAAA:
+		rr, err := p.AAAAResource()
+		return net.IP(rr.AAAA[:]).String(), err
+	case dnsmessage.TypeCNAME:
+		rr, err := p.CNAMEResource()
+		return strings.ToLower(rr.CNAME.String()), err
+	case dnsmessage.TypeNS:
+		rr, err := p.NSResource()
+		return strings.ToLower(rr.NS.String()), err
+	case dnsmessage.TypePTR:
+		rr, err := p.PTRResource()
+		return strings.ToLower(rr.PTR.String()), err
+	case dnsmessage.TypeMX:
+		rr, err := p.MXResource()
+		return fmt.Sprintf("%d %s", rr.Pref, strings.ToLower(rr.MX.String())), err
+	case dnsmessage.TypeSRV:
+		rr, err := p.SRVResource()
+		return fmt.Sprintf("%d %d %d %s", rr.Priority, rr.Weight, rr.Port, strings.ToLower(rr.Target.String())), err
+	case dnsmessage.TypeTXT:
+		rr, err := p.TXTResource()
+		return fmt.Sprintf("%q", strings.Join(rr.TXT, "")), err
+	case dnsmessage.TypeSOA:
+		rr, err := p.SOAResource()
+		r.soaSerial = rr.Serial
+		r.hasSOA = true
+		return fmt.Sprintf("%s %s %d", strings.ToLower(rr.NS.String()), strings.ToLower(rr.MBox.String()), rr.Serial), err
+	case typeRRSIG:
+		r.signed = true
+	}
+	return "", p.SkipAnswer()
+}
+
+func typeName(t dnsmessage.Type) string {
+	for name, recordType := range recordTypes {
+		if recordType == t {
+			return name
+		}
+	}
+	return fmt.Sprintf("TYPE%d", t)
+}
+
+var rcodeNames = map[dnsmessage.RCode]string{
+	dnsmessage.RCodeSuccess:        "NOERROR",
+	dnsmessage.RCodeFormatError:    "FORMERR",
+	dnsmessage.RCodeServerFailure:  "SERVFAIL",
+	dnsmessage.RCodeNameError:      "NXDOMAIN",
+	dnsmessage.RCodeNotImplemented: "NOTIMP",
+	dnsmessage.RCodeRefused:        "REFUSED",
+}
+
+func rcodeName(rcode dnsmessage.RCode) string {
+	if name, ok := rcodeNames[rcode]; ok {
+		return name
+	}
+	return fmt.Sprintf("RCODE%d", rcode)
+}
+
+func fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
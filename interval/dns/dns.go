@@ -0,0 +1,453 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/exmonitor/exclient/database"
+	"github.com/exmonitor/exlogger"
+	"github.com/pkg/errors"
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/exmonitor/watcher/interval/family"
+	"github.com/exmonitor/watcher/interval/spec"
+	"github.com/exmonitor/watcher/interval/status"
+	"github.com/exmonitor/watcher/key"
+)
+
+const (
+	msgSuccess             = "success"
+	msgFailedDiscovery     = "failed to discover nameservers"
+	msgFailedInconsistency = "failed - dns inconsistency"
+
+	dnsPort = "53"
+
+	dnssecSecure   = "secure"   // all resolvers validated the answer
+	dnssecInsecure = "insecure" // answer is not validated by resolvers
+	dnssecBogus    = "bogus"    // validation failed, answer is returned only with checking disabled
+	dnssecSigned   = "signed"   // no resolvers configured, authoritative answer carries signatures
+	dnssecUnsigned = "unsigned" // no resolvers configured, authoritative answer has no signatures
+)
+
+type CheckConfig struct {
+	Id            int
+	FailThreshold int
+	Interval      int
+	Zone          string
+	Record        string   // record name inside the zone, zone apex if empty
+	RecordType    string   // A if empty
+	Nameservers   []string // authoritative servers with optional port, discovered from NS records if empty
+	Resolvers     []string // recursive resolvers with optional port
+	RequireDnssec bool
+	Timeout       time.Duration
+	IpFamily      string
+
+	//db client
+	DBClient database.ClientInterface
+	Logger   *exlogger.Logger
+}
+
+type Check struct {
+	id            int
+	failThreshold int
+	interval      int
+	requestId     string
+	zone          string
+	record        string
+	recordType    dnsmessage.Type
+	nameservers   []string
+	resolvers     []string
+	requireDnssec bool
+	timeout       time.Duration
+	ipFamily      string
+
+	// db client
+	dbClient database.ClientInterface
+	// logger
+	log *exlogger.Logger
+
+	// internals
+	spec.CheckInterface
+}
+
+// address of a single authoritative server, name is empty for servers configured by ip
+type nameserver struct {
+	name string
+	addr string
+}
+
+func (n nameserver) String() string {
+	if n.name == "" {
+		return n.addr
+	}
+	return fmt.Sprintf("%s (%s)", n.name, n.addr)
+}
+
+// responses of single authoritative server
+type authResult struct {
+	server nameserver
+	soa    *response
+	answer *response
+	err    error
+}
+
+// responses of single resolver
+type resolverResult struct {
+	server string
+	answer *response
+	dnssec string
+	err    error
+}
+
+func NewCheck(conf CheckConfig) (*Check, error) {
+	if conf.Id == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.id must not be zero")
+	}
+	if conf.FailThreshold == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.FailThreshold must not be zero")
+	}
+	if conf.Interval == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.Interval must not be zero")
+	}
+	conf.Zone = strings.Trim(strings.ToLower(conf.Zone), ".")
+	if conf.Zone == "" {
+		return nil, errors.Wrap(invalidConfigError, "conf.Zone must not be empty")
+	}
+	conf.Record = strings.Trim(strings.ToLower(conf.Record), ".")
+	if conf.Record == "" {
+		conf.Record = conf.Zone
+	}
+	if conf.Record != conf.Zone && !strings.HasSuffix(conf.Record, "."+conf.Zone) {
+		return nil, errors.Wrapf(invalidConfigError, "conf.Record %s is not inside zone %s", conf.Record, conf.Zone)
+	}
+	if conf.RecordType == "" {
+		conf.RecordType = "A"
+	}
+	recordType, ok := recordTypes[strings.ToUpper(conf.RecordType)]
+	if !ok {
+		return nil, errors.Wrapf(invalidConfigError, "conf.RecordType %s is not supported", conf.RecordType)
+	}
+	for _, ns := range conf.Nameservers {
+		if ns == "" {
+			return nil, errors.Wrap(invalidConfigError, "conf.Nameservers must not contain empty server")
+		}
+	}
+	resolvers := make([]string, len(conf.Resolvers))
+	for i, resolver := range conf.Resolvers {
+		if resolver == "" {
+			return nil, errors.Wrap(invalidConfigError, "conf.Resolvers must not contain empty server")
+		}
+		resolvers[i] = withPort(resolver)
+	}
+	if conf.Timeout == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.Timeout must not be zero")
+	}
+	if conf.DBClient == nil {
+		return nil, errors.Wrap(invalidConfigError, "conf.DBClient must not be nil")
+	}
+	if conf.Logger == nil {
+		return nil, errors.Wrap(invalidConfigError, "conf.Logger must not be nil")
+	}
+	if err := family.Validate(conf.IpFamily); err != nil {
+		return nil, errors.Wrap(invalidConfigError, err.Error())
+	}
+
+	newCheck := &Check{
+		id:            conf.Id,
+		failThreshold: conf.FailThreshold,
+		interval:      conf.Interval,
+		zone:          conf.Zone,
+		record:        conf.Record,
+		recordType:    recordType,
+		nameservers:   conf.Nameservers,
+		resolvers:     resolvers,
+		requireDnssec: conf.RequireDnssec,
+		timeout:       conf.Timeout,
+		ipFamily:      conf.IpFamily,
+
+		dbClient: conf.DBClient,
+		log:      conf.Logger,
+	}
+
+	return newCheck, nil
+}
+
+// wrapper function used to run in separate thread (goroutine)
+func (c *Check) RunCheck() {
+	// generate unique request ID
+	c.requestId = key.GenerateReqId(c.id)
+	// run monitoring check
+	s := c.doCheck()
+	c.LogResult(s)
+
+	// save result to database
+	s.SaveToDB()
+}
+
+// run monitoring check, all servers are queried in parallel and all found problems are reported at once
+func (c *Check) doCheck() *status.Status {
+	statusConfig := status.Config{
+		Id:            c.id,
+		ReqId:         c.requestId,
+		Interval:      c.interval,
+		FailThreshold: c.failThreshold,
+		DBClient:      c.dbClient,
+	}
+	s, err := status.New(statusConfig)
+	if err != nil {
+		c.LogRunError(err, fmt.Sprintf("failed to init new status for DNS service ID %d", c.id))
+	}
+	tStart := time.Now()
+	defer func() {
+		s.Duration = time.Since(tStart)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	servers, unresolved, err := c.resolveNameservers(ctx)
+	if err != nil {
+		s.Set(false, err, msgFailedDiscovery)
+		return s
+	}
+
+	authResults := make([]authResult, len(servers))
+	resolverResults := make([]resolverResult, len(c.resolvers))
+	var wg sync.WaitGroup
+	for i, server := range servers {
+		wg.Add(1)
+		go func(i int, server nameserver) {
+			defer wg.Done()
+			authResults[i] = c.queryAuthoritative(ctx, server)
+		}(i, server)
+	}
+	for i, resolver := range c.resolvers {
+		wg.Add(1)
+		go func(i int, resolver string) {
+			defer wg.Done()
+			resolverResults[i] = c.queryResolver(ctx, resolver)
+		}(i, resolver)
+	}
+	wg.Wait()
+
+	problems := unresolved
+	var healthy []authResult
+	for _, r := range authResults {
+		if reason := lameReason(r); reason != "" {
+			problems = append(problems, fmt.Sprintf("lame delegation on %s: %s", r.server, reason))
+		} else {
+			healthy = append(healthy, r)
+		}
+	}
+
+	serials := groupServers(healthy, func(r authResult) string { return fmt.Sprint(r.soa.soaSerial) })
+	if len(serials) > 1 {
+		problems = append(problems, "serial mismatch: "+formatGroups(serials))
+	}
+	answers := groupServers(healthy, func(r authResult) string { return r.answer.String() })
+	if len(answers) > 1 {
+		problems = append(problems, "answer mismatch: "+formatGroups(answers))
+	}
+
+	// resolvers are compared only when authoritative servers agree on the answer
+	var answer string
+	if len(answers) == 1 {
+		answer = healthy[0].answer.String()
+	}
+	for _, r := range resolverResults {
+		if r.err != nil {
+			problems = append(problems, fmt.Sprintf("resolver %s: %s", r.server, r.err))
+		} else if answer != "" && r.answer.String() != answer {
+			problems = append(problems, fmt.Sprintf("resolver %s returned %s instead of %s", r.server, r.answer, answer))
+		}
+	}
+
+	dnssec := c.dnssecStatus(healthy, resolverResults)
+	if strings.HasPrefix(dnssec, dnssecBogus) {
+		problems = append(problems, "dnssec validation failed: "+dnssec)
+	} else if c.requireDnssec && dnssec != dnssecSecure && dnssec != dnssecSigned {
+		problems = append(problems, "dnssec required but answer is "+dnssec)
+	}
+
+	if len(problems) > 0 {
+		s.Set(false, nil, fmt.Sprintf("%s, %s", msgFailedInconsistency, strings.Join(problems, "; ")))
+		return s
+	}
+	s.Set(true, nil, fmt.Sprintf("%s, serial %d on %d servers, answer %s, %d resolvers agree, dnssec %s", msgSuccess, healthy[0].soa.soaSerial, len(healthy), answer, len(c.resolvers), dnssec))
+	return s
+}
+
+// returns addresses of configured or discovered nameservers in all requested families,
+// servers which can not be resolved are returned as problems so the other servers are still checked
+func (c *Check) resolveNameservers(ctx context.Context) ([]nameserver, []string, error) {
+	hosts := c.nameservers
+	if len(hosts) == 0 {
+		records, err := net.DefaultResolver.LookupNS(ctx, c.zone)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, record := range records {
+			hosts = append(hosts, strings.TrimSuffix(strings.ToLower(record.Host), "."))
+		}
+		sort.Strings(hosts)
+	}
+
+	var servers []nameserver
+	var problems []string
+	for _, host := range hosts {
+		name, port := host, dnsPort
+		if h, p, err := net.SplitHostPort(host); err == nil {
+			name, port = h, p
+		}
+		if net.ParseIP(name) != nil {
+			servers = append(servers, nameserver{addr: net.JoinHostPort(name, port)})
+			continue
+		}
+		for _, f := range family.Split(c.ipFamily) {
+			ip, err := family.ResolveIPAddr(name, f)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("lame delegation on %s: %s", name, err))
+				continue
+			}
+			servers = append(servers, nameserver{name: name, addr: net.JoinHostPort(ip.String(), port)})
+		}
+	}
+	if len(servers) == 0 && len(problems) == 0 {
+		return nil, nil, errors.Wrapf(invalidConfigError, "zone %s has no nameservers", c.zone)
+	}
+	return servers, problems, nil
+}
+
+// query soa of the zone and the record on authoritative server without recursion
+func (c *Check) queryAuthoritative(ctx context.Context, server nameserver) authResult {
+	r := authResult{server: server}
+	r.soa, r.err = exchange(ctx, server.addr, query{name: c.zone, qtype: dnsmessage.TypeSOA})
+	if r.err != nil {
+		return r
+	}
+	r.answer, r.err = exchange(ctx, server.addr, query{name: c.record, qtype: c.recordType, dnssec: true})
+	return r
+}
+
+// query the record on resolver, failed validation is recognised by SERVFAIL which disappears with checking disabled
+func (c *Check) queryResolver(ctx context.Context, server string) resolverResult {
+	r := resolverResult{server: server}
+	q := query{name: c.record, qtype: c.recordType, recursion: true, dnssec: true}
+	r.answer, r.err = exchange(ctx, server, q)
+	if r.err != nil {
+		return r
+	}
+	if r.answer.authenticated {
+		r.dnssec = dnssecSecure
+	} else {
+		r.dnssec = dnssecInsecure
+	}
+	if r.answer.rcode == dnsmessage.RCodeServerFailure {
+		q.checkingDisabled = true
+		if unchecked, err := exchange(ctx, server, q); err == nil && unchecked.rcode != dnsmessage.RCodeServerFailure {
+			r.answer = unchecked
+			r.dnssec = dnssecBogus
+		}
+	}
+	return r
+}
+
+// returns reason why the server is not considered authoritative for the zone, empty if it is
+func lameReason(r authResult) string {
+	switch {
+	case r.err != nil:
+		return r.err.Error()
+	case r.soa.rcode != dnsmessage.RCodeSuccess:
+		return "soa query returned " + rcodeName(r.soa.rcode)
+	case !r.soa.authoritative || !r.answer.authoritative:
+		return "answer is not authoritative"
+	case !r.soa.hasSOA:
+		return "no soa record for the zone"
+	}
+	return ""
+}
+
+// returns summary of dnssec status, resolvers disagreeing on the status are listed
+func (c *Check) dnssecStatus(auth []authResult, resolvers []resolverResult) string {
+	if len(resolvers) == 0 {
+		for _, r := range auth {
+			if !r.answer.signed {
+				return dnssecUnsigned
+			}
+		}
+		if len(auth) == 0 {
+			return dnssecUnsigned
+		}
+		return dnssecSigned
+	}
+
+	statuses := make(map[string][]string)
+	for _, r := range resolvers {
+		if r.err == nil {
+			statuses[r.dnssec] = append(statuses[r.dnssec], r.server)
+		}
+	}
+	for _, st := range []string{dnssecBogus, dnssecInsecure} {
+		if servers, ok := statuses[st]; ok {
+			if len(statuses) == 1 {
+				return st
+			}
+			return fmt.Sprintf("%s on %s", st, strings.Join(servers, ", "))
+		}
+	}
+	if len(statuses) == 0 {
+		return dnssecInsecure
+	}
+	return dnssecSecure
+}
+
+// groups server names by the value
+func groupServers(results []authResult, value func(authResult) string) map[string][]string {
+	groups := make(map[string][]string)
+	for _, r := range results {
+		v := value(r)
+		groups[v] = append(groups[v], r.server.String())
+	}
+	return groups
+}
+
+// format groups of servers, ie: "2024010101 on ns1 (192.0.2.1), ns2 (192.0.2.2); 2024010102 on ns3 (192.0.2.3)"
+func formatGroups(groups map[string][]string) string {
+	values := make([]string, 0, len(groups))
+	for value := range groups {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+
+	parts := make([]string, len(values))
+	for i, value := range values {
+		parts[i] = fmt.Sprintf("%s on %s", value, strings.Join(groups[value], ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// add default dns port if the server has none
+func withPort(server string) string {
+	if _, _, err := net.SplitHostPort(server); err == nil {
+		return server
+	}
+	return net.JoinHostPort(strings.Trim(server, "[]"), dnsPort)
+}
+
+func (c *Check) GetStringPort() string {
+	// dns servers are queried on their own ports
+	return ""
+}
+
+func (c *Check) LogResult(s *status.Status) {
+	c.log.Log("check-DNS|id %d|reqID %s|zone %s|record %s %s|nameservers %d|resolvers %d|latency %sms|result '%t'|msg: %s", c.id, c.requestId, c.zone, c.record, typeName(c.recordType), len(c.nameservers), len(c.resolvers), key.MsFromDuration(s.Duration), s.Result, s.Message)
+}
+
+func (c *Check) LogRunError(err error, message string) {
+	c.log.LogError(err, "running check id:%d reqID:%s type:dns zone:%s failed, reason: %s", c.id, c.requestId, c.zone, message)
+}
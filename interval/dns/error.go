@@ -0,0 +1,7 @@
+package dns
+
+import "errors"
+
+var invalidConfigError error = errors.New("invalid check config")
+
+var invalidResponseError error = errors.New("invalid dns response")
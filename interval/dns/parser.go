@@ -0,0 +1,67 @@
+package dns
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/exmonitor/exclient/database"
+	"github.com/exmonitor/exclient/database/spec/service"
+	"github.com/exmonitor/exlogger"
+	"github.com/pkg/errors"
+)
+
+/*
+Example metadata:
+{
+	"id": 21,
+	"zone": "domain.cz",
+	"record": "www.domain.cz",
+	"recordType": "A",
+	"nameservers": ["ns1.domain.cz", "ns2.domain.cz", "192.0.2.53:5353"],
+	"resolvers": ["1.1.1.1", "8.8.8.8:53"],
+	"requireDnssec": true,
+	"timeout": 5,
+	"ipFamily": "both"
+}
+*/
+
+type RawCheck struct {
+	Id            int      `json:"id"`
+	Zone          string   `json:"zone"`
+	Record        string   `json:"record"`
+	RecordType    string   `json:"recordType"`
+	Nameservers   []string `json:"nameservers"`
+	Resolvers     []string `json:"resolvers"`
+	RequireDnssec bool     `json:"requireDnssec"`
+	Timeout       int      `json:"timeout"`
+	IpFamily      string   `json:"ipFamily"`
+}
+
+func ParseCheck(service *service.Service, dbClient database.ClientInterface, logger *exlogger.Logger) (*Check, error) {
+	var rawCheck RawCheck
+	err := json.Unmarshal([]byte(service.Metadata), &rawCheck)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to parse DNS json metadata for check id %d", service.ID))
+	} else {
+		logger.LogDebug("Successfully parsed DNS json metadata for check id %d", service.ID)
+	}
+
+	checkConfig := CheckConfig{
+		Id:            service.ID,
+		FailThreshold: service.FailThreshold,
+		Interval:      service.Interval,
+		Zone:          rawCheck.Zone,
+		Record:        rawCheck.Record,
+		RecordType:    rawCheck.RecordType,
+		Nameservers:   rawCheck.Nameservers,
+		Resolvers:     rawCheck.Resolvers,
+		RequireDnssec: rawCheck.RequireDnssec,
+		Timeout:       time.Second * time.Duration(rawCheck.Timeout),
+		IpFamily:      rawCheck.IpFamily,
+		Logger:        logger,
+		DBClient:      dbClient,
+	}
+
+	return NewCheck(checkConfig)
+}
@@ -0,0 +1,268 @@
+package dnsbl
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/exmonitor/exclient/database"
+	"github.com/exmonitor/exlogger"
+	"github.com/pkg/errors"
+
+	"github.com/exmonitor/watcher/interval/spec"
+	"github.com/exmonitor/watcher/interval/status"
+	"github.com/exmonitor/watcher/key"
+)
+
+const (
+	msgSuccess         = "success, not listed"
+	msgFailedListed    = "failed - listed"
+	msgFailedResolve   = "failed to resolve target"
+	msgFailedQuery     = "failed to query dnsbl zones"
+	msgPartialFailures = "queries failed"
+
+	// max length of listing reason in the message
+	maxReasonLength = 128
+)
+
+type CheckConfig struct {
+	Id            int
+	FailThreshold int
+	Interval      int
+	Targets       []string // ip addresses or host names, host names are checked for all their addresses
+	Zones         []string
+	Resolver      string // dns server in host:port format, empty for system resolver
+	Timeout       time.Duration
+
+	//db client
+	DBClient database.ClientInterface
+	Logger   *exlogger.Logger
+}
+
+type Check struct {
+	id            int
+	failThreshold int
+	interval      int
+	requestId     string
+	targets       []string
+	zones         []string
+	resolverAddr  string
+	resolver      *net.Resolver
+	timeout       time.Duration
+
+	// db client
+	dbClient database.ClientInterface
+	// logger
+	log *exlogger.Logger
+
+	// internals
+	spec.CheckInterface
+}
+
+func NewCheck(conf CheckConfig) (*Check, error) {
+	if conf.Id == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.id must not be zero")
+	}
+	if conf.FailThreshold == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.FailThreshold must not be zero")
+	}
+	if conf.Interval == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.Interval must not be zero")
+	}
+	if len(conf.Targets) == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.Targets must not be empty")
+	}
+	if len(conf.Zones) == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.Zones must not be empty")
+	}
+	zones := make([]string, len(conf.Zones))
+	for i, zone := range conf.Zones {
+		zones[i] = strings.Trim(strings.ToLower(zone), ".")
+		if zones[i] == "" {
+			return nil, errors.Wrap(invalidConfigError, "conf.Zones must not contain empty zone")
+		}
+	}
+	if conf.Resolver != "" {
+		if _, _, err := net.SplitHostPort(conf.Resolver); err != nil {
+			return nil, errors.Wrapf(invalidConfigError, "conf.Resolver must be in host:port format, %s", err)
+		}
+	}
+	if conf.Timeout == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.Timeout must not be zero")
+	}
+	if conf.DBClient == nil {
+		return nil, errors.Wrap(invalidConfigError, "conf.DBClient must not be nil")
+	}
+	if conf.Logger == nil {
+		return nil, errors.Wrap(invalidConfigError, "conf.Logger must not be nil")
+	}
+
+	newCheck := &Check{
+		id:            conf.Id,
+		failThreshold: conf.FailThreshold,
+		interval:      conf.Interval,
+		targets:       conf.Targets,
+		zones:         zones,
+		resolverAddr:  conf.Resolver,
+		resolver:      newResolver(conf.Resolver),
+		timeout:       conf.Timeout,
+
+		dbClient: conf.DBClient,
+		log:      conf.Logger,
+	}
+
+	return newCheck, nil
+}
+
+// wrapper function used to run in separate thread (goroutine)
+func (c *Check) RunCheck() {
+	// generate unique request ID
+	c.requestId = key.GenerateReqId(c.id)
+	// run monitoring check
+	s := c.doCheck()
+	c.LogResult(s)
+
+	// save result to database
+	s.SaveToDB()
+}
+
+// run monitoring check, all addresses are looked up in all zones in parallel
+func (c *Check) doCheck() *status.Status {
+	statusConfig := status.Config{
+		Id:            c.id,
+		ReqId:         c.requestId,
+		Interval:      c.interval,
+		FailThreshold: c.failThreshold,
+		DBClient:      c.dbClient,
+	}
+	s, err := status.New(statusConfig)
+	if err != nil {
+		c.LogRunError(err, fmt.Sprintf("failed to init new status for DNSBL service ID %d", c.id))
+	}
+	tStart := time.Now()
+	defer func() {
+		s.Duration = time.Since(tStart)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	addresses, err := c.resolveTargets(ctx)
+	if err != nil {
+		s.Set(false, err, msgFailedResolve)
+		return s
+	}
+
+	listings := make([]listing, len(addresses)*len(c.zones))
+	var wg sync.WaitGroup
+	for i, ip := range addresses {
+		for j, zone := range c.zones {
+			wg.Add(1)
+			go func(n int, ip net.IP, zone string) {
+				defer wg.Done()
+				listings[n] = lookup(ctx, c.resolver, ip, zone)
+			}(i*len(c.zones)+j, ip, zone)
+		}
+	}
+	wg.Wait()
+
+	var listed []listing
+	var failed []string
+	for _, l := range listings {
+		if l.err != nil {
+			failed = append(failed, fmt.Sprintf("%s in %s: %s", l.address, l.zone, l.err))
+		} else if l.listed {
+			listed = append(listed, l)
+		}
+	}
+
+	if len(listed) > 0 {
+		s.Set(false, nil, fmt.Sprintf("%s, %s", msgFailedListed, formatListed(listed)))
+		return s
+	}
+	if len(failed) == len(listings) {
+		s.Set(false, nil, fmt.Sprintf("%s, %s", msgFailedQuery, strings.Join(failed, "; ")))
+		return s
+	}
+	// single zone being down must not hide the result of the other zones
+	msg := fmt.Sprintf("%s, %d addresses in %d zones", msgSuccess, len(addresses), len(c.zones))
+	if len(failed) > 0 {
+		msg += fmt.Sprintf(", %d %s: %s", len(failed), msgPartialFailures, strings.Join(failed, "; "))
+	}
+	s.Set(true, nil, msg)
+	return s
+}
+
+// returns unique addresses of all targets
+func (c *Check) resolveTargets(ctx context.Context) ([]net.IP, error) {
+	var addresses []net.IP
+	seen := make(map[string]bool)
+	for _, target := range c.targets {
+		var ips []net.IP
+		if ip := net.ParseIP(target); ip != nil {
+			ips = []net.IP{ip}
+		} else {
+			addrs, err := c.resolver.LookupIPAddr(ctx, target)
+			if err != nil {
+				return nil, err
+			}
+			for _, addr := range addrs {
+				ips = append(ips, addr.IP)
+			}
+		}
+		for _, ip := range ips {
+			if !seen[ip.String()] {
+				seen[ip.String()] = true
+				addresses = append(addresses, ip)
+			}
+		}
+	}
+	return addresses, nil
+}
+
+// group listings by address, ie: "203.0.113.5 in zen.spamhaus.org (127.0.0.2 'reason'), bl.spamcop.net (127.0.0.2)"
+func formatListed(listed []listing) string {
+	zones := make(map[string][]string)
+	var addresses []string
+	for _, l := range listed {
+		if _, ok := zones[l.address]; !ok {
+			addresses = append(addresses, l.address)
+		}
+		zone := fmt.Sprintf("%s (%s", l.zone, strings.Join(l.codes, ", "))
+		if l.reason != "" {
+			zone += fmt.Sprintf(" '%s'", truncate(l.reason, maxReasonLength))
+		}
+		zones[l.address] = append(zones[l.address], zone+")")
+	}
+	sort.Strings(addresses)
+
+	parts := make([]string, len(addresses))
+	for i, address := range addresses {
+		parts[i] = fmt.Sprintf("%s in %s", address, strings.Join(zones[address], ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func truncate(s string, length int) string {
+	if len(s) <= length {
+		return s
+	}
+	return s[:length] + "..."
+}
+
+func (c *Check) GetStringPort() string {
+	// dnsbl has no port
+	return ""
+}
+
+func (c *Check) LogResult(s *status.Status) {
+	c.log.Log("check-DNSBL|id %d|reqID %s|targets %s|zones %d|resolver %s|latency %sms|result '%t'|msg: %s", c.id, c.requestId, strings.Join(c.targets, ","), len(c.zones), c.resolverAddr, key.MsFromDuration(s.Duration), s.Result, s.Message)
+}
+
+func (c *Check) LogRunError(err error, message string) {
+	c.log.LogError(err, "running check id:%d reqID:%s type:dnsbl targets:%s failed, reason: %s", c.id, c.requestId, strings.Join(c.targets, ","), message)
+}
@@ -0,0 +1,7 @@
+package dnsbl
+
+import "errors"
+
+var invalidConfigError error = errors.New("invalid check config")
+
+var invalidAnswerError error = errors.New("invalid dnsbl answer")
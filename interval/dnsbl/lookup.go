@@ -0,0 +1,87 @@
+package dnsbl
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// answers from 127.255.255.0/24 are error codes, ie: spamhaus refuses queries from public resolvers
+var errorCodes = &net.IPNet{IP: net.IPv4(127, 255, 255, 0), Mask: net.CIDRMask(24, 32)}
+
+// result of single address lookup in single zone
+type listing struct {
+	address string
+	zone    string
+	listed  bool
+	codes   []string // answers of the zone, ie: 127.0.0.2
+	reason  string   // txt record of the listing
+	err     error
+}
+
+// returns dnsbl query name for the address, reversed octets for ipv4 and reversed nibbles for ipv6
+func queryName(ip net.IP, zone string) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.%s", ip4[3], ip4[2], ip4[1], ip4[0], zone)
+	}
+	const hexDigits = "0123456789abcdef"
+	ip16 := ip.To16()
+	nibbles := make([]string, 0, 32)
+	for i := len(ip16) - 1; i >= 0; i-- {
+		nibbles = append(nibbles, string(hexDigits[ip16[i]&0x0f]), string(hexDigits[ip16[i]>>4]))
+	}
+	return strings.Join(nibbles, ".") + "." + zone
+}
+
+// lookup the address in the zone, not existing name means the address is not listed
+func lookup(ctx context.Context, resolver *net.Resolver, ip net.IP, zone string) listing {
+	l := listing{address: ip.String(), zone: zone}
+	name := queryName(ip, zone)
+
+	addrs, err := resolver.LookupIPAddr(ctx, name)
+	if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+		return l
+	} else if err != nil {
+		l.err = err
+		return l
+	}
+	for _, addr := range addrs {
+		ip4 := addr.IP.To4()
+		if ip4 == nil || ip4[0] != 127 {
+			// answers outside of loopback usually come from resolvers hijacking NXDOMAIN
+			l.err = errors.Wrapf(invalidAnswerError, "%s returned %s", name, addr.IP)
+			return l
+		}
+		if errorCodes.Contains(ip4) {
+			l.err = errors.Wrapf(invalidAnswerError, "%s returned error code %s", name, addr.IP)
+			return l
+		}
+		l.codes = append(l.codes, addr.IP.String())
+	}
+	l.listed = len(l.codes) > 0
+
+	// reason is optional, most zones publish it with link to delisting form
+	if l.listed {
+		if txts, err := resolver.LookupTXT(ctx, name); err == nil && len(txts) > 0 {
+			l.reason = txts[0]
+		}
+	}
+	return l
+}
+
+// resolver using the given dns server, or system resolver if the server is empty
+func newResolver(server string) *net.Resolver {
+	if server == "" {
+		return net.DefaultResolver
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network string, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, server)
+		},
+	}
+}
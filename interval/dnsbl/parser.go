@@ -0,0 +1,55 @@
+package dnsbl
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/exmonitor/exclient/database"
+	"github.com/exmonitor/exclient/database/spec/service"
+	"github.com/exmonitor/exlogger"
+	"github.com/pkg/errors"
+)
+
+/*
+Example metadata:
+{
+	"id": 20,
+	"targets": ["203.0.113.5", "2001:db8::25", "mail.domain.cz"],
+	"zones": ["zen.spamhaus.org", "bl.spamcop.net", "b.barracudacentral.org"],
+	"resolver": "127.0.0.1:53",
+	"timeout": 5
+}
+*/
+
+type RawCheck struct {
+	Id       int      `json:"id"`
+	Targets  []string `json:"targets"`
+	Zones    []string `json:"zones"`
+	Resolver string   `json:"resolver"`
+	Timeout  int      `json:"timeout"`
+}
+
+func ParseCheck(service *service.Service, dbClient database.ClientInterface, logger *exlogger.Logger) (*Check, error) {
+	var rawCheck RawCheck
+	err := json.Unmarshal([]byte(service.Metadata), &rawCheck)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to parse DNSBL json metadata for check id %d", service.ID))
+	} else {
+		logger.LogDebug("Successfully parsed DNSBL json metadata for check id %d", service.ID)
+	}
+
+	checkConfig := CheckConfig{
+		Id:            service.ID,
+		FailThreshold: service.FailThreshold,
+		Interval:      service.Interval,
+		Targets:       rawCheck.Targets,
+		Zones:         rawCheck.Zones,
+		Resolver:      rawCheck.Resolver,
+		Timeout:       time.Second * time.Duration(rawCheck.Timeout),
+		Logger:        logger,
+		DBClient:      dbClient,
+	}
+
+	return NewCheck(checkConfig)
+}
@@ -0,0 +1,213 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/exmonitor/exclient/database"
+	"github.com/exmonitor/exlogger"
+	"github.com/pkg/errors"
+
+	"github.com/exmonitor/watcher/interval/proxy"
+	"github.com/exmonitor/watcher/interval/spec"
+	"github.com/exmonitor/watcher/interval/status"
+	"github.com/exmonitor/watcher/key"
+)
+
+const (
+	msgSuccess             = "success"
+	msgFailedLookup        = "failed to fetch registration data"
+	msgFailedNotRegistered = "failed - domain is not registered"
+	msgFailedNoExpiration  = "failed - registration data has no expiration date"
+	msgFailedExpiration    = "failed - domain registration expiration issue"
+	msgFailedNotLocked     = "failed - domain has no transfer lock"
+
+	// registries rate-limit lookups, so the data are cached heavily by default
+	defaultCacheTTL = time.Hour * 12
+)
+
+// registered domain name in ascii (punycode) form
+var domainRegexp = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]*[a-z0-9])?\.)+[a-z0-9-]{2,}$`)
+
+type CheckConfig struct {
+	Id                  int
+	FailThreshold       int
+	Interval            int
+	Domain              string
+	Timeout             time.Duration
+	ExpirationThreshold time.Duration // min time until registration expires
+	RequireTransferLock bool
+	CacheTTL            time.Duration // zero for default
+	Proxy               string        // proxy url, empty for global proxy
+
+	//db client
+	DBClient database.ClientInterface
+	Logger   *exlogger.Logger
+}
+
+type Check struct {
+	id                  int
+	failThreshold       int
+	interval            int
+	requestId           string
+	domain              string
+	timeout             time.Duration
+	expirationThreshold time.Duration
+	requireTransferLock bool
+	cacheTTL            time.Duration
+	dialer              *proxy.Dialer
+
+	// db client
+	dbClient database.ClientInterface
+	// logger
+	log *exlogger.Logger
+
+	// internals
+	spec.CheckInterface
+}
+
+func NewCheck(conf CheckConfig) (*Check, error) {
+	if conf.Id == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.id must not be zero")
+	}
+	if conf.FailThreshold == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.FailThreshold must not be zero")
+	}
+	if conf.Interval == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.Interval must not be zero")
+	}
+	conf.Domain = strings.TrimSuffix(strings.ToLower(conf.Domain), ".")
+	if !domainRegexp.MatchString(conf.Domain) {
+		return nil, errors.Wrapf(invalidConfigError, "conf.Domain %q is not valid domain name", conf.Domain)
+	}
+	if conf.Timeout == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.Timeout must not be zero")
+	}
+	if conf.ExpirationThreshold == 0 && !conf.RequireTransferLock {
+		return nil, errors.Wrap(invalidConfigError, "conf.ExpirationThreshold must not be zero, when transfer lock is not required")
+	}
+	if conf.DBClient == nil {
+		return nil, errors.Wrap(invalidConfigError, "conf.DBClient must not be nil")
+	}
+	if conf.Logger == nil {
+		return nil, errors.Wrap(invalidConfigError, "conf.Logger must not be nil")
+	}
+	if conf.CacheTTL == 0 {
+		conf.CacheTTL = defaultCacheTTL
+	}
+	dialer, err := proxy.New(conf.Proxy, conf.Timeout)
+	if err != nil {
+		return nil, errors.Wrap(err, "conf.Proxy is not valid")
+	}
+
+	newCheck := &Check{
+		id:                  conf.Id,
+		failThreshold:       conf.FailThreshold,
+		interval:            conf.Interval,
+		domain:              conf.Domain,
+		timeout:             conf.Timeout,
+		expirationThreshold: conf.ExpirationThreshold,
+		requireTransferLock: conf.RequireTransferLock,
+		cacheTTL:            conf.CacheTTL,
+		dialer:              dialer,
+
+		dbClient: conf.DBClient,
+		log:      conf.Logger,
+	}
+
+	return newCheck, nil
+}
+
+// wrapper function used to run in separate thread (goroutine)
+func (c *Check) RunCheck() {
+	// generate unique request ID
+	c.requestId = key.GenerateReqId(c.id)
+	// run monitoring check
+	s := c.doCheck()
+	c.LogResult(s)
+
+	// save result to database
+	s.SaveToDB()
+}
+
+// run monitoring check
+func (c *Check) doCheck() *status.Status {
+	statusConfig := status.Config{
+		Id:            c.id,
+		ReqId:         c.requestId,
+		Interval:      c.interval,
+		FailThreshold: c.failThreshold,
+		DBClient:      c.dbClient,
+	}
+	s, err := status.New(statusConfig)
+	if err != nil {
+		c.LogRunError(err, fmt.Sprintf("failed to init new status for DOMAIN service ID %d", c.id))
+	}
+	tStart := time.Now()
+	defer func() {
+		s.Duration = time.Since(tStart)
+	}()
+
+	r, cached, err := cachedLookup(c.domain, c.cacheTTL, newLookup(c.timeout, c.dialer).fetch)
+	if errors.Cause(err) == notFoundError {
+		s.Set(false, err, msgFailedNotRegistered)
+		return s
+	} else if err != nil {
+		s.Set(false, err, msgFailedLookup)
+		return s
+	}
+	details := formatRegistration(r, cached)
+
+	if c.expirationThreshold > 0 {
+		if r.Expiration.IsZero() {
+			s.Set(false, nil, fmt.Sprintf("%s, %s", msgFailedNoExpiration, details))
+			return s
+		}
+		if time.Now().Add(c.expirationThreshold).After(r.Expiration) {
+			s.Set(false, nil, fmt.Sprintf("%s, expires in less than %d days, %s", msgFailedExpiration, days(c.expirationThreshold), details))
+			return s
+		}
+	}
+	if c.requireTransferLock && !r.TransferLocked() {
+		s.Set(false, nil, fmt.Sprintf("%s, %s", msgFailedNotLocked, details))
+		return s
+	}
+
+	s.Set(true, nil, fmt.Sprintf("%s, %s", msgSuccess, details))
+	return s
+}
+
+// human readable summary of the registration
+func formatRegistration(r *Registration, cached bool) string {
+	msg := "expiration unknown"
+	if !r.Expiration.IsZero() {
+		msg = fmt.Sprintf("expires on %s in %d days", r.Expiration.Format("2006-01-02"), days(time.Until(r.Expiration)))
+	}
+	if r.Registrar != "" {
+		msg += ", registrar " + r.Registrar
+	}
+	msg += fmt.Sprintf(", transfer lock %t, source %s", r.TransferLocked(), r.Source)
+	if cached {
+		msg += " (cached)"
+	}
+	return msg
+}
+
+func days(d time.Duration) int {
+	return int(d / (time.Hour * 24))
+}
+
+func (c *Check) GetStringPort() string {
+	// domain has no port
+	return ""
+}
+
+func (c *Check) LogResult(s *status.Status) {
+	c.log.Log("check-DOMAIN|id %d|reqID %s|domain %s|proxy %s|latency %sms|result '%t'|msg: %s", c.id, c.requestId, c.domain, c.dialer, key.MsFromDuration(s.Duration), s.Result, s.Message)
+}
+
+func (c *Check) LogRunError(err error, message string) {
+	c.log.LogError(err, "running check id:%d reqID:%s type:domain domain:%s failed, reason: %s", c.id, c.requestId, c.domain, message)
+}
@@ -0,0 +1,9 @@
+package domain
+
+import "errors"
+
+var invalidConfigError error = errors.New("invalid check config")
+
+var notFoundError error = errors.New("domain not found")
+
+var noServerError error = errors.New("no registration data server for tld")
@@ -0,0 +1,132 @@
+package domain
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/exmonitor/watcher/interval/proxy"
+)
+
+const (
+	SourceRDAP  = "rdap"
+	SourceWHOIS = "whois"
+
+	// failed lookups are cached shortly, so temporary errors dont wait for the whole ttl
+	errorCacheTTL = time.Minute * 15
+)
+
+// registration data of the domain
+type Registration struct {
+	Expiration time.Time
+	Registrar  string
+	Status     []string // normalized EPP status codes, ie: clienttransferprohibited
+	Source     string
+}
+
+// returns true if the domain has registrar or registry transfer lock
+func (r *Registration) TransferLocked() bool {
+	for _, status := range r.Status {
+		if status == "clienttransferprohibited" || status == "servertransferprohibited" {
+			return true
+		}
+	}
+	return false
+}
+
+// normalize status from RDAP ("client transfer prohibited") or WHOIS ("clientTransferProhibited https://icann.org/epp#...")
+func normalizeStatus(status string) string {
+	status = strings.TrimSpace(status)
+	if i := strings.Index(status, "http"); i > 0 {
+		status = status[:i]
+	}
+	return strings.ToLower(strings.Join(strings.Fields(status), ""))
+}
+
+// registries rate-limit their services, so results are shared by all checks of the same domain
+// concurrent lookups of the same domain wait for the first one
+var cache = struct {
+	sync.Mutex
+	entries map[string]*cacheEntry
+}{
+	entries: make(map[string]*cacheEntry),
+}
+
+type cacheEntry struct {
+	ready        chan struct{}
+	registration *Registration
+	err          error
+	expires      time.Time
+}
+
+// returns registration from cache or fetches new one, second value is true for cached result
+func cachedLookup(domain string, ttl time.Duration, fetch func(string) (*Registration, error)) (*Registration, bool, error) {
+	cache.Lock()
+	if e, ok := cache.entries[domain]; ok {
+		select {
+		case <-e.ready:
+			if time.Now().Before(e.expires) {
+				cache.Unlock()
+				return e.registration, true, e.err
+			}
+		default:
+			// lookup in progress
+			cache.Unlock()
+			<-e.ready
+			return e.registration, true, e.err
+		}
+	}
+	e := &cacheEntry{ready: make(chan struct{})}
+	cache.entries[domain] = e
+	cache.Unlock()
+
+	e.registration, e.err = fetch(domain)
+	if e.err != nil && ttl > errorCacheTTL {
+		ttl = errorCacheTTL
+	}
+	e.expires = time.Now().Add(ttl)
+	close(e.ready)
+	return e.registration, false, e.err
+}
+
+// lookup client with check timeout and proxy
+type lookup struct {
+	timeout    time.Duration
+	dialer     *proxy.Dialer
+	httpClient *http.Client
+}
+
+func newLookup(timeout time.Duration, dialer *proxy.Dialer) *lookup {
+	return &lookup{
+		timeout: timeout,
+		dialer:  dialer,
+		httpClient: &http.Client{
+			Timeout:   timeout,
+			Transport: &http.Transport{DialContext: dialer.DialContext},
+		},
+	}
+}
+
+// query RDAP and fall back to WHOIS when RDAP is not available for the tld or fails
+func (l *lookup) fetch(domain string) (*Registration, error) {
+	registration, rdapErr := l.rdap(domain)
+	if rdapErr == nil || errors.Cause(rdapErr) == notFoundError {
+		return registration, rdapErr
+	}
+	registration, whoisErr := l.whois(domain)
+	if errors.Cause(whoisErr) == notFoundError {
+		return nil, whoisErr
+	} else if whoisErr != nil {
+		return nil, fmt.Errorf("rdap: %s, whois: %s", rdapErr, whoisErr)
+	}
+	return registration, nil
+}
+
+// returns top level domain of the domain name
+func tld(domain string) string {
+	return domain[strings.LastIndex(domain, ".")+1:]
+}
@@ -0,0 +1,189 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// IANA bootstrap registry of RDAP servers for each tld
+	rdapBootstrapURL = "https://data.iana.org/rdap/dns.json"
+	bootstrapTTL     = time.Hour * 24
+
+	rdapContentType = "application/rdap+json"
+	// max size of RDAP response
+	maxResponseSize = 1024 * 1024
+)
+
+// RDAP servers loaded from bootstrap registry, shared by all checks
+var bootstrap = struct {
+	sync.Mutex
+	servers map[string]string
+	expires time.Time
+}{}
+
+type bootstrapRegistry struct {
+	// list of [[tld, ...], [url, ...]] pairs
+	Services [][][]string `json:"services"`
+}
+
+type rdapDomain struct {
+	Status   []string     `json:"status"`
+	Events   []rdapEvent  `json:"events"`
+	Entities []rdapEntity `json:"entities"`
+}
+
+type rdapEvent struct {
+	Action string `json:"eventAction"`
+	Date   string `json:"eventDate"`
+}
+
+type rdapEntity struct {
+	Roles []string `json:"roles"`
+	// jCard: ["vcard", [["fn", {}, "text", "Registrar Name"], ...]]
+	VcardArray []interface{} `json:"vcardArray"`
+}
+
+// fetch registration data via RDAP
+func (l *lookup) rdap(domain string) (*Registration, error) {
+	server, err := l.rdapServer(tld(domain))
+	if err != nil {
+		return nil, err
+	}
+
+	var result rdapDomain
+	status, err := l.getJSON(server+"domain/"+domain, &result)
+	if status == http.StatusNotFound {
+		return nil, errors.Wrapf(notFoundError, "rdap server %s", server)
+	} else if err != nil {
+		return nil, err
+	}
+
+	registration := &Registration{Source: SourceRDAP}
+	for _, event := range result.Events {
+		if event.Action == "expiration" {
+			registration.Expiration, err = time.Parse(time.RFC3339, event.Date)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid expiration date %q", event.Date)
+			}
+		}
+	}
+	for _, status := range result.Status {
+		registration.Status = append(registration.Status, normalizeStatus(status))
+	}
+	for _, entity := range result.Entities {
+		if hasRole(entity.Roles, "registrar") {
+			registration.Registrar = vcardName(entity.VcardArray)
+		}
+	}
+	return registration, nil
+}
+
+// returns base url of RDAP server for the tld, always ending with slash
+func (l *lookup) rdapServer(tld string) (string, error) {
+	bootstrap.Lock()
+	defer bootstrap.Unlock()
+	if time.Now().After(bootstrap.expires) {
+		var registry bootstrapRegistry
+		if _, err := l.getJSON(rdapBootstrapURL, &registry); err != nil {
+			// keep using the old registry if there is any
+			if bootstrap.servers == nil {
+				return "", errors.Wrap(err, "failed to load rdap bootstrap registry")
+			}
+		} else {
+			bootstrap.servers = parseBootstrap(registry)
+		}
+		bootstrap.expires = time.Now().Add(bootstrapTTL)
+	}
+
+	server, ok := bootstrap.servers[strings.ToLower(tld)]
+	if !ok {
+		return "", errors.Wrapf(noServerError, "rdap server for tld %s", tld)
+	}
+	return server, nil
+}
+
+// map each tld to its first https RDAP server
+func parseBootstrap(registry bootstrapRegistry) map[string]string {
+	servers := make(map[string]string)
+	for _, service := range registry.Services {
+		if len(service) != 2 {
+			continue
+		}
+		for _, url := range service[1] {
+			if !strings.HasPrefix(url, "https://") {
+				continue
+			}
+			if !strings.HasSuffix(url, "/") {
+				url += "/"
+			}
+			for _, tld := range service[0] {
+				servers[strings.ToLower(tld)] = url
+			}
+			break
+		}
+	}
+	return servers
+}
+
+// get json document, returns http status code as well
+func (l *lookup) getJSON(url string, v interface{}) (int, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", rdapContentType+", application/json")
+	resp, err := l.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, fmt.Errorf("%s returned status %s", url, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return resp.StatusCode, errors.Wrapf(err, "invalid json from %s", url)
+	}
+	return resp.StatusCode, nil
+}
+
+func hasRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// returns formatted name (fn) from jCard
+func vcardName(vcard []interface{}) string {
+	if len(vcard) != 2 {
+		return ""
+	}
+	properties, ok := vcard[1].([]interface{})
+	if !ok {
+		return ""
+	}
+	for _, p := range properties {
+		property, ok := p.([]interface{})
+		if !ok || len(property) < 4 || property[0] != "fn" {
+			continue
+		}
+		if name, ok := property[3].(string); ok {
+			return name
+		}
+	}
+	return ""
+}
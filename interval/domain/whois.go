@@ -0,0 +1,224 @@
+package domain
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// IANA whois server refers to whois server of each tld
+	ianaWhoisServer = "whois.iana.org"
+	whoisPort       = "43"
+	whoisServerTTL  = time.Hour * 24
+)
+
+// fields with expiration date used by different registries, first match is used
+var expirationFields = []string{
+	"registry expiry date",
+	"registrar registration expiration date",
+	"expiration date",
+	"expiry date",
+	"expire date",
+	"expires on",
+	"expires",
+	"expire",
+	"paid-till",
+	"valid until",
+	"renewal date",
+}
+
+var registrarFields = []string{
+	"registrar",
+	"registrar name",
+	"sponsoring registrar",
+}
+
+var statusFields = []string{
+	"domain status",
+	"status",
+}
+
+// responses of registries for unregistered domains
+var notFoundPatterns = []string{
+	"no match for",
+	"not found",
+	"no entries found",
+	"no data found",
+	"no object found",
+	"status: free",
+	"status: available",
+}
+
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"02-Jan-2006",
+	"02.01.2006",
+	"2006.01.02",
+	"2006/01/02",
+	"02/01/2006",
+	"January 2 2006",
+}
+
+// whois servers of tlds loaded from IANA, shared by all checks
+var whoisServers = struct {
+	sync.Mutex
+	servers map[string]whoisServerEntry
+}{
+	servers: make(map[string]whoisServerEntry),
+}
+
+type whoisServerEntry struct {
+	server  string
+	expires time.Time
+}
+
+// fetch registration data via WHOIS
+func (l *lookup) whois(domain string) (*Registration, error) {
+	server, err := l.whoisServer(tld(domain))
+	if err != nil {
+		return nil, err
+	}
+	response, err := l.whoisQuery(server, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	registration := parseWhois(response)
+	if registration.Expiration.IsZero() && isNotFound(response) {
+		return nil, errors.Wrapf(notFoundError, "whois server %s", server)
+	}
+	return registration, nil
+}
+
+// returns whois server of the tld
+func (l *lookup) whoisServer(tld string) (string, error) {
+	tld = strings.ToLower(tld)
+	whoisServers.Lock()
+	entry, ok := whoisServers.servers[tld]
+	whoisServers.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.server, nil
+	}
+
+	response, err := l.whoisQuery(ianaWhoisServer, tld)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to query IANA whois")
+	}
+	server := whoisValue(response, []string{"whois", "refer"})
+	if server == "" {
+		return "", errors.Wrapf(noServerError, "whois server for tld %s", tld)
+	}
+
+	whoisServers.Lock()
+	whoisServers.servers[tld] = whoisServerEntry{server: server, expires: time.Now().Add(whoisServerTTL)}
+	whoisServers.Unlock()
+	return server, nil
+}
+
+// send query to the whois server and read whole response
+func (l *lookup) whoisQuery(server string, query string) (string, error) {
+	conn, err := l.dialer.Dial("tcp", net.JoinHostPort(server, whoisPort))
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(l.timeout))
+
+	if _, err := io.WriteString(conn, query+"\r\n"); err != nil {
+		return "", err
+	}
+	response, err := io.ReadAll(io.LimitReader(conn, maxResponseSize))
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read response from %s", server)
+	}
+	return string(response), nil
+}
+
+// parse free-form whois response
+func parseWhois(response string) *Registration {
+	registration := &Registration{
+		Source:    SourceWHOIS,
+		Registrar: whoisValue(response, registrarFields),
+	}
+	if value := whoisValue(response, expirationFields); value != "" {
+		registration.Expiration = parseDate(value)
+	}
+	for _, status := range whoisValues(response, statusFields) {
+		registration.Status = append(registration.Status, normalizeStatus(status))
+	}
+	return registration
+}
+
+// returns value of the first field found, fields are searched in the given order
+func whoisValue(response string, fields []string) string {
+	for _, field := range fields {
+		if values := whoisValues(response, []string{field}); len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+// returns all non-empty values of the fields
+func whoisValues(response string, fields []string) []string {
+	var values []string
+	scanner := bufio.NewScanner(strings.NewReader(response))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "%") || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.Index(line, ":")
+		if i < 0 {
+			continue
+		}
+		name := strings.ToLower(strings.TrimSpace(line[:i]))
+		value := strings.TrimSpace(line[i+1:])
+		if value == "" {
+			continue
+		}
+		for _, field := range fields {
+			if name == field {
+				values = append(values, value)
+				break
+			}
+		}
+	}
+	return values
+}
+
+// parse date in one of the formats used by registries, zero time if the format is unknown
+func parseDate(value string) time.Time {
+	candidates := []string{value}
+	// drop time zone names and times after the date
+	if fields := strings.Fields(value); len(fields) > 1 {
+		candidates = append(candidates, strings.Join(fields[:2], " "), fields[0])
+	}
+	for _, candidate := range candidates {
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, candidate); err == nil {
+				return t
+			}
+		}
+	}
+	return time.Time{}
+}
+
+func isNotFound(response string) bool {
+	response = strings.ToLower(response)
+	for _, pattern := range notFoundPatterns {
+		if strings.Contains(response, pattern) {
+			return true
+		}
+	}
+	return false
+}
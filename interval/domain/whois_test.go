@@ -0,0 +1,96 @@
+package domain
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{value: "2027-08-13T04:00:00Z", want: time.Date(2027, 8, 13, 4, 0, 0, 0, time.UTC)},
+		{value: "2027-08-13T04:00:00.0Z", want: time.Date(2027, 8, 13, 4, 0, 0, 0, time.UTC)},
+		{value: "2027-08-13T04:00:00", want: time.Date(2027, 8, 13, 4, 0, 0, 0, time.UTC)},
+		{value: "2027-08-13 04:00:00", want: time.Date(2027, 8, 13, 4, 0, 0, 0, time.UTC)},
+		{value: "2027-08-13 04:00:00 CLST", want: time.Date(2027, 8, 13, 4, 0, 0, 0, time.UTC)},
+		{value: "2027-08-13", want: time.Date(2027, 8, 13, 0, 0, 0, 0, time.UTC)},
+		{value: "13-Aug-2027", want: time.Date(2027, 8, 13, 0, 0, 0, 0, time.UTC)},
+		{value: "13.08.2027", want: time.Date(2027, 8, 13, 0, 0, 0, 0, time.UTC)},
+		{value: "2027.08.13 00:00:00", want: time.Date(2027, 8, 13, 0, 0, 0, 0, time.UTC)},
+		{value: "2027/08/13", want: time.Date(2027, 8, 13, 0, 0, 0, 0, time.UTC)},
+		{value: "13/08/2027", want: time.Date(2027, 8, 13, 0, 0, 0, 0, time.UTC)},
+		{value: "", want: time.Time{}},
+		{value: "never", want: time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := parseDate(tt.value); !got.Equal(tt.want) {
+				t.Errorf("parseDate(%q) = %s, want %s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseWhois(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     *Registration
+	}{
+		{
+			name: "gtld registry",
+			response: `   Domain Name: EXAMPLE.COM
+   Registry Domain ID: 2336799_DOMAIN_COM-VRSN
+   Registrar WHOIS Server: whois.example-registrar.com
+   Registrar URL: http://www.example-registrar.com
+   Updated Date: 2026-08-14T07:01:38Z
+   Creation Date: 1995-08-14T04:00:00Z
+   Registry Expiry Date: 2027-08-13T04:00:00Z
+   Registrar: Example Registrar, Inc.
+   Domain Status: clientDeleteProhibited https://icann.org/epp#clientDeleteProhibited
+   Domain Status: client Transfer Prohibited https://icann.org/epp#clientTransferProhibited
+>>> Last update of whois database: 2026-10-19T10:00:00Z <<<
+`,
+			want: &Registration{
+				Source:     SourceWHOIS,
+				Registrar:  "Example Registrar, Inc.",
+				Expiration: time.Date(2027, 8, 13, 4, 0, 0, 0, time.UTC),
+				Status:     []string{"clientdeleteprohibited", "clienttransferprohibited"},
+			},
+		},
+		{
+			name: "cctld registry with comments",
+			response: `%  (c) 2006-2026 CZ.NIC, z.s.p.o.
+% expire: 01.01.2000
+
+domain:       example.cz
+registrant:   EXAMPLE
+registrar:    REG-EXAMPLE
+registered:   15.03.2001 00:00:00
+expire:       16.03.2027
+status:       paid and in zone
+`,
+			want: &Registration{
+				Source:     SourceWHOIS,
+				Registrar:  "REG-EXAMPLE",
+				Expiration: time.Date(2027, 3, 16, 0, 0, 0, 0, time.UTC),
+				Status:     []string{"paidandinzone"},
+			},
+		},
+		{
+			name:     "no known fields",
+			response: "domain: example.test\nnserver: ns.example.test\n",
+			want:     &Registration{Source: SourceWHOIS},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseWhois(tt.response); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseWhois() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,388 @@
+package email
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/exmonitor/exclient/database"
+	"github.com/exmonitor/exlogger"
+	"github.com/pkg/errors"
+
+	"github.com/exmonitor/watcher/interval/secret"
+	"github.com/exmonitor/watcher/interval/smtp"
+	"github.com/exmonitor/watcher/interval/spec"
+	"github.com/exmonitor/watcher/interval/status"
+	"github.com/exmonitor/watcher/key"
+)
+
+const (
+	msgFailedToSend     = "failed - message was not accepted by smtp server"
+	msgFailedImap       = "failed - imap error"
+	msgFailedNotArrived = "failed - message was not delivered"
+
+	msgInternalFailedSecret = "INTERNAL: failed to load password"
+
+	defaultMailbox      = "INBOX"
+	defaultPollInterval = time.Second * 5
+	testMessageSubject  = "watcher email round-trip"
+)
+
+type CheckConfig struct {
+	Id              int
+	FailThreshold   int
+	Interval        int
+	Timeout         time.Duration // timeout of single network operation
+	DeliveryTimeout time.Duration // how long to wait for the message
+	PollInterval    time.Duration
+
+	MailFrom      string
+	RcptTo        string
+	TlsSkipVerify bool
+
+	// smtp server used for sending
+	SmtpTarget      string
+	SmtpPort        int
+	SmtpImplicitTls bool
+	SmtpStartTls    bool
+	SmtpUsername    string // auth is used only if username is set
+	SmtpPassword    string // password or secret reference
+
+	// imap server with the recipient mailbox
+	ImapTarget      string
+	ImapPort        int
+	ImapImplicitTls bool
+	ImapStartTls    bool
+	ImapUsername    string
+	ImapPassword    string // password or secret reference
+	ImapMailbox     string
+
+	//db client
+	DBClient database.ClientInterface
+	Logger   *exlogger.Logger
+}
+
+type Check struct {
+	id              int
+	failThreshold   int
+	interval        int
+	requestId       string
+	timeout         time.Duration
+	deliveryTimeout time.Duration
+	pollInterval    time.Duration
+
+	mailFrom      string
+	rcptTo        string
+	tlsSkipVerify bool
+
+	smtpTarget      string
+	smtpPort        int
+	smtpImplicitTls bool
+	smtpStartTls    bool
+	smtpUsername    string
+	smtpPassword    string
+
+	imapTarget      string
+	imapPort        int
+	imapImplicitTls bool
+	imapStartTls    bool
+	imapUsername    string
+	imapPassword    string
+	imapMailbox     string
+
+	// db client
+	dbClient database.ClientInterface
+	// logger
+	log *exlogger.Logger
+
+	// internals
+	spec.CheckInterface
+}
+
+func NewCheck(conf CheckConfig) (*Check, error) {
+	if conf.Id == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.id must not be zero")
+	}
+	if conf.FailThreshold == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.FailThreshold must not be zero")
+	}
+	if conf.Interval == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.Interval must not be zero")
+	}
+	if conf.Timeout == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.Timeout must not be zero")
+	}
+	if conf.DeliveryTimeout == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.DeliveryTimeout must not be zero")
+	}
+	// next run would send another message before this one is evaluated
+	if conf.DeliveryTimeout >= time.Duration(conf.Interval)*time.Second {
+		return nil, errors.Wrapf(invalidConfigError, "conf.DeliveryTimeout %s must be lower than check interval %ds", conf.DeliveryTimeout, conf.Interval)
+	}
+	if conf.MailFrom == "" || conf.RcptTo == "" {
+		return nil, errors.Wrap(invalidConfigError, "conf.MailFrom and conf.RcptTo must not be empty")
+	}
+	if conf.SmtpTarget == "" || conf.SmtpPort == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.SmtpTarget and conf.SmtpPort must be set")
+	}
+	if conf.SmtpImplicitTls && conf.SmtpStartTls {
+		return nil, errors.Wrap(invalidConfigError, "conf.SmtpImplicitTls and conf.SmtpStartTls cannot be enabled together")
+	}
+	if conf.SmtpUsername != "" && !conf.SmtpImplicitTls && !conf.SmtpStartTls {
+		return nil, errors.Wrap(invalidConfigError, "conf.SmtpImplicitTls or conf.SmtpStartTls must be enabled, when conf.SmtpUsername is set")
+	}
+	if conf.ImapTarget == "" || conf.ImapPort == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.ImapTarget and conf.ImapPort must be set")
+	}
+	if conf.ImapImplicitTls && conf.ImapStartTls {
+		return nil, errors.Wrap(invalidConfigError, "conf.ImapImplicitTls and conf.ImapStartTls cannot be enabled together")
+	}
+	if conf.ImapUsername == "" {
+		return nil, errors.Wrap(invalidConfigError, "conf.ImapUsername must not be empty")
+	}
+	if !conf.ImapImplicitTls && !conf.ImapStartTls {
+		return nil, errors.Wrap(invalidConfigError, "conf.ImapImplicitTls or conf.ImapStartTls must be enabled, login is always used")
+	}
+	if conf.DBClient == nil {
+		return nil, errors.Wrap(invalidConfigError, "conf.DBClient must not be nil")
+	}
+	if conf.Logger == nil {
+		return nil, errors.Wrap(invalidConfigError, "conf.Logger must not be nil")
+	}
+	if conf.PollInterval == 0 {
+		conf.PollInterval = defaultPollInterval
+	}
+	if conf.ImapMailbox == "" {
+		conf.ImapMailbox = defaultMailbox
+	}
+
+	newCheck := &Check{
+		id:              conf.Id,
+		failThreshold:   conf.FailThreshold,
+		interval:        conf.Interval,
+		timeout:         conf.Timeout,
+		deliveryTimeout: conf.DeliveryTimeout,
+		pollInterval:    conf.PollInterval,
+
+		mailFrom:      conf.MailFrom,
+		rcptTo:        conf.RcptTo,
+		tlsSkipVerify: conf.TlsSkipVerify,
+
+		smtpTarget:      conf.SmtpTarget,
+		smtpPort:        conf.SmtpPort,
+		smtpImplicitTls: conf.SmtpImplicitTls,
+		smtpStartTls:    conf.SmtpStartTls,
+		smtpUsername:    conf.SmtpUsername,
+		smtpPassword:    conf.SmtpPassword,
+
+		imapTarget:      conf.ImapTarget,
+		imapPort:        conf.ImapPort,
+		imapImplicitTls: conf.ImapImplicitTls,
+		imapStartTls:    conf.ImapStartTls,
+		imapUsername:    conf.ImapUsername,
+		imapPassword:    conf.ImapPassword,
+		imapMailbox:     conf.ImapMailbox,
+
+		dbClient: conf.DBClient,
+		log:      conf.Logger,
+	}
+
+	return newCheck, nil
+}
+
+// wrapper function used to run in separate thread (goroutine)
+func (c *Check) RunCheck() {
+	// generate unique request ID, its used as tag of the test message
+	c.requestId = key.GenerateReqId(c.id)
+	// run monitoring check
+	s := c.doCheck()
+	c.LogResult(s)
+
+	// save result to database
+	s.SaveToDB()
+}
+
+func (c *Check) doCheck() *status.Status {
+	statusConfig := status.Config{
+		Id:            c.id,
+		ReqId:         c.requestId,
+		Interval:      c.interval,
+		FailThreshold: c.failThreshold,
+		DBClient:      c.dbClient,
+	}
+	s, err := status.New(statusConfig)
+	if err != nil {
+		c.LogRunError(err, fmt.Sprintf("failed to init new status for EMAIL service ID %d", c.id))
+	}
+
+	smtpPassword, err := secret.Value(c.smtpPassword)
+	if err != nil {
+		c.LogRunError(err, msgInternalFailedSecret)
+		s.Set(false, err, msgInternalFailedSecret)
+		return s
+	}
+	imapPassword, err := secret.Value(c.imapPassword)
+	if err != nil {
+		c.LogRunError(err, msgInternalFailedSecret)
+		s.Set(false, err, msgInternalFailedSecret)
+		return s
+	}
+
+	// login to the mailbox first, so we dont send message which we cannot receive
+	imap, err := c.openMailbox(imapPassword)
+	if err != nil {
+		s.Set(false, err, msgFailedImap)
+		return s
+	}
+	defer imap.logout()
+
+	tSent := time.Now()
+	if err := c.send(smtpPassword); err != nil {
+		s.Set(false, err, msgFailedToSend)
+		s.Duration = time.Since(tSent)
+		return s
+	}
+
+	// wait for the message, last search is done at the deadline
+	deadline := tSent.Add(c.deliveryTimeout)
+	var uids []string
+	for {
+		uids, err = imap.searchHeader(smtp.HeaderRequestId, c.messageTag())
+		if err != nil {
+			s.Set(false, err, msgFailedImap)
+			s.Duration = time.Since(tSent)
+			return s
+		}
+		if len(uids) > 0 {
+			s.Duration = time.Since(tSent)
+			break
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			s.Duration = time.Since(tSent)
+			s.Set(false, nil, fmt.Sprintf("%s within %.0fs", msgFailedNotArrived, c.deliveryTimeout.Seconds()))
+			return s
+		}
+		if remaining > c.pollInterval {
+			remaining = c.pollInterval
+		}
+		time.Sleep(remaining)
+	}
+
+	// delete this message and messages of this check which arrived too late in previous runs,
+	// runs do not overlap as delivery timeout is lower than the interval, so messages of other runs are stale
+	all, err := imap.searchHeader(smtp.HeaderRequestId, c.tagPrefix())
+	if err != nil {
+		c.LogRunError(err, "failed to search stale test messages")
+		all = uids
+	}
+	if err := imap.delete(all); err != nil {
+		c.LogRunError(err, "failed to delete test messages")
+	}
+
+	s.Set(true, nil, fmt.Sprintf("success, delivered in %sms", key.MsFromDuration(s.Duration)))
+	return s
+}
+
+// send tagged test message via smtp server
+func (c *Check) send(password string) error {
+	tlsConfig := &tls.Config{
+		ServerName:         c.smtpTarget,
+		InsecureSkipVerify: c.tlsSkipVerify,
+	}
+	var implicitTlsConfig *tls.Config
+	if c.smtpImplicitTls {
+		implicitTlsConfig = tlsConfig
+	}
+
+	session, err := smtp.Dial(net.JoinHostPort(c.smtpTarget, strconv.Itoa(c.smtpPort)), c.timeout, implicitTlsConfig)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	hostname := smtp.HelloName()
+	if err := session.Hello(hostname); err != nil {
+		return err
+	}
+	if c.smtpStartTls {
+		if err := session.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+		if err := session.Hello(hostname); err != nil {
+			return err
+		}
+	}
+	if c.smtpUsername != "" {
+		if err := session.Auth(c.smtpUsername, password); err != nil {
+			return err
+		}
+	}
+	if err := session.Mail(c.mailFrom); err != nil {
+		return err
+	}
+	if err := session.Rcpt(c.rcptTo); err != nil {
+		return err
+	}
+	if err := session.Data(smtp.NewMessage(c.mailFrom, c.rcptTo, testMessageSubject, c.messageTag())); err != nil {
+		return err
+	}
+	return session.Quit()
+}
+
+// login to imap server and select the mailbox
+func (c *Check) openMailbox(password string) (*imapSession, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         c.imapTarget,
+		InsecureSkipVerify: c.tlsSkipVerify,
+	}
+	var implicitTlsConfig *tls.Config
+	if c.imapImplicitTls {
+		implicitTlsConfig = tlsConfig
+	}
+
+	imap, err := dialIMAP(net.JoinHostPort(c.imapTarget, strconv.Itoa(c.imapPort)), c.timeout, implicitTlsConfig)
+	if err != nil {
+		return nil, err
+	}
+	if c.imapStartTls {
+		err = imap.startTLS(tlsConfig)
+	}
+	if err == nil {
+		err = imap.login(c.imapUsername, password)
+	}
+	if err == nil {
+		err = imap.selectMailbox(c.imapMailbox)
+	}
+	if err != nil {
+		imap.close()
+		return nil, err
+	}
+
+	return imap, nil
+}
+
+func (c *Check) GetStringPort() string {
+	return fmt.Sprintf(":%d", c.smtpPort)
+}
+
+// prefix of tags of all messages sent by this check, the dash keeps check 1 from matching messages of check 11
+// as IMAP header search matches substrings
+func (c *Check) tagPrefix() string {
+	return fmt.Sprintf("watcher-%d-", c.id)
+}
+
+// unique tag of the message sent in this run
+func (c *Check) messageTag() string {
+	return c.tagPrefix() + c.requestId
+}
+
+func (c *Check) LogResult(s *status.Status) {
+	c.log.Log("check-EMAIL|id %d|reqID %s|smtp %s:%d|imap %s:%d|latency %sms|result '%t'|msg: %s", c.id, c.requestId, c.smtpTarget, c.smtpPort, c.imapTarget, c.imapPort, key.MsFromDuration(s.Duration), s.Result, s.Message)
+}
+
+func (c *Check) LogRunError(err error, message string) {
+	c.log.LogError(err, "running check id:%d reqID:%s type:email smtp:%s imap:%s failed, reason: %s", c.id, c.requestId, c.smtpTarget, c.imapTarget, message)
+}
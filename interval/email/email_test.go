@@ -0,0 +1,344 @@
+package email
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"net/textproto"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/exmonitor/exclient/database/dummydb"
+	"github.com/exmonitor/exlogger"
+
+	"github.com/exmonitor/watcher/interval/smtp"
+)
+
+const (
+	testUsername = "watcher"
+	testPassword = "secret \"pass\""
+)
+
+// messages shared by the smtp and imap stand-ins
+type mailbox struct {
+	sync.Mutex
+	deliver  bool // smtp stand-in accepts messages but drops them if false
+	nextUid  int
+	messages map[int]string
+	deleted  map[int]bool
+}
+
+func newMailbox(deliver bool) *mailbox {
+	return &mailbox{deliver: deliver, nextUid: 1, messages: map[int]string{}, deleted: map[int]bool{}}
+}
+
+func (m *mailbox) add(message string) {
+	m.Lock()
+	defer m.Unlock()
+	m.messages[m.nextUid] = message
+	m.nextUid++
+}
+
+// uids of messages with the header containing the value
+func (m *mailbox) search(header string, value string) []string {
+	m.Lock()
+	defer m.Unlock()
+	var uids []string
+	for uid := 1; uid < m.nextUid; uid++ {
+		message, ok := m.messages[uid]
+		if !ok {
+			continue
+		}
+		for _, line := range strings.Split(message, "\r\n") {
+			if strings.HasPrefix(strings.ToLower(line), strings.ToLower(header)+":") && strings.Contains(line[len(header)+1:], value) {
+				uids = append(uids, strconv.Itoa(uid))
+				break
+			}
+		}
+	}
+	return uids
+}
+
+func (m *mailbox) tags() []string {
+	m.Lock()
+	defer m.Unlock()
+	var tags []string
+	for _, message := range m.messages {
+		for _, line := range strings.Split(message, "\r\n") {
+			if strings.HasPrefix(line, smtp.HeaderRequestId+": ") {
+				tags = append(tags, strings.TrimPrefix(line, smtp.HeaderRequestId+": "))
+			}
+		}
+	}
+	return tags
+}
+
+func selfSignedConfig(t *testing.T) *tls.Config {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "stand-in"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+}
+
+// accept connections with implicit tls until the test ends, returns the port
+func serve(t *testing.T, tlsConfig *tls.Config, handle func(text *textproto.Conn)) int {
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", tlsConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				text := textproto.NewConn(conn)
+				defer text.Close()
+				handle(text)
+			}()
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+// minimal smtp server with AUTH PLAIN
+func smtpStandIn(box *mailbox) func(text *textproto.Conn) {
+	return func(text *textproto.Conn) {
+		text.PrintfLine("220 stand-in ESMTP")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			command := strings.ToUpper(strings.Fields(line + " ")[0])
+			switch command {
+			case "EHLO":
+				text.PrintfLine("250-stand-in")
+				text.PrintfLine("250 AUTH PLAIN")
+			case "AUTH":
+				text.PrintfLine("235 authenticated")
+			case "MAIL", "RCPT":
+				text.PrintfLine("250 ok")
+			case "DATA":
+				text.PrintfLine("354 go ahead")
+				message, err := text.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				if box.deliver {
+					box.add(strings.Replace(string(message), "\n", "\r\n", -1))
+				}
+				text.PrintfLine("250 queued")
+			case "QUIT":
+				text.PrintfLine("221 bye")
+				return
+			default:
+				text.PrintfLine("502 not implemented")
+			}
+		}
+	}
+}
+
+var quotedArg = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"`)
+
+func unquote(value string) string {
+	value = strings.Replace(value, "\\\"", "\"", -1)
+	return strings.Replace(value, "\\\\", "\\", -1)
+}
+
+// minimal imap server supporting commands used by the check
+func imapStandIn(box *mailbox) func(text *textproto.Conn) {
+	return func(text *textproto.Conn) {
+		text.PrintfLine("* OK stand-in ready")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			fields := strings.SplitN(line, " ", 2)
+			if len(fields) != 2 {
+				return
+			}
+			tag, command := fields[0], fields[1]
+			args := quotedArg.FindAllStringSubmatch(command, -1)
+			switch {
+			case strings.HasPrefix(command, "LOGIN "):
+				if len(args) != 2 || unquote(args[0][1]) != testUsername || unquote(args[1][1]) != testPassword {
+					text.PrintfLine("%s NO invalid credentials", tag)
+					continue
+				}
+			case strings.HasPrefix(command, "UID SEARCH HEADER "):
+				uids := box.search(unquote(args[0][1]), unquote(args[1][1]))
+				text.PrintfLine("* SEARCH %s", strings.Join(uids, " "))
+			case strings.HasPrefix(command, "UID STORE "):
+				box.Lock()
+				for _, uid := range strings.Split(strings.Fields(command)[2], ",") {
+					n, _ := strconv.Atoi(uid)
+					box.deleted[n] = true
+				}
+				box.Unlock()
+			case command == "EXPUNGE":
+				box.Lock()
+				for uid := range box.deleted {
+					delete(box.messages, uid)
+				}
+				box.deleted = map[int]bool{}
+				box.Unlock()
+			case command == "LOGOUT":
+				text.PrintfLine("* BYE")
+				text.PrintfLine("%s OK LOGOUT completed", tag)
+				return
+			case strings.HasPrefix(command, "SELECT "), command == "NOOP":
+			default:
+				text.PrintfLine("%s BAD unknown command", tag)
+				continue
+			}
+			text.PrintfLine("%s OK completed", tag)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	logger, err := exlogger.New(exlogger.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := dummydb.GetClient(dummydb.Config{Logger: logger})
+
+	tests := []struct {
+		name          string
+		deliver       bool
+		imapPassword  string
+		stale         []string // tags of messages already in the mailbox
+		wantResult    bool
+		wantMessage   string
+		wantRemaining []string
+	}{
+		{
+			name:        "delivered",
+			deliver:     true,
+			wantResult:  true,
+			wantMessage: "success, delivered in",
+		},
+		{
+			name:          "stale messages of this check are deleted",
+			deliver:       true,
+			stale:         []string{"watcher-1-old", "watcher-11-other"},
+			wantResult:    true,
+			wantMessage:   "success, delivered in",
+			wantRemaining: []string{"watcher-11-other"},
+		},
+		{
+			name:        "not delivered",
+			deliver:     false,
+			wantMessage: msgFailedNotArrived + " within 0s",
+		},
+		{
+			name:         "imap login rejected",
+			deliver:      true,
+			imapPassword: "wrong",
+			wantMessage:  msgFailedImap + ", error:LOGIN: NO invalid credentials",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			box := newMailbox(tt.deliver)
+			for _, tag := range tt.stale {
+				box.add(string(smtp.NewMessage("from@example.com", "to@example.com", "stale", tag)))
+			}
+			tlsConfig := selfSignedConfig(t)
+			imapPassword := tt.imapPassword
+			if imapPassword == "" {
+				imapPassword = testPassword
+			}
+
+			check, err := NewCheck(CheckConfig{
+				Id:              1,
+				FailThreshold:   1,
+				Interval:        60,
+				Timeout:         time.Second,
+				DeliveryTimeout: time.Millisecond * 200,
+				PollInterval:    time.Millisecond * 50,
+				MailFrom:        "from@example.com",
+				RcptTo:          "to@example.com",
+				TlsSkipVerify:   true,
+				SmtpTarget:      "127.0.0.1",
+				SmtpPort:        serve(t, tlsConfig, smtpStandIn(box)),
+				SmtpImplicitTls: true,
+				SmtpUsername:    testUsername,
+				SmtpPassword:    testPassword,
+				ImapTarget:      "127.0.0.1",
+				ImapPort:        serve(t, tlsConfig, imapStandIn(box)),
+				ImapImplicitTls: true,
+				ImapUsername:    testUsername,
+				ImapPassword:    imapPassword,
+				DBClient:        db,
+				Logger:          logger,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			check.requestId = fmt.Sprintf("req-%d", time.Now().UnixNano())
+
+			s := check.doCheck()
+			if s.Result != tt.wantResult || !strings.HasPrefix(s.Message, tt.wantMessage) {
+				t.Fatalf("doCheck() = %t %q, want %t %q", s.Result, s.Message, tt.wantResult, tt.wantMessage)
+			}
+			if tt.wantResult {
+				if remaining := box.tags(); fmt.Sprint(remaining) != fmt.Sprint(tt.wantRemaining) {
+					t.Errorf("messages left in mailbox %v, want %v", remaining, tt.wantRemaining)
+				}
+			}
+		})
+	}
+}
+
+func TestNewCheckRequiresImapTls(t *testing.T) {
+	logger, err := exlogger.New(exlogger.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewCheck(CheckConfig{
+		Id:              1,
+		FailThreshold:   1,
+		Interval:        60,
+		Timeout:         time.Second,
+		DeliveryTimeout: time.Second,
+		MailFrom:        "from@example.com",
+		RcptTo:          "to@example.com",
+		SmtpTarget:      "127.0.0.1",
+		SmtpPort:        25,
+		ImapTarget:      "127.0.0.1",
+		ImapPort:        143,
+		ImapUsername:    testUsername,
+		DBClient:        dummydb.GetClient(dummydb.Config{Logger: logger}),
+		Logger:          logger,
+	})
+	if err == nil {
+		t.Fatal("NewCheck() accepted imap without tls")
+	}
+}
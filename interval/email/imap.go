@@ -0,0 +1,153 @@
+package email
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// minimal IMAP4rev1 client (RFC 3501), supports only commands needed for finding and deleting test messages
+type imapSession struct {
+	conn    net.Conn
+	text    *textproto.Conn
+	timeout time.Duration
+	tag     int
+}
+
+// open session and read greeting, tlsConfig enables implicit tls (ie: port 993)
+func dialIMAP(addr string, timeout time.Duration, tlsConfig *tls.Config) (*imapSession, error) {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+	if tlsConfig != nil {
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, errors.Wrap(err, "tls handshake")
+		}
+		conn = tlsConn
+	}
+
+	s := &imapSession{conn: conn, text: textproto.NewConn(conn), timeout: timeout}
+	greeting, err := s.text.ReadLine()
+	if err != nil {
+		s.close()
+		return nil, errors.Wrap(err, "greeting")
+	}
+	if !strings.HasPrefix(greeting, "* OK") {
+		s.close()
+		return nil, fmt.Errorf("greeting: unexpected response %q", greeting)
+	}
+
+	return s, nil
+}
+
+func (s *imapSession) startTLS(tlsConfig *tls.Config) error {
+	if _, err := s.command("STARTTLS"); err != nil {
+		return err
+	}
+	tlsConn := tls.Client(s.conn, tlsConfig)
+	if err := tlsConn.Handshake(); err != nil {
+		return errors.Wrap(err, "tls handshake")
+	}
+	s.conn = tlsConn
+	s.text = textproto.NewConn(tlsConn)
+	return nil
+}
+
+// credentials are sent only over tls (implicit or after STARTTLS)
+func (s *imapSession) login(username string, password string) error {
+	if _, ok := s.conn.(*tls.Conn); !ok {
+		return errors.New("LOGIN: refusing to send credentials over unencrypted connection")
+	}
+	_, err := s.command("LOGIN %s %s", quote(username), quote(password))
+	return err
+}
+
+func (s *imapSession) selectMailbox(mailbox string) error {
+	_, err := s.command("SELECT %s", quote(mailbox))
+	return err
+}
+
+// returns uids of messages which have the header with the value, empty value matches all messages with the header
+func (s *imapSession) searchHeader(header string, value string) ([]string, error) {
+	// let the server notice new messages
+	if _, err := s.command("NOOP"); err != nil {
+		return nil, err
+	}
+	lines, err := s.command("UID SEARCH HEADER %s %s", quote(header), quote(value))
+	if err != nil {
+		return nil, err
+	}
+	var uids []string
+	for _, line := range lines {
+		if strings.HasPrefix(line, "* SEARCH") {
+			uids = append(uids, strings.Fields(strings.TrimPrefix(line, "* SEARCH"))...)
+		}
+	}
+	return uids, nil
+}
+
+// mark messages as deleted and expunge them
+func (s *imapSession) delete(uids []string) error {
+	if len(uids) == 0 {
+		return nil
+	}
+	if _, err := s.command("UID STORE %s +FLAGS.SILENT (\\Deleted)", strings.Join(uids, ",")); err != nil {
+		return err
+	}
+	_, err := s.command("EXPUNGE")
+	return err
+}
+
+func (s *imapSession) logout() {
+	s.command("LOGOUT")
+	s.close()
+}
+
+func (s *imapSession) close() error {
+	return s.text.Close()
+}
+
+// send tagged command and return untagged responses, error is returned if the command doesnt end with OK
+func (s *imapSession) command(format string, args ...interface{}) ([]string, error) {
+	s.tag++
+	tag := fmt.Sprintf("a%03d", s.tag)
+	command := fmt.Sprintf(format, args...)
+	name := strings.Fields(command)[0]
+
+	s.conn.SetDeadline(time.Now().Add(s.timeout))
+	if err := s.text.PrintfLine("%s %s", tag, command); err != nil {
+		return nil, errors.Wrap(err, name)
+	}
+	var untagged []string
+	for {
+		line, err := s.text.ReadLine()
+		if err != nil {
+			return nil, errors.Wrap(err, name)
+		}
+		if !strings.HasPrefix(line, tag+" ") {
+			untagged = append(untagged, line)
+			continue
+		}
+		result := strings.TrimPrefix(line, tag+" ")
+		if !strings.HasPrefix(result, "OK") {
+			return nil, fmt.Errorf("%s: %s", name, result)
+		}
+		return untagged, nil
+	}
+}
+
+// quote string for use in IMAP command
+func quote(value string) string {
+	value = strings.Replace(value, "\\", "\\\\", -1)
+	value = strings.Replace(value, "\"", "\\\"", -1)
+	return "\"" + value + "\""
+}
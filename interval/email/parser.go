@@ -0,0 +1,100 @@
+package email
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/exmonitor/exclient/database"
+	"github.com/exmonitor/exclient/database/spec/service"
+	"github.com/exmonitor/exlogger"
+	"github.com/pkg/errors"
+)
+
+/*
+Example metadata:
+{
+	"id": 6,
+	"timeout": 10,
+	"deliveryTimeout": 120,
+	"pollInterval": 5,
+	"mailFrom": "monitoring@domain.cz",
+	"rcptTo": "roundtrip@domain.cz",
+	"tlsSkipVerify": false,
+	"smtpTarget": "smtp.domain.cz",
+	"smtpPort": 587,
+	"smtpImplicitTls": false,
+	"smtpStartTls": true,
+	"smtpUsername": "monitoring@domain.cz",
+	"smtpPassword": "secret:smtp-password",
+	"imapTarget": "imap.domain.cz",
+	"imapPort": 993,
+	"imapImplicitTls": true,
+	"imapStartTls": false,
+	"imapUsername": "roundtrip@domain.cz",
+	"imapPassword": "env:IMAP_PASSWORD",
+	"imapMailbox": "INBOX"
+}
+*/
+
+type RawCheck struct {
+	Id              int    `json:"id"`
+	Timeout         int    `json:"timeout"`
+	DeliveryTimeout int    `json:"deliveryTimeout"`
+	PollInterval    int    `json:"pollInterval"`
+	MailFrom        string `json:"mailFrom"`
+	RcptTo          string `json:"rcptTo"`
+	TlsSkipVerify   bool   `json:"tlsSkipVerify"`
+	SmtpTarget      string `json:"smtpTarget"`
+	SmtpPort        int    `json:"smtpPort"`
+	SmtpImplicitTls bool   `json:"smtpImplicitTls"`
+	SmtpStartTls    bool   `json:"smtpStartTls"`
+	SmtpUsername    string `json:"smtpUsername"`
+	SmtpPassword    string `json:"smtpPassword"`
+	ImapTarget      string `json:"imapTarget"`
+	ImapPort        int    `json:"imapPort"`
+	ImapImplicitTls bool   `json:"imapImplicitTls"`
+	ImapStartTls    bool   `json:"imapStartTls"`
+	ImapUsername    string `json:"imapUsername"`
+	ImapPassword    string `json:"imapPassword"`
+	ImapMailbox     string `json:"imapMailbox"`
+}
+
+func ParseCheck(service *service.Service, dbClient database.ClientInterface, logger *exlogger.Logger) (*Check, error) {
+	var rawCheck RawCheck
+	err := json.Unmarshal([]byte(service.Metadata), &rawCheck)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to parse EMAIL json metadata for check id %d", service.ID))
+	} else {
+		logger.LogDebug("Successfully parsed EMAIL json metadata for check id %d", service.ID)
+	}
+
+	checkConfig := CheckConfig{
+		Id:              service.ID,
+		FailThreshold:   service.FailThreshold,
+		Interval:        service.Interval,
+		Timeout:         time.Second * time.Duration(rawCheck.Timeout),
+		DeliveryTimeout: time.Second * time.Duration(rawCheck.DeliveryTimeout),
+		PollInterval:    time.Second * time.Duration(rawCheck.PollInterval),
+		MailFrom:        rawCheck.MailFrom,
+		RcptTo:          rawCheck.RcptTo,
+		TlsSkipVerify:   rawCheck.TlsSkipVerify,
+		SmtpTarget:      rawCheck.SmtpTarget,
+		SmtpPort:        rawCheck.SmtpPort,
+		SmtpImplicitTls: rawCheck.SmtpImplicitTls,
+		SmtpStartTls:    rawCheck.SmtpStartTls,
+		SmtpUsername:    rawCheck.SmtpUsername,
+		SmtpPassword:    rawCheck.SmtpPassword,
+		ImapTarget:      rawCheck.ImapTarget,
+		ImapPort:        rawCheck.ImapPort,
+		ImapImplicitTls: rawCheck.ImapImplicitTls,
+		ImapStartTls:    rawCheck.ImapStartTls,
+		ImapUsername:    rawCheck.ImapUsername,
+		ImapPassword:    rawCheck.ImapPassword,
+		ImapMailbox:     rawCheck.ImapMailbox,
+		Logger:          logger,
+		DBClient:        dbClient,
+	}
+
+	return NewCheck(checkConfig)
+}
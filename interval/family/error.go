@@ -0,0 +1,5 @@
+package family
+
+import "errors"
+
+var invalidFamilyError error = errors.New("invalid address family")
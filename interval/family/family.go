@@ -0,0 +1,83 @@
+package family
+
+import (
+	"net"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/exmonitor/watcher/interval/status"
+)
+
+// address family options used in check metadata
+const (
+	Any  = ""     // let the resolver decide
+	IPv4 = "ipv4" // use only IPv4 addresses
+	IPv6 = "ipv6" // use only IPv6 addresses
+	Both = "both" // check IPv4 and IPv6 separately
+)
+
+// check if the address family option is supported
+func Validate(family string) error {
+	switch family {
+	case Any, IPv4, IPv6, Both:
+		return nil
+	}
+	return errors.Wrapf(invalidFamilyError, "address family %s is not supported", family)
+}
+
+// returns list of families which should be checked separately
+func Split(family string) []string {
+	if family == Both {
+		return []string{IPv4, IPv6}
+	}
+	return []string{family}
+}
+
+// returns network restricted to the address family, ie: tcp -> tcp4
+func Network(network string, family string) string {
+	switch family {
+	case IPv4:
+		return network + "4"
+	case IPv6:
+		return network + "6"
+	}
+	return network
+}
+
+// resolve host into single ip address of the requested family
+func ResolveIPAddr(host string, family string) (*net.IPAddr, error) {
+	ip, err := net.ResolveIPAddr(Network("ip", family), host)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to resolve %s address for %s", familyName(family), host)
+	}
+	return ip, nil
+}
+
+func familyName(family string) string {
+	if family == Any {
+		return "ip"
+	}
+	return family
+}
+
+// run check for each address family separately (in parallel) and combine results into single status
+func Run(family string, check func(family string) *status.Status) *status.Status {
+	families := Split(family)
+	if len(families) == 1 {
+		return check(families[0])
+	}
+
+	partials := make([]*status.Status, len(families))
+	var wg sync.WaitGroup
+	for i, f := range families {
+		wg.Add(1)
+		go func(i int, f string) {
+			defer wg.Done()
+			partials[i] = check(f)
+		}(i, f)
+	}
+	wg.Wait()
+
+	return status.Combine(families, partials)
+}
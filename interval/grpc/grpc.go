@@ -0,0 +1,277 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/exmonitor/exclient/database"
+	"github.com/exmonitor/exlogger"
+	"github.com/pkg/errors"
+
+	"github.com/exmonitor/watcher/interval/certs"
+	"github.com/exmonitor/watcher/interval/secret"
+	"github.com/exmonitor/watcher/interval/spec"
+	"github.com/exmonitor/watcher/interval/status"
+	"github.com/exmonitor/watcher/key"
+)
+
+const (
+	msgSuccess                   = "success"
+	msgFailedCall                = "failed - health check call"
+	msgFailedStatus              = "failed - health check returned error"
+	msgFailedNotServing          = "failed - service is not serving"
+	msgFailedCertExpired         = "failed - tls client certificate expired"
+	msgInternalFailedTLSMaterial = "INTERNAL: failed to load tls client certificate or CA bundle"
+	msgInternalFailedSecret      = "INTERNAL: failed to load metadata value"
+)
+
+type CheckConfig struct {
+	Id            int
+	FailThreshold int
+	Interval      int
+	Target        string
+	Port          int
+	Timeout       time.Duration
+
+	// service name in health check request, empty for overall server health
+	Service string
+	// custom metadata sent as headers, values can be secret references
+	Metadata []MetadataValue
+
+	// tls, h2c (plaintext HTTP/2) is used when disabled
+	Tls           bool
+	TlsSkipVerify bool
+	TlsServerName string
+	TlsClientCert string // secret reference or path to PEM client certificate
+	TlsClientKey  string // secret reference or path to PEM client key
+	TlsCaBundle   string // secret reference or path to PEM CA bundle
+
+	//db client
+	DBClient database.ClientInterface
+	Logger   *exlogger.Logger
+}
+
+type Check struct {
+	id            int
+	failThreshold int
+	interval      int
+	requestId     string
+	target        string
+	port          int
+	timeout       time.Duration
+
+	service  string
+	metadata []MetadataValue
+
+	tls           bool
+	tlsSkipVerify bool
+	tlsServerName string
+	tlsMaterial   certs.Config
+
+	// db client
+	dbClient database.ClientInterface
+	// logger
+	log *exlogger.Logger
+
+	// internals
+	spec.CheckInterface
+}
+
+type MetadataValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func NewCheck(conf CheckConfig) (*Check, error) {
+	if conf.Id == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.id must not be zero")
+	}
+	if conf.FailThreshold == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.FailThreshold must not be zero")
+	}
+	if conf.Interval == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.Interval must not be zero")
+	}
+	if conf.Target == "" {
+		return nil, errors.Wrap(invalidConfigError, "conf.Target must not be empty")
+	}
+	if conf.Port == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.Port must not be zero")
+	}
+	if conf.Timeout == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.Timeout must not be zero")
+	}
+	tlsMaterial := certs.Config{
+		ClientCert: conf.TlsClientCert,
+		ClientKey:  conf.TlsClientKey,
+		CABundle:   conf.TlsCaBundle,
+	}
+	if tlsMaterial.Enabled() && !conf.Tls {
+		return nil, errors.Wrap(invalidConfigError, "conf.Tls must be enabled when client certificate or CA bundle is set")
+	}
+	for _, m := range conf.Metadata {
+		// metadata keys are lowercase http/2 headers, grpc- prefix is reserved for the protocol
+		if m.Name == "" || strings.HasPrefix(strings.ToLower(m.Name), "grpc-") {
+			return nil, errors.Wrapf(invalidConfigError, "conf.Metadata name %q is not allowed", m.Name)
+		}
+	}
+	if conf.DBClient == nil {
+		return nil, errors.Wrap(invalidConfigError, "conf.DBClient must not be nil")
+	}
+	if conf.Logger == nil {
+		return nil, errors.Wrap(invalidConfigError, "conf.Logger must not be nil")
+	}
+
+	newCheck := &Check{
+		id:            conf.Id,
+		failThreshold: conf.FailThreshold,
+		interval:      conf.Interval,
+		timeout:       conf.Timeout,
+		port:          conf.Port,
+		target:        conf.Target,
+
+		service:  conf.Service,
+		metadata: conf.Metadata,
+
+		tls:           conf.Tls,
+		tlsSkipVerify: conf.TlsSkipVerify,
+		tlsServerName: conf.TlsServerName,
+		tlsMaterial:   tlsMaterial,
+
+		dbClient: conf.DBClient,
+		log:      conf.Logger,
+	}
+
+	return newCheck, nil
+}
+
+// wrapper function used to run in separate thread (goroutine)
+func (c *Check) RunCheck() {
+	// generate unique request ID
+	c.requestId = key.GenerateReqId(c.id)
+	// run monitoring check
+	s := c.doCheck()
+	c.LogResult(s)
+
+	// save result to database
+	s.SaveToDB()
+}
+
+func (c *Check) doCheck() *status.Status {
+	statusConfig := status.Config{
+		Id:            c.id,
+		ReqId:         c.requestId,
+		Interval:      c.interval,
+		FailThreshold: c.failThreshold,
+		DBClient:      c.dbClient,
+	}
+	s, err := status.New(statusConfig)
+	if err != nil {
+		c.LogRunError(err, fmt.Sprintf("failed to init new status for GRPC service ID %d", c.id))
+	}
+	tStart := time.Now()
+	defer func() {
+		s.Duration = time.Since(tStart)
+	}()
+
+	// prepare transport
+	protocols := new(http.Protocols)
+	transport := &http.Transport{
+		DialContext: (&net.Dialer{Timeout: c.timeout}).DialContext,
+		Protocols:   protocols,
+	}
+	scheme := "http"
+	if c.tls {
+		scheme = "https"
+		protocols.SetHTTP2(true)
+		transport.TLSClientConfig = &tls.Config{
+			InsecureSkipVerify: c.tlsSkipVerify,
+			ServerName:         c.tlsServerName,
+		}
+		if c.tlsMaterial.Enabled() {
+			material, err := certs.Load(c.id, c.tlsMaterial)
+			if err != nil {
+				c.LogRunError(err, msgInternalFailedTLSMaterial)
+				s.Set(false, err, msgInternalFailedTLSMaterial)
+				return s
+			}
+			material.Apply(transport.TLSClientConfig)
+			// expired client certificate would only cause confusing handshake error
+			if leaf := material.ClientLeaf; leaf != nil && time.Now().After(leaf.NotAfter) {
+				s.Set(false, nil, fmt.Sprintf("%s, client certificate %s expired on %s", msgFailedCertExpired, leaf.Subject.CommonName, leaf.NotAfter.Format(time.RFC3339)))
+				return s
+			}
+		}
+	} else {
+		protocols.SetUnencryptedHTTP2(true)
+	}
+	client := &http.Client{Transport: transport}
+	defer transport.CloseIdleConnections()
+
+	// prepare request
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	callUrl := fmt.Sprintf("%s://%s%s", scheme, net.JoinHostPort(c.target, strconv.Itoa(c.port)), healthCheckPath)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, callUrl, bytes.NewReader(healthCheckRequest(c.service)))
+	if err != nil {
+		c.LogRunError(err, "failed to create request")
+		s.Set(false, err, msgFailedCall)
+		return s
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("TE", "trailers")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Grpc-Timeout", fmt.Sprintf("%dm", c.timeout.Milliseconds()))
+	for _, m := range c.metadata {
+		value, err := secret.Value(m.Value)
+		if err != nil {
+			c.LogRunError(err, msgInternalFailedSecret)
+			s.Set(false, err, msgInternalFailedSecret)
+			return s
+		}
+		req.Header.Add(m.Name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		s.Set(false, err, msgFailedCall)
+		return s
+	}
+	defer resp.Body.Close()
+
+	servingStatus, err := readHealthCheckResponse(resp)
+	if err != nil {
+		if _, ok := err.(*statusError); ok {
+			s.Set(false, err, msgFailedStatus)
+		} else {
+			s.Set(false, err, msgFailedCall)
+		}
+		return s
+	}
+	if servingStatus != ServingStatusServing {
+		s.Set(false, nil, fmt.Sprintf("%s, status %s", msgFailedNotServing, servingStatusName(servingStatus)))
+		return s
+	}
+
+	s.Set(true, nil, fmt.Sprintf("%s, status %s", msgSuccess, servingStatusName(servingStatus)))
+	return s
+}
+
+func (c *Check) GetStringPort() string {
+	return fmt.Sprintf(":%d", c.port)
+}
+
+func (c *Check) LogResult(s *status.Status) {
+	c.log.Log("check-GRPC|id %d|reqID %s|target %s|port %d|service '%s'|latency %sms|result '%t'|msg: %s", c.id, c.requestId, c.target, c.port, c.service, key.MsFromDuration(s.Duration), s.Result, s.Message)
+}
+
+func (c *Check) LogRunError(err error, message string) {
+	c.log.LogError(err, "running check id:%d reqID:%s type:grpc target:%s:%d failed, reason: %s", c.id, c.requestId, c.target, c.port, message)
+}
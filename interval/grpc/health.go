@@ -0,0 +1,192 @@
+package grpc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// gRPC health checking protocol (grpc.health.v1), implemented directly over HTTP/2
+// as the check needs only one unary call
+
+const (
+	healthCheckPath = "/grpc.health.v1.Health/Check"
+	contentType     = "application/grpc"
+	userAgent       = "watcher-grpc-health"
+
+	// size of length-prefixed message header
+	messageHeaderSize = 5
+	// health response is tiny, anything bigger is not a health response
+	maxResponseSize = 64 * 1024
+)
+
+// HealthCheckResponse.ServingStatus
+const (
+	ServingStatusUnknown        = 0
+	ServingStatusServing        = 1
+	ServingStatusNotServing     = 2
+	ServingStatusServiceUnknown = 3
+)
+
+var servingStatusNames = map[uint64]string{
+	ServingStatusUnknown:        "UNKNOWN",
+	ServingStatusServing:        "SERVING",
+	ServingStatusNotServing:     "NOT_SERVING",
+	ServingStatusServiceUnknown: "SERVICE_UNKNOWN",
+}
+
+var codeNames = []string{
+	"OK", "CANCELLED", "UNKNOWN", "INVALID_ARGUMENT", "DEADLINE_EXCEEDED", "NOT_FOUND", "ALREADY_EXISTS",
+	"PERMISSION_DENIED", "RESOURCE_EXHAUSTED", "FAILED_PRECONDITION", "ABORTED", "OUT_OF_RANGE",
+	"UNIMPLEMENTED", "INTERNAL", "UNAVAILABLE", "DATA_LOSS", "UNAUTHENTICATED",
+}
+
+// error status of the call returned by the server in grpc-status
+type statusError struct {
+	code    int
+	message string
+}
+
+func (e *statusError) Error() string {
+	name := strconv.Itoa(e.code)
+	if e.code >= 0 && e.code < len(codeNames) {
+		name = codeNames[e.code]
+	}
+	if e.message == "" {
+		return "grpc status " + name
+	}
+	return fmt.Sprintf("grpc status %s: %s", name, e.message)
+}
+
+func servingStatusName(servingStatus uint64) string {
+	if name, ok := servingStatusNames[servingStatus]; ok {
+		return name
+	}
+	return strconv.FormatUint(servingStatus, 10)
+}
+
+// encoded HealthCheckRequest{service = 1} framed as gRPC message
+func healthCheckRequest(service string) []byte {
+	var message []byte
+	if service != "" {
+		message = append(message, 1<<3|2)
+		message = appendUvarint(message, uint64(len(service)))
+		message = append(message, service...)
+	}
+	frame := make([]byte, messageHeaderSize, messageHeaderSize+len(message))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(message)))
+	return append(frame, message...)
+}
+
+// read the response and return serving status, non-zero grpc-status is returned as *statusError
+func readHealthCheckResponse(resp *http.Response) (uint64, error) {
+	if resp.StatusCode != http.StatusOK {
+		return 0, errors.Errorf("unexpected http status %s", resp.Status)
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), contentType) {
+		return 0, errors.Errorf("unexpected content type %q", resp.Header.Get("Content-Type"))
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return 0, err
+	}
+
+	// trailers are available after reading the body, trailers-only responses have status in headers
+	grpcStatus := resp.Trailer.Get("Grpc-Status")
+	grpcMessage := resp.Trailer.Get("Grpc-Message")
+	if grpcStatus == "" {
+		grpcStatus = resp.Header.Get("Grpc-Status")
+		grpcMessage = resp.Header.Get("Grpc-Message")
+	}
+	if grpcStatus == "" {
+		return 0, errors.New("response is missing grpc-status")
+	}
+	code, err := strconv.Atoi(grpcStatus)
+	if err != nil {
+		return 0, errors.Errorf("invalid grpc-status %q", grpcStatus)
+	}
+	if code != 0 {
+		message, err := url.PathUnescape(grpcMessage)
+		if err != nil {
+			message = grpcMessage
+		}
+		return 0, &statusError{code: code, message: message}
+	}
+
+	if len(body) < messageHeaderSize {
+		return 0, errors.New("response message is missing")
+	}
+	if body[0] != 0 {
+		return 0, errors.New("compressed response is not supported")
+	}
+	size := binary.BigEndian.Uint32(body[1:messageHeaderSize])
+	message := body[messageHeaderSize:]
+	if uint32(len(message)) < size {
+		return 0, errors.New("response message is truncated")
+	}
+
+	return decodeServingStatus(message[:size])
+}
+
+// decode HealthCheckResponse{status = 1}, unknown fields are skipped
+func decodeServingStatus(message []byte) (uint64, error) {
+	var servingStatus uint64
+	buf := bytes.NewReader(message)
+	for buf.Len() > 0 {
+		tag, err := binary.ReadUvarint(buf)
+		if err != nil {
+			return 0, errors.Wrap(err, "invalid protobuf message")
+		}
+		field, wireType := tag>>3, tag&7
+		switch wireType {
+		case 0:
+			value, err := binary.ReadUvarint(buf)
+			if err != nil {
+				return 0, errors.Wrap(err, "invalid protobuf message")
+			}
+			if field == 1 {
+				servingStatus = value
+			}
+		case 1:
+			err = skip(buf, 8)
+		case 2:
+			var size uint64
+			size, err = binary.ReadUvarint(buf)
+			if err == nil {
+				err = skip(buf, size)
+			}
+		case 5:
+			err = skip(buf, 4)
+		default:
+			err = errors.Errorf("unsupported wire type %d", wireType)
+		}
+		if err != nil {
+			return 0, errors.Wrap(err, "invalid protobuf message")
+		}
+	}
+	return servingStatus, nil
+}
+
+// skip field value, seeking past the end of the message would not fail on its own
+func skip(buf *bytes.Reader, size uint64) error {
+	if size > uint64(buf.Len()) {
+		return io.ErrUnexpectedEOF
+	}
+	_, err := buf.Seek(int64(size), io.SeekCurrent)
+	return err
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
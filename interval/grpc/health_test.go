@@ -0,0 +1,52 @@
+package grpc
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestHealthCheckRequest(t *testing.T) {
+	tests := []struct {
+		service string
+		want    []byte
+	}{
+		{service: "", want: []byte{0, 0, 0, 0, 0}},
+		{service: "api", want: []byte{0, 0, 0, 0, 5, 0x0a, 3, 'a', 'p', 'i'}},
+	}
+	for _, tt := range tests {
+		if got := healthCheckRequest(tt.service); !bytes.Equal(got, tt.want) {
+			t.Errorf("healthCheckRequest(%q) = %v, want %v", tt.service, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeServingStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		message []byte
+		want    uint64
+		wantErr bool
+	}{
+		{name: "empty message is unknown", message: nil, want: 0},
+		{name: "serving", message: []byte{0x08, 1}, want: 1},
+		{name: "not serving", message: []byte{0x08, 2}, want: 2},
+		{name: "multi byte varint", message: []byte{0x08, 0x96, 0x01}, want: 150},
+		{name: "unknown fields are skipped", message: []byte{0x11, 1, 2, 3, 4, 5, 6, 7, 8, 0x1a, 2, 'x', 'y', 0x25, 1, 2, 3, 4, 0x08, 1}, want: 1},
+		{name: "truncated varint", message: []byte{0x08, 0x96}, wantErr: true},
+		{name: "truncated fixed64", message: []byte{0x11, 1, 2}, wantErr: true},
+		{name: "truncated bytes", message: []byte{0x1a, 10, 'x'}, wantErr: true},
+		{name: "hostile bytes length", message: []byte{0x1a, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}, wantErr: true},
+		{name: "unsupported wire type", message: []byte{0x0b}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeServingStatus(tt.message)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeServingStatus() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("decodeServingStatus() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
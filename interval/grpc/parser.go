@@ -0,0 +1,81 @@
+package grpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/exmonitor/exclient/database"
+	"github.com/exmonitor/exclient/database/spec/service"
+	"github.com/exmonitor/exlogger"
+	"github.com/pkg/errors"
+)
+
+/*
+Example metadata:
+{
+	"id": 13,
+	"target": "api.domain.cz",
+	"port": 50051,
+	"timeout": 5,
+	"service": "orders.v1.OrderService",
+	"tls": true,
+	"tlsSkipVerify": false,
+	"tlsServerName": "api.domain.cz",
+	"tlsClientCert": "file:/etc/watcher/tls/client.pem",
+	"tlsClientKey": "secret:client-key",
+	"tlsCaBundle": "file:/etc/watcher/tls/ca.pem",
+	"metadata": [
+		{
+			"name": "authorization",
+			"value": "env:GRPC_TOKEN"
+		}
+	]
+}
+*/
+
+type RawCheck struct {
+	Id            int             `json:"id"`
+	Target        string          `json:"target"`
+	Port          int             `json:"port"`
+	Timeout       int             `json:"timeout"`
+	Service       string          `json:"service"`
+	Tls           bool            `json:"tls"`
+	TlsSkipVerify bool            `json:"tlsSkipVerify"`
+	TlsServerName string          `json:"tlsServerName"`
+	TlsClientCert string          `json:"tlsClientCert"`
+	TlsClientKey  string          `json:"tlsClientKey"`
+	TlsCaBundle   string          `json:"tlsCaBundle"`
+	Metadata      []MetadataValue `json:"metadata"`
+}
+
+func ParseCheck(service *service.Service, dbClient database.ClientInterface, logger *exlogger.Logger) (*Check, error) {
+	var rawCheck RawCheck
+	err := json.Unmarshal([]byte(service.Metadata), &rawCheck)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to parse GRPC json metadata for check id %d", service.ID))
+	} else {
+		logger.LogDebug("Successfully parsed GRPC json metadata for check id %d", service.ID)
+	}
+
+	checkConfig := CheckConfig{
+		Id:            service.ID,
+		FailThreshold: service.FailThreshold,
+		Interval:      service.Interval,
+		Target:        rawCheck.Target,
+		Port:          rawCheck.Port,
+		Timeout:       time.Second * time.Duration(rawCheck.Timeout),
+		Service:       rawCheck.Service,
+		Tls:           rawCheck.Tls,
+		TlsSkipVerify: rawCheck.TlsSkipVerify,
+		TlsServerName: rawCheck.TlsServerName,
+		TlsClientCert: rawCheck.TlsClientCert,
+		TlsClientKey:  rawCheck.TlsClientKey,
+		TlsCaBundle:   rawCheck.TlsCaBundle,
+		Metadata:      rawCheck.Metadata,
+		Logger:        logger,
+		DBClient:      dbClient,
+	}
+
+	return NewCheck(checkConfig)
+}
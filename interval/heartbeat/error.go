@@ -0,0 +1,9 @@
+package heartbeat
+
+import "errors"
+
+var invalidConfigError error = errors.New("invalid check config")
+
+var unknownTokenError error = errors.New("unknown heartbeat token")
+
+var invalidPingError error = errors.New("invalid heartbeat ping")
@@ -0,0 +1,204 @@
+package heartbeat
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/exmonitor/exclient/database"
+	"github.com/exmonitor/exlogger"
+	"github.com/pkg/errors"
+
+	"github.com/exmonitor/watcher/interval/checkstate"
+	"github.com/exmonitor/watcher/interval/spec"
+	"github.com/exmonitor/watcher/interval/status"
+	"github.com/exmonitor/watcher/key"
+)
+
+const (
+	msgSuccess           = "success"
+	msgSuccessWaiting    = "success, waiting for first heartbeat"
+	msgFailedNoPing      = "failed - no heartbeat received"
+	msgFailedLate        = "failed - heartbeat is late"
+	msgFailedJob         = "failed - job reported failure"
+	msgFailedNotFinished = "failed - job did not finish within grace time"
+	msgReceiverDisabled  = "heartbeat receiver is not running"
+
+	// tokens are the only authentication of the pings, so they must not be guessable
+	minTokenLength = 16
+)
+
+var tokenRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+type CheckConfig struct {
+	Id            int
+	FailThreshold int
+	Interval      int
+	Token         string        // secret part of the heartbeat url
+	Period        time.Duration // expected time between two successful pings
+	Grace         time.Duration // extra time for late pings and max duration of running job
+
+	//db client
+	DBClient database.ClientInterface
+	Logger   *exlogger.Logger
+}
+
+type Check struct {
+	id            int
+	failThreshold int
+	interval      int
+	requestId     string
+	token         string
+	period        time.Duration
+	grace         time.Duration
+
+	// db client
+	dbClient database.ClientInterface
+	// logger
+	log *exlogger.Logger
+
+	// internals
+	spec.CheckInterface
+}
+
+func NewCheck(conf CheckConfig) (*Check, error) {
+	if conf.Id == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.id must not be zero")
+	}
+	if conf.FailThreshold == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.FailThreshold must not be zero")
+	}
+	if conf.Interval == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.Interval must not be zero")
+	}
+	if len(conf.Token) < minTokenLength {
+		return nil, errors.Wrapf(invalidConfigError, "conf.Token must have at least %d characters", minTokenLength)
+	}
+	if !tokenRegexp.MatchString(conf.Token) {
+		return nil, errors.Wrap(invalidConfigError, "conf.Token can contain only letters, digits, '-' and '_'")
+	}
+	if conf.Period <= 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.Period must be positive")
+	}
+	if conf.Grace < 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.Grace must not be negative")
+	}
+	if conf.DBClient == nil {
+		return nil, errors.Wrap(invalidConfigError, "conf.DBClient must not be nil")
+	}
+	if conf.Logger == nil {
+		return nil, errors.Wrap(invalidConfigError, "conf.Logger must not be nil")
+	}
+	// pings for the token are accepted from now on, until the check is not parsed for a while
+	register(conf.Token, conf.Id, time.Now(), checkstate.Expiration(conf.Interval))
+
+	newCheck := &Check{
+		id:            conf.Id,
+		failThreshold: conf.FailThreshold,
+		interval:      conf.Interval,
+		token:         conf.Token,
+		period:        conf.Period,
+		grace:         conf.Grace,
+
+		dbClient: conf.DBClient,
+		log:      conf.Logger,
+	}
+
+	return newCheck, nil
+}
+
+// wrapper function used to run in separate thread (goroutine)
+func (c *Check) RunCheck() {
+	// generate unique request ID
+	c.requestId = key.GenerateReqId(c.id)
+	// run monitoring check
+	s := c.doCheck()
+	c.LogResult(s)
+
+	// save result to database
+	s.SaveToDB()
+}
+
+// evaluate last heartbeat received by the receiver
+// check is passive, so duration of the status is the job duration reported by the last ping
+func (c *Check) doCheck() *status.Status {
+	statusConfig := status.Config{
+		Id:            c.id,
+		ReqId:         c.requestId,
+		Interval:      c.interval,
+		FailThreshold: c.failThreshold,
+		DBClient:      c.dbClient,
+	}
+	s, err := status.New(statusConfig)
+	if err != nil {
+		c.LogRunError(err, fmt.Sprintf("failed to init new status for HEARTBEAT service ID %d", c.id))
+	}
+
+	now := time.Now()
+	beat, _ := get(c.token)
+	s.Duration = beat.Duration
+	result, msg := c.evaluate(beat, now)
+	s.Set(result, nil, msg)
+	return s
+}
+
+// returns result and message for the heartbeat
+func (c *Check) evaluate(beat Beat, now time.Time) (bool, string) {
+	deadline := c.period + c.grace
+	if beat.LastPing.IsZero() {
+		// watcher restart loses all heartbeats, so the job gets whole period since the token was registered
+		since := now.Sub(beat.Registered)
+		if since <= deadline {
+			return true, msgSuccessWaiting
+		}
+		msg := fmt.Sprintf("%s in %s", msgFailedNoPing, roundDuration(since))
+		if !listening.Load() {
+			msg += ", " + msgReceiverDisabled
+		}
+		return false, msg
+	}
+
+	if beat.LastState == StateFail {
+		return false, fmt.Sprintf("%s %s ago%s", msgFailedJob, roundDuration(now.Sub(beat.LastFail)), formatJobDuration(beat.Duration))
+	}
+	if beat.Running() && now.Sub(beat.LastStart) > c.grace {
+		return false, fmt.Sprintf("%s, started %s ago", msgFailedNotFinished, roundDuration(now.Sub(beat.LastStart)))
+	}
+	lastSuccess := beat.LastSuccess
+	if lastSuccess.IsZero() {
+		lastSuccess = beat.Registered
+	}
+	if since := now.Sub(lastSuccess); since > deadline {
+		return false, fmt.Sprintf("%s, last success %s ago, expected every %s", msgFailedLate, roundDuration(since), c.period)
+	}
+
+	msg := fmt.Sprintf("%s, last heartbeat %s ago", msgSuccess, roundDuration(now.Sub(beat.LastPing)))
+	if beat.Running() {
+		msg += ", job is running"
+	}
+	return true, msg + formatJobDuration(beat.Duration)
+}
+
+func formatJobDuration(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return fmt.Sprintf(", job duration %s", d.Round(time.Millisecond))
+}
+
+func roundDuration(d time.Duration) time.Duration {
+	return d.Round(time.Second)
+}
+
+func (c *Check) GetStringPort() string {
+	// heartbeat has no port
+	return ""
+}
+
+func (c *Check) LogResult(s *status.Status) {
+	c.log.Log("check-HEARTBEAT|id %d|reqID %s|period %s|grace %s|duration %sms|result '%t'|msg: %s", c.id, c.requestId, c.period, c.grace, key.MsFromDuration(s.Duration), s.Result, s.Message)
+}
+
+func (c *Check) LogRunError(err error, message string) {
+	c.log.LogError(err, "running check id:%d reqID:%s type:heartbeat failed, reason: %s", c.id, c.requestId, message)
+}
@@ -0,0 +1,52 @@
+package heartbeat
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/exmonitor/exclient/database"
+	"github.com/exmonitor/exclient/database/spec/service"
+	"github.com/exmonitor/exlogger"
+	"github.com/pkg/errors"
+)
+
+/*
+Example metadata:
+{
+	"id": 18,
+	"token": "3f2b8c0e9d7a41c6b5e4",
+	"period": 3600,
+	"grace": 300
+}
+*/
+
+type RawCheck struct {
+	Id     int    `json:"id"`
+	Token  string `json:"token"`
+	Period int    `json:"period"`
+	Grace  int    `json:"grace"`
+}
+
+func ParseCheck(service *service.Service, dbClient database.ClientInterface, logger *exlogger.Logger) (*Check, error) {
+	var rawCheck RawCheck
+	err := json.Unmarshal([]byte(service.Metadata), &rawCheck)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to parse HEARTBEAT json metadata for check id %d", service.ID))
+	} else {
+		logger.LogDebug("Successfully parsed HEARTBEAT json metadata for check id %d", service.ID)
+	}
+
+	checkConfig := CheckConfig{
+		Id:            service.ID,
+		FailThreshold: service.FailThreshold,
+		Interval:      service.Interval,
+		Token:         rawCheck.Token,
+		Period:        time.Second * time.Duration(rawCheck.Period),
+		Grace:         time.Second * time.Duration(rawCheck.Grace),
+		Logger:        logger,
+		DBClient:      dbClient,
+	}
+
+	return NewCheck(checkConfig)
+}
@@ -0,0 +1,112 @@
+package heartbeat
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/exmonitor/exlogger"
+	"github.com/pkg/errors"
+)
+
+/*
+Heartbeat urls:
+	/hb/<token>                     - job finished successfully
+	/hb/<token>/start               - job started
+	/hb/<token>/success             - job finished successfully
+	/hb/<token>/fail                - job failed
+	/hb/<token>/success?duration=42 - job duration in seconds or in time.Duration format, ie: 1m30s
+*/
+
+const (
+	PathPrefix = "/hb/"
+
+	readHeaderTimeout = time.Second * 10
+	// request body is ignored, but its read to keep the connection reusable
+	maxBodySize = 64 * 1024
+)
+
+// set when the receiver is running, checks use it to explain missing heartbeats
+var listening atomic.Bool
+
+// start heartbeat receiver on the address, the listener is opened synchronously so errors are reported immediately
+func Listen(addr string, logger *exlogger.Logger) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return errors.Wrapf(err, "failed to listen on %s", addr)
+	}
+	mux := http.NewServeMux()
+	mux.Handle(PathPrefix, &handler{log: logger})
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+	listening.Store(true)
+	go func() {
+		err := server.Serve(ln)
+		listening.Store(false)
+		logger.LogError(err, "heartbeat receiver on %s stopped", addr)
+	}()
+	logger.Log("heartbeat receiver listening on %s", addr)
+	return nil
+}
+
+type handler struct {
+	log *exlogger.Logger
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	io.Copy(io.Discard, io.LimitReader(r.Body, maxBodySize))
+
+	token, state := parsePath(r.URL.Path)
+	duration, err := parseDuration(r.URL.Query().Get("duration"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	checkId, err := Ping(token, state, duration, time.Now())
+	if errors.Cause(err) == unknownTokenError {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// token is the only authentication of the ping, so it is not logged
+	h.log.LogDebug("heartbeat|id %d|state %s|duration %s|remote %s", checkId, state, duration, r.RemoteAddr)
+	io.WriteString(w, "OK\n")
+}
+
+// split url path into token and state, missing state means success
+func parsePath(path string) (string, string) {
+	parts := strings.SplitN(strings.TrimPrefix(path, PathPrefix), "/", 2)
+	state := StateSuccess
+	if len(parts) == 2 && parts[1] != "" {
+		state = parts[1]
+	}
+	return parts[0], state
+}
+
+// duration is either number of seconds or time.Duration string
+func parseDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, errors.Wrapf(invalidPingError, "invalid duration %q", value)
+	}
+	return d, nil
+}
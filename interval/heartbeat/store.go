@@ -0,0 +1,96 @@
+package heartbeat
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/exmonitor/watcher/interval/checkstate"
+)
+
+// states reported by the job
+const (
+	StateSuccess = "success"
+	StateStart   = "start"
+	StateFail    = "fail"
+)
+
+// last known heartbeat of a single token
+type Beat struct {
+	CheckId     int       // check which registered the token, used in logs instead of the secret token
+	Registered  time.Time // first time the token was loaded from check metadata
+	LastPing    time.Time
+	LastState   string
+	LastSuccess time.Time
+	LastStart   time.Time
+	LastFail    time.Time
+	Duration    time.Duration // job duration reported by the last success or fail ping
+}
+
+// returns true if the job sent start ping and didnt finish yet
+func (b Beat) Running() bool {
+	return b.LastState == StateStart
+}
+
+// heartbeats are shared by the receiver and the checks
+var store = checkstate.NewStore()
+
+// make the token known to the receiver until the expiration, pings with unregistered tokens are rejected,
+// so tokens of deleted checks stop being accepted
+func register(token string, checkId int, now time.Time, ttl time.Duration) {
+	store.Update(token, ttl, func(value interface{}) interface{} {
+		beat, ok := value.(*Beat)
+		if !ok {
+			beat = &Beat{Registered: now}
+		}
+		beat.CheckId = checkId
+		return beat
+	})
+}
+
+// returns copy of the heartbeat for the token
+func get(token string) (Beat, bool) {
+	var beat Beat
+	ok := store.View(token, func(value interface{}) {
+		beat = *value.(*Beat)
+	})
+	return beat, ok
+}
+
+// record ping from the job and return id of the check which registered the token,
+// zero duration is computed from the start ping if there was any
+func Ping(token string, state string, duration time.Duration, now time.Time) (int, error) {
+	var checkId int
+	var err error
+	ok := store.View(token, func(value interface{}) {
+		beat := value.(*Beat)
+		checkId = beat.CheckId
+		err = ping(beat, state, duration, now)
+	})
+	if !ok {
+		return 0, unknownTokenError
+	}
+	return checkId, err
+}
+
+func ping(beat *Beat, state string, duration time.Duration, now time.Time) error {
+	switch state {
+	case StateStart:
+		beat.LastStart = now
+	case StateSuccess, StateFail:
+		if duration == 0 && beat.Running() {
+			duration = now.Sub(beat.LastStart)
+		}
+		beat.Duration = duration
+		if state == StateSuccess {
+			beat.LastSuccess = now
+		} else {
+			beat.LastFail = now
+		}
+	default:
+		return errors.Wrapf(invalidPingError, "unknown state %q", state)
+	}
+	beat.LastPing = now
+	beat.LastState = state
+	return nil
+}
@@ -0,0 +1,26 @@
+package http
+
+import (
+	"fmt"
+	"strings"
+)
+
+// response assertions shared with checks built on top of the http check (transaction)
+
+// returns reason of the failure if the status code is not allowed, empty string if it is
+func StatusCodeFailure(code int, allowed []int) string {
+	for _, allowedCode := range allowed {
+		if code == allowedCode {
+			return ""
+		}
+	}
+	return fmt.Sprintf("HTTP code: %d is not within allowed codes %v", code, allowed)
+}
+
+// returns reason of the failure if the body does not contain the string, empty string if it does
+func ContentFailure(body []byte, contentCheckString string) string {
+	if strings.Contains(string(body), contentCheckString) {
+		return ""
+	}
+	return fmt.Sprintf("content %q not found", contentCheckString)
+}
@@ -1,10 +1,14 @@
 package http
 
 import (
+	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -12,6 +16,9 @@ import (
 	"github.com/exmonitor/exlogger"
 	"github.com/pkg/errors"
 
+	"github.com/exmonitor/watcher/interval/certs"
+	"github.com/exmonitor/watcher/interval/family"
+	"github.com/exmonitor/watcher/interval/proxy"
 	"github.com/exmonitor/watcher/interval/spec"
 	"github.com/exmonitor/watcher/interval/status"
 	"github.com/exmonitor/watcher/key"
@@ -23,12 +30,27 @@ const (
 	msgFailedBadStatusCode   = "failed - bad http status code"
 	msgFailedContentNotFound = "failed - content not found"
 	msgFailedCertExpired     = "failed - certificate expiration issue"
+	msgFailedFinalUrl        = "failed - unexpected final url"
+	msgFailedProxy           = "failed - proxy error"
+	msgFailedTLSAudit        = "failed - tls audit"
+	msgFailedCertRevocation  = "failed - certificate revocation issue"
 
 	msgInternalFailedToReadResponse = "INTERNAL: failed to read http response"
 	msgInternalFailedHttpClient     = "INTERNAL: failed to prepare http request"
+	msgInternalFailedTLSMaterial    = "INTERNAL: failed to load tls client certificate or CA bundle"
 )
 
-var defaultAllowedStatusCodes = []int{200, 201, 202, 203, 204, 205}
+const (
+	// redirect policies
+	RedirectPolicyFollow   = "follow"   // follow redirects up to maxRedirects hops
+	RedirectPolicyNone     = "none"     // do not follow any redirect, response with 3xx code is evaluated
+	RedirectPolicySameHost = "sameHost" // follow redirects only within the same host, redirect to other host is the response
+
+	defaultMaxRedirects = 10
+)
+
+// status codes accepted when check doesnt set its own
+var DefaultAllowedStatusCodes = []int{200, 201, 202, 203, 204, 205}
 
 // config is used for initializing the check
 type CheckConfig struct {
@@ -49,6 +71,13 @@ type CheckConfig struct {
 	AuthUsername string
 	AuthPassword string
 
+	// connection overrides, similar to curl --resolve
+	ConnectAddress string // IP or IP:port used instead of resolving target
+	TlsServerName  string // SNI sent in tls handshake
+	HostHeader     string // value of http Host header
+	Proxy          string // proxy url, empty for global proxy
+	IpFamily       string // ipv4, ipv6 or both, empty to let resolver decide
+
 	// content specific options
 	ContentCheckEnabled bool
 	ContentCheckString  string
@@ -56,10 +85,23 @@ type CheckConfig struct {
 	// allowed http responses
 	AllowedHttpStatusCodes []int
 
+	// redirect options
+	RedirectPolicy       string
+	MaxRedirects         int
+	FinalUrlCheckEnabled bool
+	FinalUrlCheckString  string
+
 	// https options
 	TlsSkipVerify              bool
 	TlsCheckCertificates       bool
 	TlsCertExpirationThreshold time.Duration
+	TlsClientCert              string // secret reference
+	TlsClientKey               string // secret reference
+	TlsCaBundle                string // secret reference
+	TlsAuditEnabled            bool
+	TlsAuditOptions            certs.AuditOptions
+	TlsRevocationCheckEnabled  bool
+	TlsRequireOcspStaple       bool
 
 	// db client
 	DBClient database.ClientInterface
@@ -88,6 +130,13 @@ type Check struct {
 	authUsername string
 	authPassword string
 
+	// connection overrides
+	connectAddress string
+	tlsServerName  string
+	hostHeader     string
+	dialer         *proxy.Dialer
+	ipFamily       string
+
 	// content specific options
 	contentCheckEnabled bool
 	contentCheckString  string
@@ -95,10 +144,20 @@ type Check struct {
 	// allowed http responses status code (ie: [200,404])
 	allowedHttpStatusCodes []int
 
+	// redirect options
+	redirectPolicy       string
+	maxRedirects         int
+	finalUrlCheckEnabled bool
+	finalUrlCheckString  string
+
 	// https options
 	tlsSkipVerify              bool
 	tlsCheckCertificates       bool
 	tlsCertExpirationThreshold time.Duration
+	tlsMaterial                certs.Config
+	tlsAuditor                 *certs.Auditor // nil if audit is disabled
+	tlsRevocationCheckEnabled  bool
+	tlsRequireOcspStaple       bool
 
 	// db client
 	dbClient database.ClientInterface
@@ -146,17 +205,62 @@ func New(conf CheckConfig) (*Check, error) {
 		return nil, errors.Wrapf(invalidConfigError, "check.Username must not be empty, when BasicAuth is enabled")
 	}
 	if len(conf.AllowedHttpStatusCodes) == 0 {
-		conf.AllowedHttpStatusCodes = defaultAllowedStatusCodes
+		conf.AllowedHttpStatusCodes = DefaultAllowedStatusCodes
+	}
+	if conf.RedirectPolicy == "" {
+		conf.RedirectPolicy = RedirectPolicyFollow
+	}
+	if conf.RedirectPolicy != RedirectPolicyFollow && conf.RedirectPolicy != RedirectPolicyNone && conf.RedirectPolicy != RedirectPolicySameHost {
+		return nil, errors.Wrap(invalidConfigError, "redirect policy "+conf.RedirectPolicy+" is not supported")
+	}
+	if conf.MaxRedirects < 0 {
+		return nil, errors.Wrap(invalidConfigError, "check.MaxRedirects must not be negative")
+	}
+	if conf.MaxRedirects == 0 {
+		conf.MaxRedirects = defaultMaxRedirects
+	}
+	if conf.FinalUrlCheckEnabled && conf.FinalUrlCheckString == "" {
+		return nil, errors.Wrap(invalidConfigError, "check.FinalUrlCheckString must not be empty, when FinalUrlCheckEnabled is enabled")
 	}
 	if conf.TlsCheckCertificates && conf.TlsCertExpirationThreshold == 0 {
 		return nil, errors.Wrapf(invalidConfigError, "check.tlsCertExpirationThreshold must not be zero, when tlsCheckCertificates is enabled")
 	}
+	if (conf.TlsClientCert == "") != (conf.TlsClientKey == "") {
+		return nil, errors.Wrapf(invalidConfigError, "check.TlsClientCert and check.TlsClientKey must be set together")
+	}
 	if conf.Logger == nil {
 		return nil, errors.Wrapf(invalidConfigError, "check.Logger must not be nil")
 	}
 	if conf.DBClient == nil {
 		return nil, errors.Wrapf(invalidConfigError, "check.DBClient must not be nil")
 	}
+	if err := family.Validate(conf.IpFamily); err != nil {
+		return nil, errors.Wrap(invalidConfigError, err.Error())
+	}
+	dialer, err := proxy.New(conf.Proxy, conf.Timeout)
+	if err != nil {
+		return nil, errors.Wrap(err, "check.Proxy is not valid")
+	}
+	// forwarding proxy resolves the target itself
+	if dialer.ForwardsHTTP() && conf.Proto == "http" && conf.ConnectAddress == "" && conf.IpFamily != family.Any {
+		return nil, errors.Wrap(invalidConfigError, "check.IpFamily cannot be used for http targets via HTTP proxy")
+	}
+	if conf.TlsRevocationCheckEnabled && conf.Proto != "https" {
+		return nil, errors.Wrap(invalidConfigError, "check.TlsRevocationCheckEnabled can be used only with https")
+	}
+	if conf.TlsRequireOcspStaple && !conf.TlsRevocationCheckEnabled {
+		return nil, errors.Wrap(invalidConfigError, "check.TlsRequireOcspStaple can be used only when TlsRevocationCheckEnabled is enabled")
+	}
+	var auditor *certs.Auditor
+	if conf.TlsAuditEnabled {
+		if conf.Proto != "https" {
+			return nil, errors.Wrap(invalidConfigError, "check.TlsAuditEnabled can be used only with https")
+		}
+		auditor, err = certs.NewAuditor(conf.TlsAuditOptions)
+		if err != nil {
+			return nil, errors.Wrap(err, "check.TlsAuditOptions are not valid")
+		}
+	}
 
 	// init values
 	newCheck := &Check{
@@ -174,14 +278,33 @@ func New(conf CheckConfig) (*Check, error) {
 		authUsername: conf.AuthUsername,
 		authPassword: conf.AuthPassword,
 
+		connectAddress: conf.ConnectAddress,
+		tlsServerName:  conf.TlsServerName,
+		hostHeader:     conf.HostHeader,
+		dialer:         dialer,
+		ipFamily:       conf.IpFamily,
+
 		contentCheckEnabled: conf.ContentCheckEnabled,
 		contentCheckString:  conf.ContentCheckString,
 
 		allowedHttpStatusCodes: conf.AllowedHttpStatusCodes,
 
+		redirectPolicy:       conf.RedirectPolicy,
+		maxRedirects:         conf.MaxRedirects,
+		finalUrlCheckEnabled: conf.FinalUrlCheckEnabled,
+		finalUrlCheckString:  conf.FinalUrlCheckString,
+
 		tlsSkipVerify:              conf.TlsSkipVerify,
 		tlsCheckCertificates:       conf.TlsCheckCertificates,
 		tlsCertExpirationThreshold: conf.TlsCertExpirationThreshold,
+		tlsMaterial: certs.Config{
+			ClientCert: conf.TlsClientCert,
+			ClientKey:  conf.TlsClientKey,
+			CABundle:   conf.TlsCaBundle,
+		},
+		tlsAuditor:                auditor,
+		tlsRevocationCheckEnabled: conf.TlsRevocationCheckEnabled,
+		tlsRequireOcspStaple:      conf.TlsRequireOcspStaple,
 
 		failThreshold: conf.FailThreshold,
 
@@ -203,8 +326,13 @@ func (c *Check) RunCheck() {
 	s.SaveToDB()
 }
 
-// run monitoring check with all options
+// run monitoring check, in dual-stack mode each address family is checked separately
 func (c *Check) doCheck() *status.Status {
+	return family.Run(c.ipFamily, c.doFamilyCheck)
+}
+
+// run monitoring check with all options over single address family
+func (c *Check) doFamilyCheck(ipFamily string) *status.Status {
 	statusConfig := status.Config{
 		Id:            c.id,
 		ReqId:         c.requestId,
@@ -218,24 +346,20 @@ func (c *Check) doCheck() *status.Status {
 	}
 	tStart := time.Now()
 
-	// set tls config
-	tlsConfig := &tls.Config{
-		InsecureSkipVerify: c.tlsSkipVerify,
+	// set tls config with client certificate and custom CA bundle
+	tlsConfig, clientCert, err := c.TLSConfig()
+	if err != nil {
+		c.LogRunError(err, msgInternalFailedTLSMaterial)
+		s.Set(false, err, msgInternalFailedTLSMaterial)
+		return s
 	}
-	// set http transport configuration
-	transportConf := &http.Transport{
-		ResponseHeaderTimeout: c.timeout,
-		IdleConnTimeout:       c.timeout,
-		ExpectContinueTimeout: c.timeout,
-		TLSHandshakeTimeout:   c.timeout,
-		TLSClientConfig:       tlsConfig,
+	// expired client certificate would only cause confusing handshake error
+	if clientCert != nil && time.Now().After(clientCert.NotAfter) {
+		s.Set(false, nil, fmt.Sprintf("%s, client certificate %s expired on %s", msgFailedCertExpired, clientCert.Subject.CommonName, clientCert.NotAfter.Format(time.RFC3339)))
+		return s
 	}
 	// initialize http client
-	client := http.Client{
-		Transport:     transportConf,
-		Timeout:       c.timeout,
-		CheckRedirect: c.redirectPolicyFunc,
-	}
+	client := c.Client(ipFamily, tlsConfig)
 	// prepare http request
 	req, err := http.NewRequest(c.method, c.url(), c.getPostData())
 	if err != nil {
@@ -243,36 +367,46 @@ func (c *Check) doCheck() *status.Status {
 		s.Set(false, err, msgInternalFailedHttpClient)
 		return s
 	}
-	// set basic auth if its enabled
-	if c.authEnabled {
-		req.SetBasicAuth(c.authUsername, c.authPassword)
-	}
-
-	// add all extra http headers
-	c.addExtraHeaders(req)
+	// set auth, Host header and extra http headers
+	c.PrepareRequest(req)
 
 	// execute http request
 	resp, err := client.Do(req)
 	if err != nil {
-		s.Set(false, err, msgFailedToExecute)
+		// report untrusted chain or hostname mismatch as audit findings
+		if c.tlsAuditor != nil && isVerificationError(err) {
+			if u, parseErr := url.Parse(err.(*url.Error).URL); parseErr == nil {
+				if findings, auditErr := c.auditTLS(u, ipFamily, tlsConfig.RootCAs); auditErr == nil && len(findings) > 0 {
+					s.Set(false, nil, fmt.Sprintf("%s, %s", msgFailedTLSAudit, certs.FormatFindings(findings)))
+					return s
+				}
+			}
+		}
+		if proxy.IsProxyError(err) {
+			s.Set(false, err, msgFailedProxy)
+		} else if c.dialer.Enabled() {
+			s.Set(false, err, msgFailedToExecute+"target failed via proxy "+c.dialer.String())
+		} else {
+			s.Set(false, err, msgFailedToExecute)
+		}
 		return s
 	} else {
-		httpCodeOK := false
-		// check if http response code is allowed
-		for _, allowedStatusCode := range c.allowedHttpStatusCodes {
-			if resp.StatusCode == allowedStatusCode {
-				httpCodeOK = true
-				break
-			}
+		defer resp.Body.Close()
+		// record the whole redirect chain in the result
+		if chain := redirectChain(resp); len(chain) > 1 {
+			defer func() {
+				s.Message += ", redirects: " + strings.Join(chain, " -> ")
+			}()
 		}
-		if !httpCodeOK {
-			msg := fmt.Sprintf("HTTP code: %d is in not within allowed codes %s", resp.StatusCode, c.allowedHttpStatusCodes)
-			s.Set(false, nil, fmt.Sprintf("%s, %s", msgFailedBadStatusCode, msg))
+
+		// check if http response code is allowed
+		if reason := StatusCodeFailure(resp.StatusCode, c.allowedHttpStatusCodes); reason != "" {
+			s.Set(false, nil, fmt.Sprintf("%s, %s", msgFailedBadStatusCode, reason))
 			return s
 		}
 
 		// check for content
-		if c.contentCheckEnabled && httpCodeOK {
+		if c.contentCheckEnabled {
 			// read http response body
 			respData, err := ioutil.ReadAll(resp.Body)
 			if err != nil {
@@ -281,11 +415,17 @@ func (c *Check) doCheck() *status.Status {
 				return s
 			}
 			// check if response contains requested content in http body
-			if !strings.Contains(string(respData), c.contentCheckString) {
+			if ContentFailure(respData, c.contentCheckString) != "" {
 				s.Set(false, nil, msgFailedContentNotFound)
 				return s
 			}
 		}
+
+		// check the url we ended up on after all redirects
+		if c.finalUrlCheckEnabled && resp.Request.URL.String() != c.finalUrlCheckString {
+			s.Set(false, nil, fmt.Sprintf("%s, expected %s but got %s", msgFailedFinalUrl, c.finalUrlCheckString, resp.Request.URL.String()))
+			return s
+		}
 	}
 	// check certificates
 	if c.tlsCheckCertificates {
@@ -294,6 +434,31 @@ func (c *Check) doCheck() *status.Status {
 			s.Set(false, nil, message)
 			return s
 		}
+		// check our own client certificate as well
+		if clientCert != nil && time.Now().Add(c.tlsCertExpirationThreshold).After(clientCert.NotAfter) {
+			s.Set(false, nil, fmt.Sprintf("client certificate %s will expire in less than %.0f hours", clientCert.Subject.CommonName, c.tlsCertExpirationThreshold.Hours()))
+			return s
+		}
+	}
+	// audit tls configuration of the server which sent the final response
+	if c.tlsAuditor != nil && resp.TLS != nil {
+		findings, err := c.auditTLS(resp.Request.URL, ipFamily, tlsConfig.RootCAs)
+		if err != nil {
+			s.Set(false, err, msgFailedTLSAudit)
+			return s
+		}
+		if len(findings) > 0 {
+			s.Set(false, nil, fmt.Sprintf("%s, %s", msgFailedTLSAudit, certs.FormatFindings(findings)))
+			return s
+		}
+	}
+	// check that server certificate is not revoked
+	if c.tlsRevocationCheckEnabled && resp.TLS != nil {
+		notRevoked, message := certs.CheckRevocation(resp.TLS, c.tlsRequireOcspStaple, c.timeout)
+		if !notRevoked {
+			s.Set(false, nil, fmt.Sprintf("%s, %s", msgFailedCertRevocation, message))
+			return s
+		}
 	}
 
 	s.Duration = time.Since(tStart)
@@ -302,17 +467,181 @@ func (c *Check) doCheck() *status.Status {
 	return s
 }
 
+// audit tls of the host on separate handshakes, no request data or credentials are sent over unverified connections
+func (c *Check) auditTLS(u *url.URL, ipFamily string, roots *x509.CertPool) ([]certs.Finding, error) {
+	hostname, port := u.Hostname(), u.Port()
+	if port == "" {
+		port = "443"
+	}
+	addr := net.JoinHostPort(hostname, port)
+	// original target is verified against the configured server name
+	if addr == net.JoinHostPort(c.target, strconv.Itoa(c.port)) {
+		hostname = c.ServerName()
+	}
+	handshake := func(probeConfig *tls.Config) (*tls.ConnectionState, error) {
+		conn, err := c.DialContext(context.Background(), family.Network("tcp", ipFamily), addr)
+		if err != nil {
+			return nil, err
+		}
+		return certs.Handshake(conn, probeConfig, c.timeout)
+	}
+	// audit verifies chain and hostname itself
+	state, err := handshake(&tls.Config{ServerName: hostname, InsecureSkipVerify: true})
+	if err != nil {
+		return nil, err
+	}
+	return c.tlsAuditor.Audit(state, hostname, roots, handshake), nil
+}
+
+// returns true if the request failed on certificate verification
+func isVerificationError(err error) bool {
+	urlErr, ok := err.(*url.Error)
+	if !ok {
+		return false
+	}
+	_, ok = urlErr.Err.(*tls.CertificateVerificationError)
+	return ok
+}
+
+// returns http client with check timeouts, dialer and redirect policy
+func (c *Check) Client(ipFamily string, tlsConfig *tls.Config) *http.Client {
+	// set http transport configuration
+	transportConf := &http.Transport{
+		ResponseHeaderTimeout: c.timeout,
+		IdleConnTimeout:       c.timeout,
+		ExpectContinueTimeout: c.timeout,
+		TLSHandshakeTimeout:   c.timeout,
+		TLSClientConfig:       tlsConfig,
+		DialContext: func(ctx context.Context, network string, addr string) (net.Conn, error) {
+			return c.DialContext(ctx, family.Network(network, ipFamily), addr)
+		},
+	}
+	// server name override applies only to connections to the original target, other hosts reached by redirects
+	// are verified against their own name
+	if c.tlsServerName != "" {
+		transportConf.DialTLSContext = func(ctx context.Context, network string, addr string) (net.Conn, error) {
+			conn, err := c.DialContext(ctx, family.Network(network, ipFamily), addr)
+			if err != nil {
+				return nil, err
+			}
+			config := tlsConfig.Clone()
+			config.ServerName, _, _ = net.SplitHostPort(addr)
+			if addr == net.JoinHostPort(c.target, strconv.Itoa(c.port)) {
+				config.ServerName = c.tlsServerName
+			}
+			tlsConn := tls.Client(conn, config)
+			ctx, cancel := context.WithTimeout(ctx, c.timeout)
+			defer cancel()
+			if err := tlsConn.HandshakeContext(ctx); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			return tlsConn, nil
+		}
+	}
+	// connect address must be used as tunnel target, so such requests are never forwarded
+	if c.connectAddress == "" {
+		transportConf.Proxy = c.dialer.ForwardProxy
+	}
+	return &http.Client{
+		Transport:     transportConf,
+		Timeout:       c.timeout,
+		CheckRedirect: c.redirectPolicyFunc,
+	}
+}
+
 // redirect policy, in case the target URL is not real page but is redirecting to somewhere else
-// we need to re-add all the http headers
+// credentials and extra http headers are kept only when the redirect stays on the original origin
 func (c *Check) redirectPolicyFunc(req *http.Request, via []*http.Request) error {
+	switch c.redirectPolicy {
+	case RedirectPolicyNone:
+		// return the redirect response itself
+		return http.ErrUseLastResponse
+	case RedirectPolicySameHost:
+		// redirect response to other host is evaluated like any other response
+		if req.URL.Host != via[0].URL.Host {
+			return http.ErrUseLastResponse
+		}
+	}
+	if len(via) > c.maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", c.maxRedirects)
+	}
+
+	// http client copies Authorization header for the same hostname on any scheme or port and for subdomains
+	// and extra headers (api keys, tokens) everywhere, so they have to be removed explicitly when leaving the original origin
+	if !sameOrigin(req.URL, via[0].URL) {
+		req.Header.Del("Authorization")
+		for _, header := range c.extraHeaders {
+			req.Header.Del(header.Name)
+		}
+	} else if c.authEnabled {
+		req.SetBasicAuth(c.authUsername, c.authPassword)
+	}
+
+	return nil
+}
+
+// returns true if both urls have same scheme, host and port
+func sameOrigin(a *url.URL, b *url.URL) bool {
+	return a.Scheme == b.Scheme && a.Host == b.Host
+}
+
+// returns list of all urls visited while following redirects, ending with the final url
+func redirectChain(resp *http.Response) []string {
+	var chain []string
+	for r := resp; r != nil; r = r.Request.Response {
+		chain = append([]string{r.Request.URL.String()}, chain...)
+	}
+	return chain
+}
+
+// dial connection for the http transport, directly or via proxy
+// connections to the check target are redirected to connectAddress if its set
+func (c *Check) DialContext(ctx context.Context, network string, addr string) (net.Conn, error) {
+	if c.connectAddress != "" && addr == net.JoinHostPort(c.target, strconv.Itoa(c.port)) {
+		addr = connectAddress(c.connectAddress, c.port)
+	}
+	return c.dialer.DialContext(ctx, network, addr)
+}
+
+// returns address in host:port format, port is used only if the address doesnt contain any
+func connectAddress(address string, port int) string {
+	if _, _, err := net.SplitHostPort(address); err == nil {
+		return address
+	}
+	return net.JoinHostPort(strings.Trim(address, "[]"), strconv.Itoa(port))
+}
+
+// returns tls config for connections to the target and parsed client certificate if its configured
+func (c *Check) TLSConfig() (*tls.Config, *x509.Certificate, error) {
+	// server name override is applied per connection by the client
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: c.tlsSkipVerify,
+	}
+	if !c.tlsMaterial.Enabled() {
+		return tlsConfig, nil, nil
+	}
+	material, err := certs.Load(c.id, c.tlsMaterial)
+	if err != nil {
+		return nil, nil, err
+	}
+	material.Apply(tlsConfig)
+	return tlsConfig, material.ClientLeaf, nil
+}
+
+// set basic auth, Host header override and extra http headers on the request
+func (c *Check) PrepareRequest(req *http.Request) {
 	// set basic auth if its enabled
 	if c.authEnabled {
 		req.SetBasicAuth(c.authUsername, c.authPassword)
 	}
+	// override Host header
+	if c.hostHeader != "" {
+		req.Host = c.hostHeader
+	}
+
 	// add all extra http headers
 	c.addExtraHeaders(req)
-
-	return nil
 }
 
 // add extra http headers to the request
@@ -333,24 +662,21 @@ func (c *Check) getPostData() *strings.Reader {
 
 }
 
+// hostname expected in the server certificate
+func (c *Check) ServerName() string {
+	if c.tlsServerName != "" {
+		return c.tlsServerName
+	}
+	return c.target
+}
+
 func (c *Check) url() string {
 	return fmt.Sprintf("%s://%s:%d/%s", c.proto, c.target, c.port, c.query)
 }
 
 // check TTL of tls certs
 func (c *Check) checkTLS(conn *tls.ConnectionState) (bool, string) {
-	certsOK := true
-	message := ""
-	// check certs
-	for _, cert := range conn.PeerCertificates {
-		// check if now() + tlsExpirationThreshold > CertExpirationDate
-		if time.Now().Add(c.tlsCertExpirationThreshold).After(cert.NotAfter) {
-			certsOK = false
-			message += fmt.Sprintf("certificate %s will expire in less than %.0f hours", cert.DNSNames, c.tlsCertExpirationThreshold.Hours())
-		}
-	}
-
-	return certsOK, message
+	return certs.CheckExpiration(conn, c.tlsCertExpirationThreshold)
 }
 
 func (c *Check) GetStringPort() string {
@@ -358,7 +684,7 @@ func (c *Check) GetStringPort() string {
 }
 
 func (c *Check) LogResult(s *status.Status) {
-	c.log.Log("check-HTTP|id %d|reqID %s|target %s|proto %s|port %d|latency %sms|result '%t'|msg: %s", c.id, c.requestId, c.target, c.proto, c.port, key.MsFromDuration(s.Duration), s.Result, s.Message)
+	c.log.Log("check-HTTP|id %d|reqID %s|target %s|proto %s|port %d|proxy %s|family %s|latency %sms|result '%t'|msg: %s", c.id, c.requestId, c.target, c.proto, c.port, c.dialer, c.ipFamily, key.MsFromDuration(s.Duration), s.Result, s.Message)
 }
 
 func (c *Check) LogRunError(err error, message string) {
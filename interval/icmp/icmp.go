@@ -8,8 +8,10 @@ import (
 	"fmt"
 	"github.com/exmonitor/exclient/database"
 	"github.com/exmonitor/exlogger"
+	"github.com/exmonitor/watcher/interval/family"
 	"github.com/exmonitor/watcher/interval/spec"
 	"github.com/exmonitor/watcher/interval/status"
+	"github.com/exmonitor/watcher/interval/traceroute"
 	"github.com/exmonitor/watcher/key"
 	"github.com/pkg/errors"
 )
@@ -19,6 +21,8 @@ const (
 	MsgSuccess = "success"
 	MsgTimeout = "failed - timeout"
 
+	msgFailedToResolve = "failed to resolve target"
+
 	msgInternalFailedToInitialisePing = "failed to initialise pinger"
 )
 
@@ -28,6 +32,11 @@ type CheckConfig struct {
 	Interval      int
 	Target        string
 	Timeout       time.Duration
+	IpFamily      string // ipv4, ipv6 or both, empty to let resolver decide
+	// path diagnostics attached to failed results
+	Traceroute        string // icmp or tcp, empty to disable
+	TraceroutePort    int    // destination port of tcp traceroute
+	TracerouteMaxHops int    // zero for default
 
 	//db client
 	DBClient database.ClientInterface
@@ -41,6 +50,8 @@ type Check struct {
 	requestId     string
 	target        string
 	timeout       time.Duration
+	ipFamily      string
+	trace         traceroute.Config
 
 	// db client
 	dbClient database.ClientInterface
@@ -70,6 +81,18 @@ func NewCheck(conf CheckConfig) (*Check, error) {
 	if conf.DBClient == nil {
 		return nil, errors.Wrap(invalidConfigError, "check.DbClient must not be nil")
 	}
+	if err := family.Validate(conf.IpFamily); err != nil {
+		return nil, errors.Wrap(invalidConfigError, err.Error())
+	}
+	trace := traceroute.Config{
+		Method:  conf.Traceroute,
+		Port:    conf.TraceroutePort,
+		MaxHops: conf.TracerouteMaxHops,
+		Timeout: conf.Timeout,
+	}
+	if err := traceroute.Validate(trace); err != nil {
+		return nil, errors.Wrap(invalidConfigError, err.Error())
+	}
 
 	newCheck := &Check{
 		id:            conf.Id,
@@ -77,6 +100,8 @@ func NewCheck(conf CheckConfig) (*Check, error) {
 		interval:      conf.Interval,
 		timeout:       conf.Timeout,
 		target:        conf.Target,
+		ipFamily:      conf.IpFamily,
+		trace:         trace,
 
 		dbClient: conf.DBClient,
 		log:      conf.Logger,
@@ -97,7 +122,12 @@ func (c *Check) RunCheck() {
 	s.SaveToDB()
 }
 
+// run monitoring check, in dual-stack mode each address family is checked separately
 func (c *Check) doCheck() *status.Status {
+	return family.Run(c.ipFamily, c.doFamilyCheck)
+}
+
+func (c *Check) doFamilyCheck(ipFamily string) *status.Status {
 	statusConfig := status.Config{
 		Id:            c.id,
 		ReqId:         c.requestId,
@@ -119,6 +149,16 @@ func (c *Check) doCheck() *status.Status {
 			return s
 		}
 
+		// ping address of the requested family
+		if ipFamily != family.Any {
+			ipAddr, err := family.ResolveIPAddr(c.target, ipFamily)
+			if err != nil {
+				s.Set(false, err, msgFailedToResolve)
+				return s
+			}
+			pinger.SetIPAddr(ipAddr)
+		}
+
 		pinger.Count = pingCount
 		pinger.Timeout = c.timeout
 		pinger.SetPrivileged(true)
@@ -133,6 +173,7 @@ func (c *Check) doCheck() *status.Status {
 	if s.Duration >= c.timeout {
 		s.Set(false, nil, MsgTimeout)
 	}
+	traceroute.Diagnose(c.trace, c.id, c.interval, c.target, ipFamily, s)
 
 	return s
 }
@@ -143,7 +184,7 @@ func (c *Check) GetStringPort() string {
 }
 
 func (c *Check) LogResult(s *status.Status) {
-	c.log.Log("check-ICMP|id %d|reqID %s|target %s|latency %sms|result '%t'|msg: %s", c.id, c.requestId, c.target, key.MsFromDuration(s.Duration), s.Result, s.Message)
+	c.log.Log("check-ICMP|id %d|reqID %s|target %s|family %s|latency %sms|result '%t'|msg: %s", c.id, c.requestId, c.target, c.ipFamily, key.MsFromDuration(s.Duration), s.Result, s.Message)
 }
 
 func (c *Check) LogRunError(err error, message string) {
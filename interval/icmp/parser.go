@@ -16,13 +16,22 @@ Example metadata:
 	"id": 2,
 	"target": "101.102.103.104",
 	"timeout": 2,
+	"ipFamily": "both",
+	"traceroute": "tcp",
+	"traceroutePort": 443,
+	"tracerouteMaxHops": 20
 }
 */
 
 type RawCheck struct {
-	Id      int    `json:"id"`
-	Target  string `json:"target"`
-	Timeout int    `json:"timeout"`
+	Id       int    `json:"id"`
+	Target   string `json:"target"`
+	Timeout  int    `json:"timeout"`
+	IpFamily string `json:"ipFamily"`
+
+	Traceroute        string `json:"traceroute"`
+	TraceroutePort    int    `json:"traceroutePort"`
+	TracerouteMaxHops int    `json:"tracerouteMaxHops"`
 }
 
 func ParseCheck(service *service.Service, dbClient database.ClientInterface, logger *exlogger.Logger) (*Check, error) {
@@ -40,8 +49,13 @@ func ParseCheck(service *service.Service, dbClient database.ClientInterface, log
 		Interval:      service.Interval,
 		Target:        rawCheck.Target,
 		Timeout:       time.Second * time.Duration(rawCheck.Timeout),
-		Logger:        logger,
-		DBClient:      dbClient,
+		IpFamily:      rawCheck.IpFamily,
+
+		Traceroute:        rawCheck.Traceroute,
+		TraceroutePort:    rawCheck.TraceroutePort,
+		TracerouteMaxHops: rawCheck.TracerouteMaxHops,
+		Logger:            logger,
+		DBClient:          dbClient,
 	}
 
 	return NewCheck(checkConfig)
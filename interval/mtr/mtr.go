@@ -0,0 +1,264 @@
+package mtr
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/exmonitor/exclient/database"
+	"github.com/exmonitor/exlogger"
+	"github.com/pkg/errors"
+
+	"github.com/exmonitor/watcher/interval/checkstate"
+	"github.com/exmonitor/watcher/interval/family"
+	"github.com/exmonitor/watcher/interval/spec"
+	"github.com/exmonitor/watcher/interval/status"
+	"github.com/exmonitor/watcher/interval/traceroute"
+	"github.com/exmonitor/watcher/key"
+)
+
+const (
+	msgSuccess           = "success"
+	msgFailedToResolve   = "failed to resolve target"
+	msgFailedNotReached  = "failed - target not reached"
+	msgFailedPathQuality = "failed - path quality issue"
+
+	msgInternalFailedTrace = "failed to run traceroute"
+
+	defaultProbes = 10
+	maxProbes     = 100
+	// routers rate-limit icmp errors, so rounds and probes within them are spread like in mtr
+	roundInterval = time.Second
+	probePacing   = 20 * time.Millisecond
+)
+
+type CheckConfig struct {
+	Id            int
+	FailThreshold int
+	Interval      int
+	Target        string
+	Timeout       time.Duration // time to wait for responses in each round
+	Probes        int           // number of rounds, zero for default
+	MaxHops       int           // zero for default
+	LossHop       int           // loss is checked on this hop and all following hops, zero for the first hop
+	MaxLoss       float64       // max allowed loss in percent
+	Hops          int           // expected hop count, zero to compare with the baseline learned from previous runs
+	IpFamily      string        // ipv4, ipv6 or both, empty to let resolver decide
+
+	//db client
+	DBClient database.ClientInterface
+	Logger   *exlogger.Logger
+}
+
+type Check struct {
+	id            int
+	failThreshold int
+	interval      int
+	requestId     string
+	target        string
+	probes        int
+	lossHop       int
+	maxLoss       float64
+	hops          int
+	ipFamily      string
+	trace         traceroute.Config
+
+	// db client
+	dbClient database.ClientInterface
+	// logger
+	log *exlogger.Logger
+
+	// internals
+	spec.CheckInterface
+}
+
+func NewCheck(conf CheckConfig) (*Check, error) {
+	if conf.Id == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.id must not be zero")
+	}
+	if conf.FailThreshold == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.FailThreshold must not be zero")
+	}
+	if conf.Interval == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.Interval must not be zero")
+	}
+	if conf.Target == "" {
+		return nil, errors.Wrap(invalidConfigError, "conf.Target must not be empty")
+	}
+	if conf.Timeout == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.Timeout must not be zero")
+	}
+	if conf.Probes == 0 {
+		conf.Probes = defaultProbes
+	}
+	if conf.Probes < 0 || conf.Probes > maxProbes {
+		return nil, errors.Wrapf(invalidConfigError, "conf.Probes must be between 1 and %d", maxProbes)
+	}
+	if conf.MaxHops == 0 {
+		conf.MaxHops = traceroute.DefaultMaxHops
+	}
+	// all rounds must finish before the next interval tick
+	round := time.Duration(conf.MaxHops-1)*probePacing + conf.Timeout
+	if round < roundInterval {
+		round = roundInterval
+	}
+	if time.Duration(conf.Probes)*round >= time.Duration(conf.Interval)*time.Second {
+		return nil, errors.Wrapf(invalidConfigError, "conf.Probes %d with conf.Timeout %s do not fit into conf.Interval %ds", conf.Probes, conf.Timeout, conf.Interval)
+	}
+	trace := traceroute.Config{
+		Method:  traceroute.MethodICMP,
+		MaxHops: conf.MaxHops,
+		Timeout: conf.Timeout,
+		Pacing:  probePacing,
+	}
+	if err := traceroute.Validate(trace); err != nil {
+		return nil, errors.Wrap(invalidConfigError, err.Error())
+	}
+	if conf.LossHop == 0 {
+		conf.LossHop = 1
+	}
+	if conf.LossHop < 0 || conf.LossHop > conf.MaxHops {
+		return nil, errors.Wrapf(invalidConfigError, "conf.LossHop must be between 1 and %d", conf.MaxHops)
+	}
+	if conf.MaxLoss < 0 || conf.MaxLoss >= 100 {
+		return nil, errors.Wrap(invalidConfigError, "conf.MaxLoss must be between 0 and 100")
+	}
+	if conf.Hops < 0 || conf.Hops > conf.MaxHops {
+		return nil, errors.Wrapf(invalidConfigError, "conf.Hops must be between 0 and %d", conf.MaxHops)
+	}
+	if conf.DBClient == nil {
+		return nil, errors.Wrap(invalidConfigError, "conf.DBClient must not be nil")
+	}
+	if conf.Logger == nil {
+		return nil, errors.Wrap(invalidConfigError, "conf.Logger must not be nil")
+	}
+	if err := family.Validate(conf.IpFamily); err != nil {
+		return nil, errors.Wrap(invalidConfigError, err.Error())
+	}
+
+	newCheck := &Check{
+		id:            conf.Id,
+		failThreshold: conf.FailThreshold,
+		interval:      conf.Interval,
+		target:        conf.Target,
+		probes:        conf.Probes,
+		lossHop:       conf.LossHop,
+		maxLoss:       conf.MaxLoss,
+		hops:          conf.Hops,
+		ipFamily:      conf.IpFamily,
+		trace:         trace,
+
+		dbClient: conf.DBClient,
+		log:      conf.Logger,
+	}
+
+	return newCheck, nil
+}
+
+// wrapper function used to run in separate thread (goroutine)
+func (c *Check) RunCheck() {
+	// generate unique request ID
+	c.requestId = key.GenerateReqId(c.id)
+	// run monitoring check
+	s := c.doCheck()
+	c.LogResult(s)
+
+	// save result to database
+	s.SaveToDB()
+}
+
+// run monitoring check, in dual-stack mode each address family is checked separately
+func (c *Check) doCheck() *status.Status {
+	return family.Run(c.ipFamily, c.doFamilyCheck)
+}
+
+func (c *Check) doFamilyCheck(ipFamily string) *status.Status {
+	statusConfig := status.Config{
+		Id:            c.id,
+		ReqId:         c.requestId,
+		Interval:      c.interval,
+		FailThreshold: c.failThreshold,
+		DBClient:      c.dbClient,
+	}
+	s, err := status.New(statusConfig)
+	if err != nil {
+		c.LogRunError(err, fmt.Sprintf("failed to init new status for MTR service ID %d", c.id))
+	}
+	tStart := time.Now()
+	defer func() {
+		if s.Duration == 0 {
+			s.Duration = time.Since(tStart)
+		}
+	}()
+
+	ip, err := family.ResolveIPAddr(c.target, ipFamily)
+	if err != nil {
+		s.Set(false, err, msgFailedToResolve)
+		return s
+	}
+
+	rounds := make([][]traceroute.Hop, 0, c.probes)
+	for i := 0; i < c.probes; i++ {
+		roundStart := time.Now()
+		hops, err := traceroute.Trace(c.trace, ip.IP)
+		if err != nil {
+			c.LogRunError(err, msgInternalFailedTrace)
+			s.Set(false, err, msgInternalFailedTrace)
+			return s
+		}
+		rounds = append(rounds, hops)
+		if i < c.probes-1 {
+			time.Sleep(time.Until(roundStart.Add(roundInterval)))
+		}
+	}
+
+	stats := aggregate(rounds, c.trace.MaxHops)
+	path := stats.path()
+	if stats.reached == 0 {
+		s.Set(false, nil, fmt.Sprintf("%s, path: %s", msgFailedNotReached, formatPath(path)))
+		return s
+	}
+	hopCount := stats.hopCount()
+	// latency of the check is the average round trip time to the target
+	s.Duration = path[hopCount-1].avg()
+
+	var problems []string
+	// loss which does not continue to the target is only icmp rate limiting of the router,
+	// so intermediate hop is reported only where loss persisting up to the target starts
+	if loss := stats.targetLoss(); loss > c.maxLoss {
+		if hop := lossStart(path[:hopCount-1], c.lossHop, c.maxLoss); hop != nil {
+			problems = append(problems, fmt.Sprintf("loss %.1f%% from hop %d (%s)", hop.loss(), hop.ttl, strings.Join(hop.addrs, "/")))
+		}
+		problems = append(problems, fmt.Sprintf("loss %.1f%% at target", loss))
+	}
+
+	// without expected hop count, changed route is reported until it was seen in fail threshold
+	// consecutive runs and became the new baseline, so the change always raises an alert
+	expected := c.hops
+	if expected == 0 {
+		expected = routeBaseline(fmt.Sprintf("%d/%s", c.id, ipFamily), hopCount, c.failThreshold, checkstate.Expiration(c.interval))
+	}
+	if expected != 0 && hopCount != expected {
+		problems = append(problems, fmt.Sprintf("route changed, hop count %d instead of %d", hopCount, expected))
+	}
+
+	if len(problems) > 0 {
+		s.Set(false, nil, fmt.Sprintf("%s, %s, path: %s", msgFailedPathQuality, strings.Join(problems, "; "), formatPath(path)))
+		return s
+	}
+	s.Set(true, nil, fmt.Sprintf("%s, %d hops, target loss %.1f%%, path: %s", msgSuccess, hopCount, stats.targetLoss(), formatPath(path)))
+	return s
+}
+
+func (c *Check) GetStringPort() string {
+	// mtr has no port
+	return ""
+}
+
+func (c *Check) LogResult(s *status.Status) {
+	c.log.Log("check-MTR|id %d|reqID %s|target %s|family %s|probes %d|latency %sms|result '%t'|msg: %s", c.id, c.requestId, c.target, c.ipFamily, c.probes, key.MsFromDuration(s.Duration), s.Result, s.Message)
+}
+
+func (c *Check) LogRunError(err error, message string) {
+	c.log.LogError(err, "running check id:%d reqID:%s type:mtr target:%s failed, reason: %s", c.id, c.requestId, c.target, message)
+}
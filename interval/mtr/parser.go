@@ -0,0 +1,67 @@
+package mtr
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/exmonitor/exclient/database"
+	"github.com/exmonitor/exclient/database/spec/service"
+	"github.com/exmonitor/exlogger"
+	"github.com/pkg/errors"
+)
+
+/*
+Example metadata:
+{
+	"id": 22,
+	"target": "101.102.103.104",
+	"timeout": 2,
+	"probes": 10,
+	"maxHops": 30,
+	"lossHop": 3,
+	"maxLoss": 10,
+	"hops": 9,
+	"ipFamily": "ipv4"
+}
+*/
+
+type RawCheck struct {
+	Id       int     `json:"id"`
+	Target   string  `json:"target"`
+	Timeout  int     `json:"timeout"`
+	Probes   int     `json:"probes"`
+	MaxHops  int     `json:"maxHops"`
+	LossHop  int     `json:"lossHop"`
+	MaxLoss  float64 `json:"maxLoss"`
+	Hops     int     `json:"hops"`
+	IpFamily string  `json:"ipFamily"`
+}
+
+func ParseCheck(service *service.Service, dbClient database.ClientInterface, logger *exlogger.Logger) (*Check, error) {
+	var rawCheck RawCheck
+	err := json.Unmarshal([]byte(service.Metadata), &rawCheck)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to parse MTR json metadata for check id %d", service.ID))
+	} else {
+		logger.LogDebug("Successfully parsed MTR json metadata for check id %d", service.ID)
+	}
+
+	checkConfig := CheckConfig{
+		Id:            service.ID,
+		FailThreshold: service.FailThreshold,
+		Interval:      service.Interval,
+		Target:        rawCheck.Target,
+		Timeout:       time.Second * time.Duration(rawCheck.Timeout),
+		Probes:        rawCheck.Probes,
+		MaxHops:       rawCheck.MaxHops,
+		LossHop:       rawCheck.LossHop,
+		MaxLoss:       rawCheck.MaxLoss,
+		Hops:          rawCheck.Hops,
+		IpFamily:      rawCheck.IpFamily,
+		Logger:        logger,
+		DBClient:      dbClient,
+	}
+
+	return NewCheck(checkConfig)
+}
@@ -0,0 +1,189 @@
+package mtr
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/exmonitor/watcher/interval/checkstate"
+	"github.com/exmonitor/watcher/interval/traceroute"
+	"github.com/exmonitor/watcher/key"
+)
+
+// statistics of single hop over all rounds
+type hopStats struct {
+	ttl         int
+	sent        int
+	received    int
+	addrs       []string // responding routers, more than one means load balancing
+	unreachable bool     // router reported the target as unreachable
+	worst       time.Duration
+	total       time.Duration
+}
+
+func (h *hopStats) loss() float64 {
+	if h.sent == 0 {
+		return 0
+	}
+	return 100 * float64(h.sent-h.received) / float64(h.sent)
+}
+
+func (h *hopStats) avg() time.Duration {
+	if h.received == 0 {
+		return 0
+	}
+	return h.total / time.Duration(h.received)
+}
+
+// loss with average and worst latency, ie: "3 198.51.100.1 10.0% 12.30/15.02ms", silent hops are "3 *"
+func (h *hopStats) String() string {
+	if h.received == 0 {
+		return fmt.Sprintf("%d *", h.ttl)
+	}
+	s := fmt.Sprintf("%d %s %.1f%% %s/%sms", h.ttl, strings.Join(h.addrs, "/"), h.loss(), key.MsFromDuration(h.avg()), key.MsFromDuration(h.worst))
+	if h.unreachable {
+		s += " (unreachable)"
+	}
+	return s
+}
+
+func (h *hopStats) add(hop traceroute.Hop) {
+	h.sent++
+	if hop.Addr == "" {
+		return
+	}
+	h.received++
+	h.total += hop.RTT
+	h.unreachable = h.unreachable || hop.Unreachable
+	if hop.RTT > h.worst {
+		h.worst = hop.RTT
+	}
+	for _, addr := range h.addrs {
+		if addr == hop.Addr {
+			return
+		}
+	}
+	h.addrs = append(h.addrs, hop.Addr)
+}
+
+// statistics of the whole path
+type pathStats struct {
+	hops      []*hopStats // indexed by ttl-1
+	rounds    int
+	reached   int         // rounds which reached the target
+	hopCounts map[int]int // number of rounds which reached the target with the ttl
+}
+
+func aggregate(rounds [][]traceroute.Hop, maxHops int) *pathStats {
+	p := &pathStats{
+		hops:      make([]*hopStats, maxHops),
+		rounds:    len(rounds),
+		hopCounts: make(map[int]int),
+	}
+	for i := range p.hops {
+		p.hops[i] = &hopStats{ttl: i + 1}
+	}
+	for _, hops := range rounds {
+		for _, hop := range hops {
+			p.hops[hop.TTL-1].add(hop)
+			if hop.Reached {
+				p.reached++
+				p.hopCounts[hop.TTL]++
+			}
+		}
+	}
+	return p
+}
+
+// returns number of hops to the target seen in most rounds, zero if the target was not reached
+func (p *pathStats) hopCount() int {
+	count, rounds := 0, 0
+	for ttl, n := range p.hopCounts {
+		if n > rounds || (n == rounds && ttl < count) {
+			count, rounds = ttl, n
+		}
+	}
+	return count
+}
+
+func (p *pathStats) targetLoss() float64 {
+	return 100 * float64(p.rounds-p.reached) / float64(p.rounds)
+}
+
+// returns hops up to the farthest target response, or up to the last responding hop if the target was not reached
+func (p *pathStats) path() []*hopStats {
+	length := 0
+	for ttl := range p.hopCounts {
+		if ttl > length {
+			length = ttl
+		}
+	}
+	if length == 0 {
+		for i, hop := range p.hops {
+			if hop.received > 0 {
+				length = i + 1
+			}
+		}
+	}
+	return p.hops[:length]
+}
+
+// returns the first hop from which all following responding hops have loss above maxLoss, hops before fromTTL
+// are not considered, routers which never answer are skipped as they only do not send icmp errors
+func lossStart(hops []*hopStats, fromTTL int, maxLoss float64) *hopStats {
+	var start *hopStats
+	for i := len(hops) - 1; i >= 0 && hops[i].ttl >= fromTTL; i-- {
+		if hops[i].received == 0 {
+			continue
+		}
+		if hops[i].loss() <= maxLoss {
+			break
+		}
+		start = hops[i]
+	}
+	return start
+}
+
+func formatPath(hops []*hopStats) string {
+	parts := make([]string, len(hops))
+	for i, hop := range hops {
+		parts[i] = hop.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// hop count baseline of each check
+var routes = checkstate.NewStore()
+
+type routeState struct {
+	baseline  int // hop count of the known route
+	candidate int // different hop count seen in the last runs
+	runs      int // number of consecutive runs with the candidate hop count
+}
+
+// returns baseline hop count to compare with, zero if it is not known yet,
+// different hop count becomes the new baseline only after it was seen in stableRuns consecutive runs,
+// so the route change is reported in all of them
+func routeBaseline(key string, count int, stableRuns int, ttl time.Duration) int {
+	baseline := 0
+	routes.Update(key, ttl, func(value interface{}) interface{} {
+		state, ok := value.(*routeState)
+		if !ok {
+			return &routeState{baseline: count}
+		}
+		baseline = state.baseline
+		switch {
+		case count == state.baseline:
+			state.candidate, state.runs = 0, 0
+		case count == state.candidate:
+			state.runs++
+		default:
+			state.candidate, state.runs = count, 1
+		}
+		if state.runs >= stableRuns {
+			state.baseline, state.candidate, state.runs = count, 0, 0
+		}
+		return state
+	})
+	return baseline
+}
@@ -0,0 +1,91 @@
+package mtr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/exmonitor/watcher/interval/traceroute"
+)
+
+// round with responding routers up to the target at the ttl
+func round(target int) []traceroute.Hop {
+	hops := make([]traceroute.Hop, target)
+	for i := range hops {
+		hops[i] = traceroute.Hop{TTL: i + 1, Addr: "192.0.2.1", RTT: time.Millisecond, Reached: i == target-1}
+	}
+	return hops
+}
+
+func TestHopCount(t *testing.T) {
+	tests := []struct {
+		name   string
+		rounds [][]traceroute.Hop
+		want   int
+	}{
+		{name: "target not reached", rounds: [][]traceroute.Hop{{{TTL: 1}, {TTL: 2}}}, want: 0},
+		{name: "stable route", rounds: [][]traceroute.Hop{round(3), round(3)}, want: 3},
+		{name: "most rounds win", rounds: [][]traceroute.Hop{round(4), round(3), round(4)}, want: 4},
+		{name: "tie prefers shorter route", rounds: [][]traceroute.Hop{round(4), round(3)}, want: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := aggregate(tt.rounds, 5).hopCount(); got != tt.want {
+				t.Errorf("hopCount() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouteBaseline(t *testing.T) {
+	tests := []struct {
+		name       string
+		stableRuns int
+		counts     []int
+		want       []int // baseline returned for each run
+	}{
+		{name: "first run learns baseline", stableRuns: 3, counts: []int{5, 5}, want: []int{0, 5}},
+		{name: "change reported until stable", stableRuns: 3, counts: []int{5, 6, 6, 6, 6}, want: []int{0, 5, 5, 5, 6}},
+		{name: "single run threshold", stableRuns: 1, counts: []int{5, 6, 6}, want: []int{0, 5, 6}},
+		{name: "return to baseline resets candidate", stableRuns: 2, counts: []int{5, 6, 5, 6, 6, 6}, want: []int{0, 5, 5, 5, 5, 6}},
+		{name: "flapping candidate restarts", stableRuns: 2, counts: []int{5, 6, 7, 7, 7}, want: []int{0, 5, 5, 5, 7}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := "test/" + tt.name
+			for i, count := range tt.counts {
+				if got := routeBaseline(key, count, tt.stableRuns, time.Minute); got != tt.want[i] {
+					t.Fatalf("run %d with hop count %d: routeBaseline() = %d, want %d", i+1, count, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestLossStart(t *testing.T) {
+	hop := func(ttl int, sent int, received int) *hopStats {
+		return &hopStats{ttl: ttl, sent: sent, received: received}
+	}
+	tests := []struct {
+		name    string
+		hops    []*hopStats
+		fromTTL int
+		want    int // ttl of the returned hop, zero for nil
+	}{
+		{name: "no loss", hops: []*hopStats{hop(1, 10, 10), hop(2, 10, 10)}, fromTTL: 1, want: 0},
+		{name: "rate limiting router", hops: []*hopStats{hop(1, 10, 10), hop(2, 10, 5), hop(3, 10, 10)}, fromTTL: 1, want: 0},
+		{name: "persistent loss", hops: []*hopStats{hop(1, 10, 10), hop(2, 10, 5), hop(3, 10, 6)}, fromTTL: 1, want: 2},
+		{name: "silent router is skipped", hops: []*hopStats{hop(1, 10, 5), hop(2, 10, 0), hop(3, 10, 6)}, fromTTL: 1, want: 1},
+		{name: "hops before loss hop are ignored", hops: []*hopStats{hop(1, 10, 5), hop(2, 10, 5), hop(3, 10, 6)}, fromTTL: 2, want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := 0
+			if start := lossStart(tt.hops, tt.fromTTL, 10); start != nil {
+				got = start.ttl
+			}
+			if got != tt.want {
+				t.Errorf("lossStart() = hop %d, want hop %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package ntp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// seconds between NTP epoch (1900) and unix epoch (1970)
+	ntpEpochOffset = 2208988800
+
+	packetSize = 48
+	// leap indicator 0, version 4, mode 3 (client)
+	clientHeader = 0<<6 | 4<<3 | 3
+	modeServer   = 4
+
+	// leap indicator value meaning that server clock is not synchronized
+	LeapNotSynchronized = 3
+)
+
+// server response of single SNTP query (RFC 4330)
+type Response struct {
+	LeapIndicator  int
+	Stratum        int
+	ReferenceID    string
+	RootDelay      time.Duration
+	RootDispersion time.Duration
+	// local clock offset, positive value means local clock is behind the server
+	Offset time.Duration
+	// round trip delay without server processing time
+	RTT time.Duration
+}
+
+// query NTP server, network is udp, udp4 or udp6 and address is host:port
+func Query(network string, address string, timeout time.Duration) (*Response, error) {
+	conn, err := net.DialTimeout(network, address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	request := make([]byte, packetSize)
+	request[0] = clientHeader
+	tSend := time.Now()
+	// transmit timestamp is echoed back as origin timestamp, so we can match the response
+	origin := toNtpTime(tSend)
+	binary.BigEndian.PutUint64(request[40:], origin)
+	if _, err := conn.Write(request); err != nil {
+		return nil, err
+	}
+
+	response := make([]byte, packetSize)
+	for {
+		n, err := conn.Read(response)
+		if err != nil {
+			return nil, err
+		}
+		// drop short or unrelated packets
+		if n >= packetSize && binary.BigEndian.Uint64(response[24:]) == origin {
+			break
+		}
+	}
+	// monotonic clock is used for local receive time
+	tReceive := tSend.Add(time.Since(tSend))
+
+	if mode := response[0] & 0x7; mode != modeServer {
+		return nil, fmt.Errorf("invalid mode %d in response", mode)
+	}
+	r := &Response{
+		LeapIndicator:  int(response[0] >> 6),
+		Stratum:        int(response[1]),
+		RootDelay:      fromNtpShort(binary.BigEndian.Uint32(response[4:])),
+		RootDispersion: fromNtpShort(binary.BigEndian.Uint32(response[8:])),
+		ReferenceID:    referenceID(response[1], response[12:16]),
+	}
+	// stratum 0 is kiss-o'-death, reference id contains the reason (ie: RATE, DENY)
+	if r.Stratum == 0 {
+		return nil, errors.Errorf("kiss-o'-death from server: %s", r.ReferenceID)
+	}
+
+	serverReceive := fromNtpTime(binary.BigEndian.Uint64(response[32:]))
+	serverTransmit := fromNtpTime(binary.BigEndian.Uint64(response[40:]))
+	r.Offset = (serverReceive.Sub(tSend) + serverTransmit.Sub(tReceive)) / 2
+	r.RTT = tReceive.Sub(tSend) - serverTransmit.Sub(serverReceive)
+	if r.RTT < 0 {
+		r.RTT = 0
+	}
+
+	return r, nil
+}
+
+// reference id is ascii code for stratum 0 and 1, ipv4 address (or hash of ipv6 address) otherwise
+func referenceID(stratum byte, id []byte) string {
+	if stratum <= 1 {
+		return string(trimZero(id))
+	}
+	return net.IP(id).String()
+}
+
+func trimZero(b []byte) []byte {
+	for len(b) > 0 && b[len(b)-1] == 0 {
+		b = b[:len(b)-1]
+	}
+	return b
+}
+
+func toNtpTime(t time.Time) uint64 {
+	seconds := uint64(t.Unix() + ntpEpochOffset)
+	fraction := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+	return seconds<<32 | fraction
+}
+
+func fromNtpTime(ntpTime uint64) time.Time {
+	seconds := int64(ntpTime>>32) - ntpEpochOffset
+	nanoseconds := (ntpTime & 0xffffffff) * uint64(time.Second) >> 32
+	return time.Unix(seconds, int64(nanoseconds))
+}
+
+// 16.16 fixed point seconds
+func fromNtpShort(ntpShort uint32) time.Duration {
+	return time.Duration(uint64(ntpShort) * uint64(time.Second) >> 16)
+}
@@ -0,0 +1,145 @@
+package ntp
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNtpTime(t *testing.T) {
+	for _, want := range []time.Time{
+		time.Unix(0, 0),
+		time.Date(2026, 10, 19, 12, 30, 15, 500000000, time.UTC),
+	} {
+		got := fromNtpTime(toNtpTime(want))
+		if d := got.Sub(want); d < -time.Nanosecond || d > time.Nanosecond {
+			t.Errorf("fromNtpTime(toNtpTime(%s)) = %s", want, got)
+		}
+	}
+	if got := fromNtpShort(0x00018000); got != time.Millisecond*1500 {
+		t.Errorf("fromNtpShort() = %s, want 1.5s", got)
+	}
+}
+
+func TestReferenceID(t *testing.T) {
+	tests := []struct {
+		stratum byte
+		id      []byte
+		want    string
+	}{
+		{stratum: 0, id: []byte("RATE"), want: "RATE"},
+		{stratum: 1, id: []byte{'G', 'P', 'S', 0}, want: "GPS"},
+		{stratum: 2, id: []byte{192, 0, 2, 1}, want: "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		if got := referenceID(tt.stratum, tt.id); got != tt.want {
+			t.Errorf("referenceID(%d, %v) = %q, want %q", tt.stratum, tt.id, got, tt.want)
+		}
+	}
+}
+
+// answer each request with responses built from the request, returns server address
+func serve(t *testing.T, respond func(request []byte) [][]byte) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			for _, response := range respond(buf[:n]) {
+				conn.WriteTo(response, addr)
+			}
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+// server response with clock shifted by offset
+func response(request []byte, stratum byte, refID string, offset time.Duration) []byte {
+	r := make([]byte, packetSize)
+	r[0] = 0<<6 | 4<<3 | modeServer
+	r[1] = stratum
+	binary.BigEndian.PutUint32(r[4:], 0x00008000)
+	copy(r[12:16], refID)
+	copy(r[24:32], request[40:48])
+	now := toNtpTime(time.Now().Add(offset))
+	binary.BigEndian.PutUint64(r[32:], now)
+	binary.BigEndian.PutUint64(r[40:], now)
+	return r
+}
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		name       string
+		respond    func(request []byte) [][]byte
+		wantOffset time.Duration
+		wantErr    string
+	}{
+		{
+			name: "clock ahead of local",
+			respond: func(request []byte) [][]byte {
+				return [][]byte{response(request, 2, "\xc0\x00\x02\x01", time.Second*3)}
+			},
+			wantOffset: time.Second * 3,
+		},
+		{
+			name: "clock behind local",
+			respond: func(request []byte) [][]byte {
+				return [][]byte{response(request, 1, "GPS", -time.Second*2)}
+			},
+			wantOffset: -time.Second * 2,
+		},
+		{
+			name: "unrelated and short packets are dropped",
+			respond: func(request []byte) [][]byte {
+				unrelated := response(request, 2, "", time.Hour)
+				unrelated[24]++
+				return [][]byte{unrelated, []byte{0x24}, response(request, 2, "", 0)}
+			},
+		},
+		{
+			name: "kiss-o'-death",
+			respond: func(request []byte) [][]byte {
+				return [][]byte{response(request, 0, "RATE", 0)}
+			},
+			wantErr: "kiss-o'-death from server: RATE",
+		},
+		{
+			name: "client mode response",
+			respond: func(request []byte) [][]byte {
+				r := response(request, 2, "", 0)
+				r[0] = clientHeader
+				return [][]byte{r}
+			},
+			wantErr: "invalid mode 3 in response",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Query("udp", serve(t, tt.respond), time.Second)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Query() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d := r.Offset - tt.wantOffset; d < -time.Millisecond*100 || d > time.Millisecond*100 {
+				t.Errorf("Query() offset = %s, want %s", r.Offset, tt.wantOffset)
+			}
+			if r.RootDelay != time.Millisecond*500 {
+				t.Errorf("Query() root delay = %s, want 500ms", r.RootDelay)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package ntp
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/exmonitor/exclient/database"
+	"github.com/exmonitor/exlogger"
+	"github.com/pkg/errors"
+
+	"github.com/exmonitor/watcher/interval/family"
+	"github.com/exmonitor/watcher/interval/spec"
+	"github.com/exmonitor/watcher/interval/status"
+	"github.com/exmonitor/watcher/key"
+)
+
+const (
+	msgSuccess              = "success"
+	msgFailedQuery          = "failed to query ntp server"
+	msgFailedUnsynchronized = "failed - server clock is not synchronized"
+	msgFailedStratum        = "failed - stratum too high"
+	msgFailedOffset         = "failed - clock offset too high"
+
+	DefaultPort = 123
+)
+
+type CheckConfig struct {
+	Id            int
+	FailThreshold int
+	Interval      int
+	Target        string
+	Port          int // default 123
+	Timeout       time.Duration
+	IpFamily      string // ipv4, ipv6 or both, empty to let resolver decide
+
+	// thresholds, zero value disables the threshold
+	MaxOffset  time.Duration // max absolute offset between watcher and server clock
+	MaxStratum int
+
+	//db client
+	DBClient database.ClientInterface
+	Logger   *exlogger.Logger
+}
+
+type Check struct {
+	id            int
+	failThreshold int
+	interval      int
+	requestId     string
+	target        string
+	port          int
+	timeout       time.Duration
+	ipFamily      string
+
+	maxOffset  time.Duration
+	maxStratum int
+
+	// db client
+	dbClient database.ClientInterface
+	// logger
+	log *exlogger.Logger
+
+	// internals
+	spec.CheckInterface
+}
+
+func NewCheck(conf CheckConfig) (*Check, error) {
+	if conf.Id == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.id must not be zero")
+	}
+	if conf.FailThreshold == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.FailThreshold must not be zero")
+	}
+	if conf.Interval == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.Interval must not be zero")
+	}
+	if conf.Target == "" {
+		return nil, errors.Wrap(invalidConfigError, "conf.Target must not be empty")
+	}
+	if conf.Timeout == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.Timeout must not be zero")
+	}
+	if conf.MaxOffset < 0 || conf.MaxStratum < 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.MaxOffset and conf.MaxStratum must not be negative")
+	}
+	if conf.DBClient == nil {
+		return nil, errors.Wrap(invalidConfigError, "conf.DBClient must not be nil")
+	}
+	if conf.Logger == nil {
+		return nil, errors.Wrap(invalidConfigError, "conf.Logger must not be nil")
+	}
+	if err := family.Validate(conf.IpFamily); err != nil {
+		return nil, errors.Wrap(invalidConfigError, err.Error())
+	}
+	if conf.Port == 0 {
+		conf.Port = DefaultPort
+	}
+
+	newCheck := &Check{
+		id:            conf.Id,
+		failThreshold: conf.FailThreshold,
+		interval:      conf.Interval,
+		timeout:       conf.Timeout,
+		port:          conf.Port,
+		target:        conf.Target,
+		ipFamily:      conf.IpFamily,
+
+		maxOffset:  conf.MaxOffset,
+		maxStratum: conf.MaxStratum,
+
+		dbClient: conf.DBClient,
+		log:      conf.Logger,
+	}
+
+	return newCheck, nil
+}
+
+// wrapper function used to run in separate thread (goroutine)
+func (c *Check) RunCheck() {
+	// generate unique request ID
+	c.requestId = key.GenerateReqId(c.id)
+	// run monitoring check
+	s := c.doCheck()
+	c.LogResult(s)
+
+	// save result to database
+	s.SaveToDB()
+}
+
+// run monitoring check, in dual-stack mode each address family is checked separately
+func (c *Check) doCheck() *status.Status {
+	return family.Run(c.ipFamily, c.doFamilyCheck)
+}
+
+func (c *Check) doFamilyCheck(ipFamily string) *status.Status {
+	statusConfig := status.Config{
+		Id:            c.id,
+		ReqId:         c.requestId,
+		Interval:      c.interval,
+		FailThreshold: c.failThreshold,
+		DBClient:      c.dbClient,
+	}
+	s, err := status.New(statusConfig)
+	if err != nil {
+		c.LogRunError(err, fmt.Sprintf("failed to init new status for NTP service ID %d", c.id))
+	}
+	tStart := time.Now()
+	defer func() {
+		s.Duration = time.Since(tStart)
+	}()
+
+	r, err := Query(family.Network("udp", ipFamily), net.JoinHostPort(c.target, strconv.Itoa(c.port)), c.timeout)
+	if err != nil {
+		s.Set(false, err, msgFailedQuery)
+		return s
+	}
+	details := Format(r)
+
+	if r.LeapIndicator == LeapNotSynchronized {
+		s.Set(false, nil, fmt.Sprintf("%s, %s", msgFailedUnsynchronized, details))
+		return s
+	}
+	if c.maxStratum > 0 && r.Stratum > c.maxStratum {
+		s.Set(false, nil, fmt.Sprintf("%s, stratum %d exceeds %d, %s", msgFailedStratum, r.Stratum, c.maxStratum, details))
+		return s
+	}
+	if c.maxOffset > 0 && absDuration(r.Offset) > c.maxOffset {
+		s.Set(false, nil, fmt.Sprintf("%s, offset %sms exceeds %sms, %s", msgFailedOffset, key.MsFromDuration(r.Offset), key.MsFromDuration(c.maxOffset), details))
+		return s
+	}
+
+	s.Set(true, nil, fmt.Sprintf("%s, %s", msgSuccess, details))
+	return s
+}
+
+// human readable summary of the response
+func Format(r *Response) string {
+	return fmt.Sprintf("offset %sms, stratum %d, reference %s, root delay %sms, root dispersion %sms", key.MsFromDuration(r.Offset), r.Stratum, r.ReferenceID, key.MsFromDuration(r.RootDelay), key.MsFromDuration(r.RootDispersion))
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+func (c *Check) GetStringPort() string {
+	return fmt.Sprintf(":%d", c.port)
+}
+
+func (c *Check) LogResult(s *status.Status) {
+	c.log.Log("check-NTP|id %d|reqID %s|target %s|port %d|family %s|latency %sms|result '%t'|msg: %s", c.id, c.requestId, c.target, c.port, c.ipFamily, key.MsFromDuration(s.Duration), s.Result, s.Message)
+}
+
+func (c *Check) LogRunError(err error, message string) {
+	c.log.LogError(err, "running check id:%d reqID:%s type:ntp target:%s:%d failed, reason: %s", c.id, c.requestId, c.target, c.port, message)
+}
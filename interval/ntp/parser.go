@@ -0,0 +1,61 @@
+package ntp
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/exmonitor/exclient/database"
+	"github.com/exmonitor/exclient/database/spec/service"
+	"github.com/exmonitor/exlogger"
+	"github.com/pkg/errors"
+)
+
+/*
+Example metadata:
+{
+	"id": 11,
+	"target": "ntp1.domain.cz",
+	"port": 123,
+	"timeout": 5,
+	"maxOffset": 100,
+	"maxStratum": 3,
+	"ipFamily": "both"
+}
+*/
+
+type RawCheck struct {
+	Id         int    `json:"id"`
+	Target     string `json:"target"`
+	Port       int    `json:"port"`
+	Timeout    int    `json:"timeout"`
+	MaxOffset  int    `json:"maxOffset"` // in milliseconds
+	MaxStratum int    `json:"maxStratum"`
+	IpFamily   string `json:"ipFamily"`
+}
+
+func ParseCheck(service *service.Service, dbClient database.ClientInterface, logger *exlogger.Logger) (*Check, error) {
+	var rawCheck RawCheck
+	err := json.Unmarshal([]byte(service.Metadata), &rawCheck)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to parse NTP json metadata for check id %d", service.ID))
+	} else {
+		logger.LogDebug("Successfully parsed NTP json metadata for check id %d", service.ID)
+	}
+
+	checkConfig := CheckConfig{
+		Id:            service.ID,
+		FailThreshold: service.FailThreshold,
+		Interval:      service.Interval,
+		Target:        rawCheck.Target,
+		Port:          rawCheck.Port,
+		Timeout:       time.Second * time.Duration(rawCheck.Timeout),
+		MaxOffset:     time.Millisecond * time.Duration(rawCheck.MaxOffset),
+		MaxStratum:    rawCheck.MaxStratum,
+		IpFamily:      rawCheck.IpFamily,
+		Logger:        logger,
+		DBClient:      dbClient,
+	}
+
+	return NewCheck(checkConfig)
+}
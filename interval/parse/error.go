@@ -0,0 +1,5 @@
+package parse
+
+import "errors"
+
+var unknownServiceTypeError error = errors.New("unknown service type")
@@ -4,12 +4,30 @@ import (
 	"github.com/exmonitor/exclient/database"
 	"github.com/exmonitor/exclient/database/spec/service"
 	"github.com/exmonitor/exlogger"
+	"github.com/pkg/errors"
 
+	"github.com/exmonitor/watcher/interval/command"
+	"github.com/exmonitor/watcher/interval/db"
+	"github.com/exmonitor/watcher/interval/dns"
+	"github.com/exmonitor/watcher/interval/dnsbl"
+	"github.com/exmonitor/watcher/interval/domain"
+	"github.com/exmonitor/watcher/interval/email"
+	"github.com/exmonitor/watcher/interval/grpc"
+	"github.com/exmonitor/watcher/interval/heartbeat"
 	"github.com/exmonitor/watcher/interval/http"
 	"github.com/exmonitor/watcher/interval/icmp"
+	"github.com/exmonitor/watcher/interval/mtr"
+	"github.com/exmonitor/watcher/interval/ntp"
+	"github.com/exmonitor/watcher/interval/script"
+	"github.com/exmonitor/watcher/interval/smtp"
+	"github.com/exmonitor/watcher/interval/spec"
+	"github.com/exmonitor/watcher/interval/ssh"
 	"github.com/exmonitor/watcher/interval/tcp"
+	"github.com/exmonitor/watcher/interval/tlscheck"
+	"github.com/exmonitor/watcher/interval/transaction"
+	"github.com/exmonitor/watcher/interval/udp"
+	"github.com/exmonitor/watcher/interval/websocket"
 	"github.com/exmonitor/watcher/key"
-	"github.com/exmonitor/watcher/interval/spec"
 )
 
 func ParseCheck(s *service.Service, dbClient database.ClientInterface, logger *exlogger.Logger) (spec.CheckInterface, error) {
@@ -25,6 +43,59 @@ func ParseCheck(s *service.Service, dbClient database.ClientInterface, logger *e
 	case key.ServiceTypeIcmp:
 		check, err = icmp.ParseCheck(s, dbClient, logger)
 		break
+	case key.ServiceTypeTls:
+		check, err = tlscheck.ParseCheck(s, dbClient, logger)
+		break
+	case key.ServiceTypeSmtp:
+		check, err = smtp.ParseCheck(s, dbClient, logger)
+		break
+	case key.ServiceTypeEmail:
+		check, err = email.ParseCheck(s, dbClient, logger)
+		break
+	case key.ServiceTypeMysql, key.ServiceTypePostgres, key.ServiceTypeRedis:
+		check, err = db.ParseCheck(s, dbClient, logger)
+		break
+	case key.ServiceTypeUdp:
+		check, err = udp.ParseCheck(s, dbClient, logger)
+		break
+	case key.ServiceTypeNtp:
+		check, err = ntp.ParseCheck(s, dbClient, logger)
+		break
+	case key.ServiceTypeSsh:
+		check, err = ssh.ParseCheck(s, dbClient, logger)
+		break
+	case key.ServiceTypeGrpc:
+		check, err = grpc.ParseCheck(s, dbClient, logger)
+		break
+	case key.ServiceTypeWebsocket:
+		check, err = websocket.ParseCheck(s, dbClient, logger)
+		break
+	case key.ServiceTypeTransaction:
+		check, err = transaction.ParseCheck(s, dbClient, logger)
+		break
+	case key.ServiceTypeScript:
+		check, err = script.ParseCheck(s, dbClient, logger)
+		break
+	case key.ServiceTypeCommand:
+		check, err = command.ParseCheck(s, dbClient, logger)
+		break
+	case key.ServiceTypeHeartbeat:
+		check, err = heartbeat.ParseCheck(s, dbClient, logger)
+		break
+	case key.ServiceTypeDomain:
+		check, err = domain.ParseCheck(s, dbClient, logger)
+		break
+	case key.ServiceTypeDnsbl:
+		check, err = dnsbl.ParseCheck(s, dbClient, logger)
+		break
+	case key.ServiceTypeDns:
+		check, err = dns.ParseCheck(s, dbClient, logger)
+		break
+	case key.ServiceTypeMtr:
+		check, err = mtr.ParseCheck(s, dbClient, logger)
+		break
+	default:
+		return nil, errors.Wrapf(unknownServiceTypeError, "service id %d has type %d", s.ID, s.Type)
 	}
 
 	return check, err
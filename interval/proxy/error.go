@@ -0,0 +1,36 @@
+package proxy
+
+import (
+	"errors"
+	"net/url"
+)
+
+var invalidConfigError error = errors.New("invalid proxy config")
+
+// error caused by the proxy itself and not by the target behind the proxy
+type proxyError struct {
+	proxy string
+	err   error
+}
+
+func (e *proxyError) Error() string {
+	return "proxy " + e.proxy + " failed: " + e.err.Error()
+}
+
+func (e *proxyError) Unwrap() error {
+	return e.err
+}
+
+// returns true if error was caused by the proxy and not by the target
+func IsProxyError(err error) bool {
+	var pErr *proxyError
+	return errors.As(err, &pErr)
+}
+
+// hide password from proxy url, so it can be used in logs and messages
+func redacted(u *url.URL) string {
+	if u.User == nil {
+		return u.Scheme + "://" + u.Host
+	}
+	return u.Scheme + "://" + u.User.Username() + "@" + u.Host
+}
@@ -0,0 +1,161 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// SOCKS5 protocol, RFC 1928 and RFC 1929
+const (
+	socks5Version = 0x05
+
+	socks5AuthNone         = 0x00
+	socks5AuthPassword     = 0x02
+	socks5AuthNoAcceptable = 0xff
+	socks5AuthVersion      = 0x01
+
+	socks5CmdConnect = 0x01
+
+	socks5AddrIPv4   = 0x01
+	socks5AddrDomain = 0x03
+	socks5AddrIPv6   = 0x04
+)
+
+// reply codes which means that proxy works, but the target is not reachable
+var socks5TargetFailures = map[byte]string{
+	0x03: "network unreachable",
+	0x04: "host unreachable",
+	0x05: "connection refused",
+	0x06: "TTL expired",
+}
+
+// reply codes caused by the proxy itself
+var socks5ProxyFailures = map[byte]string{
+	0x01: "general SOCKS server failure",
+	0x02: "connection not allowed by ruleset",
+	0x07: "command not supported",
+	0x08: "address type not supported",
+}
+
+// open tunnel to the addr via SOCKS5 proxy
+func (d *Dialer) connectSOCKS5(conn net.Conn, addr string) error {
+	host, portString, err := net.SplitHostPort(addr)
+	if err != nil {
+		return errors.Wrapf(err, "invalid address %s", addr)
+	}
+	port, err := strconv.Atoi(portString)
+	if err != nil {
+		return errors.Wrapf(err, "invalid port in address %s", addr)
+	}
+
+	// negotiate authentication method
+	method := byte(socks5AuthNone)
+	if d.proxy.User != nil {
+		method = socks5AuthPassword
+	}
+	if _, err := conn.Write([]byte{socks5Version, 1, method}); err != nil {
+		return d.socks5Error(err)
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return d.socks5Error(err)
+	}
+	if reply[0] != socks5Version {
+		return d.socks5Error(fmt.Errorf("unexpected protocol version %d", reply[0]))
+	}
+	if reply[1] == socks5AuthNoAcceptable || reply[1] != method {
+		return d.socks5Error(fmt.Errorf("authentication method was not accepted"))
+	}
+
+	// username and password authentication
+	if method == socks5AuthPassword {
+		username := d.proxy.User.Username()
+		password, _ := d.proxy.User.Password()
+		if len(username) > 255 || len(password) > 255 {
+			return d.socks5Error(fmt.Errorf("username or password is too long"))
+		}
+		auth := []byte{socks5AuthVersion, byte(len(username))}
+		auth = append(auth, username...)
+		auth = append(auth, byte(len(password)))
+		auth = append(auth, password...)
+		if _, err := conn.Write(auth); err != nil {
+			return d.socks5Error(err)
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return d.socks5Error(err)
+		}
+		if reply[1] != 0x00 {
+			return d.socks5Error(fmt.Errorf("authentication failed"))
+		}
+	}
+
+	// connect request
+	req := []byte{socks5Version, socks5CmdConnect, 0x00}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			req = append(req, socks5AddrIPv4)
+			req = append(req, ip4...)
+		} else {
+			req = append(req, socks5AddrIPv6)
+			req = append(req, ip.To16()...)
+		}
+	} else {
+		if len(host) > 255 {
+			return fmt.Errorf("hostname %s is too long", host)
+		}
+		req = append(req, socks5AddrDomain, byte(len(host)))
+		req = append(req, host...)
+	}
+	req = append(req, byte(port>>8), byte(port))
+	if _, err := conn.Write(req); err != nil {
+		return d.socks5Error(err)
+	}
+
+	// reply header: version, reply code, reserved, address type
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return d.socks5Error(err)
+	}
+	if reason, ok := socks5TargetFailures[header[1]]; ok {
+		return fmt.Errorf("proxy %s could not connect to %s, %s", redacted(d.proxy), addr, reason)
+	}
+	if header[1] != 0x00 {
+		reason, ok := socks5ProxyFailures[header[1]]
+		if !ok {
+			reason = fmt.Sprintf("unknown reply code %d", header[1])
+		}
+		return d.socks5Error(errors.New(reason))
+	}
+
+	// read and drop bound address
+	var addrLen int
+	switch header[3] {
+	case socks5AddrIPv4:
+		addrLen = net.IPv4len
+	case socks5AddrIPv6:
+		addrLen = net.IPv6len
+	case socks5AddrDomain:
+		l := make([]byte, 1)
+		if _, err := io.ReadFull(conn, l); err != nil {
+			return d.socks5Error(err)
+		}
+		addrLen = int(l[0])
+	default:
+		return d.socks5Error(fmt.Errorf("unknown address type %d", header[3]))
+	}
+	// address is followed by 2 bytes of port
+	bound := make([]byte, addrLen+2)
+	if _, err := io.ReadFull(conn, bound); err != nil {
+		return d.socks5Error(err)
+	}
+
+	return nil
+}
+
+func (d *Dialer) socks5Error(err error) error {
+	return &proxyError{proxy: redacted(d.proxy), err: err}
+}
@@ -0,0 +1,301 @@
+package script
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.starlark.net/lib/json"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+
+	"github.com/exmonitor/watcher/interval/proxy"
+)
+
+/*
+Built-in helpers available to the script:
+	http.get(url, headers={}, timeout=0, insecure=False)
+	http.post(url, body="", headers={}, timeout=0, insecure=False)
+	http.request(method, url, body="", headers={}, timeout=0, insecure=False)
+		-> struct(ok, error, status, body, headers, url, latency_ms)
+	tcp.connect(host, port, send="", read=False, timeout=0)
+		-> struct(ok, error, response, latency_ms)
+	dns.lookup(name, type="A", timeout=0)
+		-> struct(ok, error, records, latency_ms)
+	json.encode(value), json.decode(string), json.indent(string)
+
+Network failures dont stop the script, they are returned in the ok and error fields.
+Timeout is in seconds, zero means the rest of the script time budget.
+*/
+
+const (
+	// thread local keys
+	localContext = "context"
+	localDialer  = "dialer"
+
+	// max size of the http body and tcp response passed to the script
+	maxResponseSize = 1024 * 1024
+	tcpReadSize     = 4096
+)
+
+var predeclared = starlark.StringDict{
+	"http": &starlarkstruct.Module{
+		Name: "http",
+		Members: starlark.StringDict{
+			"get":     starlark.NewBuiltin("http.get", httpGet),
+			"post":    starlark.NewBuiltin("http.post", httpPost),
+			"request": starlark.NewBuiltin("http.request", httpRequest),
+		},
+	},
+	"tcp": &starlarkstruct.Module{
+		Name: "tcp",
+		Members: starlark.StringDict{
+			"connect": starlark.NewBuiltin("tcp.connect", tcpConnect),
+		},
+	},
+	"dns": &starlarkstruct.Module{
+		Name: "dns",
+		Members: starlark.StringDict{
+			"lookup": starlark.NewBuiltin("dns.lookup", dnsLookup),
+		},
+	},
+	"json": json.Module,
+}
+
+func init() {
+	predeclared.Freeze()
+}
+
+func httpGet(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var url string
+	var headers *starlark.Dict
+	var timeout int
+	var insecure bool
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "url", &url, "headers?", &headers, "timeout?", &timeout, "insecure?", &insecure); err != nil {
+		return nil, err
+	}
+	return doHttpRequest(thread, b, http.MethodGet, url, "", headers, timeout, insecure)
+}
+
+func httpPost(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var url, body string
+	var headers *starlark.Dict
+	var timeout int
+	var insecure bool
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "url", &url, "body?", &body, "headers?", &headers, "timeout?", &timeout, "insecure?", &insecure); err != nil {
+		return nil, err
+	}
+	return doHttpRequest(thread, b, http.MethodPost, url, body, headers, timeout, insecure)
+}
+
+func httpRequest(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var method, url, body string
+	var headers *starlark.Dict
+	var timeout int
+	var insecure bool
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "method", &method, "url", &url, "body?", &body, "headers?", &headers, "timeout?", &timeout, "insecure?", &insecure); err != nil {
+		return nil, err
+	}
+	return doHttpRequest(thread, b, strings.ToUpper(method), url, body, headers, timeout, insecure)
+}
+
+func doHttpRequest(thread *starlark.Thread, b *starlark.Builtin, method string, url string, body string, headers *starlark.Dict, timeout int, insecure bool) (starlark.Value, error) {
+	ctx, cancel := callContext(thread, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, url, strings.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", b.Name(), err)
+	}
+	if headers != nil {
+		for _, item := range headers.Items() {
+			name, ok1 := starlark.AsString(item[0])
+			value, ok2 := starlark.AsString(item[1])
+			if !ok1 || !ok2 {
+				return nil, fmt.Errorf("%s: headers must be dict of strings", b.Name())
+			}
+			if strings.EqualFold(name, "Host") {
+				req.Host = value
+			} else {
+				req.Header.Set(name, value)
+			}
+		}
+	}
+
+	transport := &http.Transport{
+		DialContext:     threadDialer(thread).DialContext,
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: insecure},
+	}
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport}
+
+	tStart := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return httpResult(err, 0, "", starlark.NewDict(0), url, time.Since(tStart)), nil
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	latency := time.Since(tStart)
+
+	respHeaders := starlark.NewDict(len(resp.Header))
+	for name, values := range resp.Header {
+		respHeaders.SetKey(starlark.String(strings.ToLower(name)), starlark.String(strings.Join(values, ", ")))
+	}
+	return httpResult(err, resp.StatusCode, string(respBody), respHeaders, resp.Request.URL.String(), latency), nil
+}
+
+func httpResult(err error, statusCode int, body string, headers *starlark.Dict, url string, latency time.Duration) starlark.Value {
+	return starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+		"ok":         starlark.Bool(err == nil),
+		"error":      errorValue(err),
+		"status":     starlark.MakeInt(statusCode),
+		"body":       starlark.String(body),
+		"headers":    headers,
+		"url":        starlark.String(url),
+		"latency_ms": latencyValue(latency),
+	})
+}
+
+func tcpConnect(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var host, send string
+	var port, timeout int
+	var read bool
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "host", &host, "port", &port, "send?", &send, "read?", &read, "timeout?", &timeout); err != nil {
+		return nil, err
+	}
+	ctx, cancel := callContext(thread, timeout)
+	defer cancel()
+
+	tStart := time.Now()
+	response, err := tcpExchange(ctx, threadDialer(thread), net.JoinHostPort(host, strconv.Itoa(port)), send, read)
+	return starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+		"ok":         starlark.Bool(err == nil),
+		"error":      errorValue(err),
+		"response":   starlark.String(response),
+		"latency_ms": latencyValue(time.Since(tStart)),
+	}), nil
+}
+
+// open tcp connection, optionally send data and read the first response chunk
+func tcpExchange(ctx context.Context, dialer *proxy.Dialer, addr string, send string, read bool) (string, error) {
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if send != "" {
+		if _, err := conn.Write([]byte(send)); err != nil {
+			return "", err
+		}
+	}
+	if !read {
+		return "", nil
+	}
+	buf := make([]byte, tcpReadSize)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return "", err
+	}
+	return string(buf[:n]), nil
+}
+
+func dnsLookup(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var name string
+	var recordType = "A"
+	var timeout int
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "name", &name, "type?", &recordType, "timeout?", &timeout); err != nil {
+		return nil, err
+	}
+	ctx, cancel := callContext(thread, timeout)
+	defer cancel()
+
+	tStart := time.Now()
+	var records []string
+	var err error
+	resolver := net.DefaultResolver
+	switch strings.ToUpper(recordType) {
+	case "A", "AAAA":
+		network := "ip4"
+		if strings.ToUpper(recordType) == "AAAA" {
+			network = "ip6"
+		}
+		var ips []net.IP
+		ips, err = resolver.LookupIP(ctx, network, name)
+		for _, ip := range ips {
+			records = append(records, ip.String())
+		}
+	case "CNAME":
+		var cname string
+		cname, err = resolver.LookupCNAME(ctx, name)
+		if err == nil {
+			records = append(records, cname)
+		}
+	case "MX":
+		var mxs []*net.MX
+		mxs, err = resolver.LookupMX(ctx, name)
+		for _, mx := range mxs {
+			records = append(records, fmt.Sprintf("%d %s", mx.Pref, mx.Host))
+		}
+	case "NS":
+		var nss []*net.NS
+		nss, err = resolver.LookupNS(ctx, name)
+		for _, ns := range nss {
+			records = append(records, ns.Host)
+		}
+	case "TXT":
+		records, err = resolver.LookupTXT(ctx, name)
+	case "PTR":
+		records, err = resolver.LookupAddr(ctx, name)
+	default:
+		return nil, fmt.Errorf("%s: unsupported record type %q", b.Name(), recordType)
+	}
+
+	values := make([]starlark.Value, len(records))
+	for i, record := range records {
+		values[i] = starlark.String(record)
+	}
+	return starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+		"ok":         starlark.Bool(err == nil),
+		"error":      errorValue(err),
+		"records":    starlark.NewList(values),
+		"latency_ms": latencyValue(time.Since(tStart)),
+	}), nil
+}
+
+// returns context for single network call, limited by the script time budget
+func callContext(thread *starlark.Thread, timeout int) (context.Context, context.CancelFunc) {
+	ctx, ok := thread.Local(localContext).(context.Context)
+	if !ok {
+		ctx = context.Background()
+	}
+	if timeout > 0 {
+		return context.WithTimeout(ctx, time.Second*time.Duration(timeout))
+	}
+	return context.WithCancel(ctx)
+}
+
+func threadDialer(thread *starlark.Thread) *proxy.Dialer {
+	return thread.Local(localDialer).(*proxy.Dialer)
+}
+
+func errorValue(err error) starlark.Value {
+	if err == nil {
+		return starlark.String("")
+	}
+	return starlark.String(err.Error())
+}
+
+func latencyValue(d time.Duration) starlark.Value {
+	return starlark.Float(float64(d.Microseconds()) / 1000)
+}
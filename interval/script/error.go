@@ -0,0 +1,7 @@
+package script
+
+import "errors"
+
+var invalidConfigError error = errors.New("invalid check config")
+
+var invalidResultError error = errors.New("invalid script result")
@@ -0,0 +1,248 @@
+package script
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/exmonitor/exclient/database"
+	"github.com/exmonitor/exlogger"
+	"github.com/pkg/errors"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+
+	"github.com/exmonitor/watcher/interval/proxy"
+	"github.com/exmonitor/watcher/interval/spec"
+	"github.com/exmonitor/watcher/interval/status"
+	"github.com/exmonitor/watcher/key"
+)
+
+const (
+	msgSuccess      = "success"
+	msgFailedResult = "failed - check() returned false"
+	msgFailedScript = "failed - script error"
+	msgFailedBudget = "failed - script exceeded its budget"
+
+	// function called by the check, it must return bool or (bool, message) tuple
+	checkFunction  = "check"
+	scriptFilename = "check.star"
+
+	// default max number of starlark execution steps per check run
+	defaultMaxSteps = 1000000
+)
+
+// scripts can use while loops and sets, recursion stays disabled
+var fileOptions = &syntax.FileOptions{
+	Set:   true,
+	While: true,
+}
+
+type CheckConfig struct {
+	Id            int
+	FailThreshold int
+	Interval      int
+	Script        string        // starlark source, must define check() function
+	Timeout       time.Duration // time budget for the whole script run
+	MaxSteps      uint64        // cpu budget in starlark execution steps, zero for default
+	Proxy         string        // proxy url for http and tcp helpers, empty for global proxy
+
+	//db client
+	DBClient database.ClientInterface
+	Logger   *exlogger.Logger
+}
+
+type Check struct {
+	id            int
+	failThreshold int
+	interval      int
+	requestId     string
+	program       *starlark.Program
+	timeout       time.Duration
+	maxSteps      uint64
+	dialer        *proxy.Dialer
+
+	// db client
+	dbClient database.ClientInterface
+	// logger
+	log *exlogger.Logger
+
+	// internals
+	spec.CheckInterface
+}
+
+func NewCheck(conf CheckConfig) (*Check, error) {
+	if conf.Id == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.id must not be zero")
+	}
+	if conf.FailThreshold == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.FailThreshold must not be zero")
+	}
+	if conf.Interval == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.Interval must not be zero")
+	}
+	if conf.Script == "" {
+		return nil, errors.Wrap(invalidConfigError, "conf.Script must not be empty")
+	}
+	if conf.Timeout == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.Timeout must not be zero")
+	}
+	if conf.DBClient == nil {
+		return nil, errors.Wrap(invalidConfigError, "conf.DBClient must not be nil")
+	}
+	if conf.Logger == nil {
+		return nil, errors.Wrap(invalidConfigError, "conf.Logger must not be nil")
+	}
+	if conf.MaxSteps == 0 {
+		conf.MaxSteps = defaultMaxSteps
+	}
+	file, program, err := starlark.SourceProgramOptions(fileOptions, scriptFilename, conf.Script, predeclared.Has)
+	if err != nil {
+		return nil, errors.Wrap(invalidConfigError, "conf.Script is not valid: "+err.Error())
+	}
+	if !definesFunction(file, checkFunction) {
+		return nil, errors.Wrap(invalidConfigError, "conf.Script must define function "+checkFunction+"()")
+	}
+	dialer, err := proxy.New(conf.Proxy, conf.Timeout)
+	if err != nil {
+		return nil, errors.Wrap(err, "conf.Proxy is not valid")
+	}
+
+	newCheck := &Check{
+		id:            conf.Id,
+		failThreshold: conf.FailThreshold,
+		interval:      conf.Interval,
+		program:       program,
+		timeout:       conf.Timeout,
+		maxSteps:      conf.MaxSteps,
+		dialer:        dialer,
+
+		dbClient: conf.DBClient,
+		log:      conf.Logger,
+	}
+
+	return newCheck, nil
+}
+
+// returns true if the function is defined at the top level of the script
+func definesFunction(file *syntax.File, name string) bool {
+	for _, stmt := range file.Stmts {
+		if def, ok := stmt.(*syntax.DefStmt); ok && def.Name.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// wrapper function used to run in separate thread (goroutine)
+func (c *Check) RunCheck() {
+	// generate unique request ID
+	c.requestId = key.GenerateReqId(c.id)
+	// run monitoring check
+	s := c.doCheck()
+	c.LogResult(s)
+
+	// save result to database
+	s.SaveToDB()
+}
+
+// run monitoring check
+func (c *Check) doCheck() *status.Status {
+	statusConfig := status.Config{
+		Id:            c.id,
+		ReqId:         c.requestId,
+		Interval:      c.interval,
+		FailThreshold: c.failThreshold,
+		DBClient:      c.dbClient,
+	}
+	s, err := status.New(statusConfig)
+	if err != nil {
+		c.LogRunError(err, fmt.Sprintf("failed to init new status for SCRIPT service ID %d", c.id))
+	}
+	tStart := time.Now()
+	defer func() { s.Duration = time.Since(tStart) }()
+
+	// the time budget cancels both the interpreter and the pending network calls
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	thread := &starlark.Thread{
+		Name: c.requestId,
+		Print: func(_ *starlark.Thread, msg string) {
+			c.log.LogDebug("check-SCRIPT|id %d|reqID %s|print: %s", c.id, c.requestId, msg)
+		},
+	}
+	thread.SetLocal(localContext, ctx)
+	thread.SetLocal(localDialer, c.dialer)
+	thread.SetMaxExecutionSteps(c.maxSteps)
+	stop := context.AfterFunc(ctx, func() {
+		thread.Cancel(fmt.Sprintf("timeout %s exceeded", c.timeout))
+	})
+	defer stop()
+
+	result, msg, err := c.run(thread)
+	if err != nil {
+		if evalErr, ok := err.(*starlark.EvalError); ok {
+			c.log.LogDebug("check-SCRIPT|id %d|reqID %s|backtrace: %s", c.id, c.requestId, evalErr.Backtrace())
+		}
+		if ctx.Err() != nil || thread.ExecutionSteps() >= c.maxSteps {
+			s.Set(false, err, msgFailedBudget)
+		} else {
+			s.Set(false, err, msgFailedScript)
+		}
+		return s
+	}
+	if msg == "" {
+		msg = msgSuccess
+		if !result {
+			msg = msgFailedResult
+		}
+	}
+	s.Set(result, nil, msg)
+	return s
+}
+
+// execute the script and call its check function
+func (c *Check) run(thread *starlark.Thread) (bool, string, error) {
+	globals, err := c.program.Init(thread, predeclared)
+	if err != nil {
+		return false, "", err
+	}
+	fn, ok := globals[checkFunction].(starlark.Callable)
+	if !ok {
+		return false, "", errors.Wrapf(invalidResultError, "%s is not a function", checkFunction)
+	}
+	value, err := starlark.Call(thread, fn, nil, nil)
+	if err != nil {
+		return false, "", err
+	}
+	return parseResult(value)
+}
+
+// script result is either bool or (bool, message) tuple
+func parseResult(value starlark.Value) (bool, string, error) {
+	switch v := value.(type) {
+	case starlark.Bool:
+		return bool(v), "", nil
+	case starlark.Tuple:
+		if len(v) == 2 {
+			result, ok1 := v[0].(starlark.Bool)
+			msg, ok2 := starlark.AsString(v[1])
+			if ok1 && ok2 {
+				return bool(result), msg, nil
+			}
+		}
+	}
+	return false, "", errors.Wrapf(invalidResultError, "%s() returned %s, expected bool or (bool, string) tuple", checkFunction, value.Type())
+}
+
+func (c *Check) GetStringPort() string {
+	// script has no port
+	return ""
+}
+
+func (c *Check) LogResult(s *status.Status) {
+	c.log.Log("check-SCRIPT|id %d|reqID %s|proxy %s|latency %sms|result '%t'|msg: %s", c.id, c.requestId, c.dialer, key.MsFromDuration(s.Duration), s.Result, s.Message)
+}
+
+func (c *Check) LogRunError(err error, message string) {
+	c.log.LogError(err, "running check id:%d reqID:%s type:script failed, reason: %s", c.id, c.requestId, message)
+}
@@ -0,0 +1,5 @@
+package secret
+
+import "errors"
+
+var invalidReferenceError error = errors.New("invalid secret reference")
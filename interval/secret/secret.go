@@ -0,0 +1,86 @@
+package secret
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+/*
+Secret references used in check metadata:
+	"file:/etc/watcher/client.pem" - content of the file
+	"env:DB_PASSWORD"              - value of the environment variable
+	"secret:db-password"           - file with the same name inside the secrets directory (--secrets-dir)
+	"/etc/watcher/client.pem"      - reference without prefix is treated as file path
+*/
+
+const (
+	prefixFile   = "file:"
+	prefixEnv    = "env:"
+	prefixSecret = "secret:"
+)
+
+// directory used for resolving "secret:" references, set on startup
+var secretsDir string
+
+// set directory used as secret store
+func SetDir(dir string) {
+	secretsDir = dir
+}
+
+// returns true if the value is a reference to secret and not the secret itself
+func IsReference(value string) bool {
+	return strings.HasPrefix(value, prefixFile) || strings.HasPrefix(value, prefixEnv) || strings.HasPrefix(value, prefixSecret)
+}
+
+// load content of the referenced secret
+func Resolve(ref string) ([]byte, error) {
+	switch {
+	case ref == "":
+		return nil, errors.Wrap(invalidReferenceError, "reference must not be empty")
+	case strings.HasPrefix(ref, prefixEnv):
+		name := strings.TrimPrefix(ref, prefixEnv)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return nil, errors.Wrapf(invalidReferenceError, "environment variable %s is not set", name)
+		}
+		return []byte(value), nil
+	case strings.HasPrefix(ref, prefixSecret):
+		if secretsDir == "" {
+			return nil, errors.Wrapf(invalidReferenceError, "secrets directory is not configured, cannot resolve %s", ref)
+		}
+		name := strings.TrimPrefix(ref, prefixSecret)
+		// dont allow escaping from the secrets directory
+		if name == "" || strings.Contains(name, "..") || strings.ContainsRune(name, filepath.Separator) {
+			return nil, errors.Wrapf(invalidReferenceError, "invalid secret name %s", name)
+		}
+		return readFile(filepath.Join(secretsDir, name))
+	default:
+		return readFile(strings.TrimPrefix(ref, prefixFile))
+	}
+}
+
+// resolve value if its a reference, otherwise return the value itself
+// useful for options like passwords that can be set directly in metadata
+func Value(value string) (string, error) {
+	if !IsReference(value) {
+		return value, nil
+	}
+	data, err := Resolve(value)
+	if err != nil {
+		return "", err
+	}
+	// secrets stored in files usually ends with new line
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+func readFile(path string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read secret file %s", path)
+	}
+	return data, nil
+}
@@ -0,0 +1,25 @@
+package smtp
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+)
+
+// header used for identification of test messages
+const HeaderRequestId = "X-Watcher-Request-Id"
+
+// build simple test message with CRLF line endings, reqId is used as unique tag of the message
+func NewMessage(from string, to string, subject string, reqId string) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: <%s>\r\n", from)
+	fmt.Fprintf(&b, "To: <%s>\r\n", to)
+	fmt.Fprintf(&b, "Subject: %s %s\r\n", subject, reqId)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: <%s@watcher>\r\n", reqId)
+	fmt.Fprintf(&b, "%s: %s\r\n", HeaderRequestId, reqId)
+	fmt.Fprintf(&b, "Content-Type: text/plain; charset=utf-8\r\n")
+	fmt.Fprintf(&b, "\r\n")
+	fmt.Fprintf(&b, "This is automatic test message sent by exmonitor watcher, request id %s.\r\n", reqId)
+	return b.Bytes()
+}
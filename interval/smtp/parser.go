@@ -0,0 +1,91 @@
+package smtp
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/exmonitor/exclient/database"
+	"github.com/exmonitor/exclient/database/spec/service"
+	"github.com/exmonitor/exlogger"
+	"github.com/pkg/errors"
+)
+
+/*
+Example metadata:
+{
+	"id": 5,
+	"target": "mail.domain.cz",
+	"port": 587,
+	"timeout": 10,
+	"heloName": "watcher.domain.cz",
+	"bannerCheckString": "ESMTP Postfix",
+	"requiredExtensions": ["PIPELINING", "8BITMIME"],
+	"implicitTls": false,
+	"startTls": true,
+	"tlsSkipVerify": false,
+	"authEnabled": true,
+	"authUsername": "monitoring@domain.cz",
+	"authPassword": "secret:smtp-password",
+	"transactionEnabled": true,
+	"mailFrom": "monitoring@domain.cz",
+	"rcptTo": "blackhole@domain.cz",
+	"sendData": true
+}
+*/
+
+type RawCheck struct {
+	Id                 int      `json:"id"`
+	Target             string   `json:"target"`
+	Port               int      `json:"port"`
+	Timeout            int      `json:"timeout"`
+	HeloName           string   `json:"heloName"`
+	BannerCheckString  string   `json:"bannerCheckString"`
+	RequiredExtensions []string `json:"requiredExtensions"`
+	ImplicitTls        bool     `json:"implicitTls"`
+	StartTls           bool     `json:"startTls"`
+	TlsSkipVerify      bool     `json:"tlsSkipVerify"`
+	AuthEnabled        bool     `json:"authEnabled"`
+	AuthUsername       string   `json:"authUsername"`
+	AuthPassword       string   `json:"authPassword"`
+	TransactionEnabled bool     `json:"transactionEnabled"`
+	MailFrom           string   `json:"mailFrom"`
+	RcptTo             string   `json:"rcptTo"`
+	SendData           bool     `json:"sendData"`
+}
+
+func ParseCheck(service *service.Service, dbClient database.ClientInterface, logger *exlogger.Logger) (*Check, error) {
+	var rawCheck RawCheck
+	err := json.Unmarshal([]byte(service.Metadata), &rawCheck)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to parse SMTP json metadata for check id %d", service.ID))
+	} else {
+		logger.LogDebug("Successfully parsed SMTP json metadata for check id %d", service.ID)
+	}
+
+	checkConfig := CheckConfig{
+		Id:                 service.ID,
+		FailThreshold:      service.FailThreshold,
+		Interval:           service.Interval,
+		Target:             rawCheck.Target,
+		Port:               rawCheck.Port,
+		Timeout:            time.Second * time.Duration(rawCheck.Timeout),
+		HeloName:           rawCheck.HeloName,
+		BannerCheckString:  rawCheck.BannerCheckString,
+		RequiredExtensions: rawCheck.RequiredExtensions,
+		ImplicitTls:        rawCheck.ImplicitTls,
+		StartTls:           rawCheck.StartTls,
+		TlsSkipVerify:      rawCheck.TlsSkipVerify,
+		AuthEnabled:        rawCheck.AuthEnabled,
+		AuthUsername:       rawCheck.AuthUsername,
+		AuthPassword:       rawCheck.AuthPassword,
+		TransactionEnabled: rawCheck.TransactionEnabled,
+		MailFrom:           rawCheck.MailFrom,
+		RcptTo:             rawCheck.RcptTo,
+		SendData:           rawCheck.SendData,
+		Logger:             logger,
+		DBClient:           dbClient,
+	}
+
+	return NewCheck(checkConfig)
+}
@@ -0,0 +1,213 @@
+package smtp
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// minimal SMTP client (RFC 5321), unlike net/smtp it exposes the banner
+type Session struct {
+	conn net.Conn
+	text *textproto.Conn
+
+	Banner     string
+	Extensions map[string]string // EHLO keywords in upper case with their parameters
+}
+
+// open session and read the banner, tlsConfig enables implicit tls (ie: port 465)
+// whole session must finish within timeout
+func Dial(addr string, timeout time.Duration, tlsConfig *tls.Config) (*Session, error) {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+	if tlsConfig != nil {
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, errors.Wrap(err, "tls handshake")
+		}
+		conn = tlsConn
+	}
+
+	s := &Session{conn: conn, text: textproto.NewConn(conn)}
+	_, banner, err := s.text.ReadResponse(220)
+	if err != nil {
+		s.Close()
+		return nil, errors.Wrap(err, "banner")
+	}
+	s.Banner = banner
+
+	return s, nil
+}
+
+// send EHLO and parse announced extensions
+func (s *Session) Hello(name string) error {
+	_, msg, err := s.cmd(250, "EHLO %s", name)
+	if err != nil {
+		return errors.Wrap(err, "EHLO")
+	}
+	s.Extensions = map[string]string{}
+	// first line is greeting, rest are extensions
+	lines := strings.Split(msg, "\n")
+	for _, line := range lines[1:] {
+		keyword, params := line, ""
+		if i := strings.IndexByte(line, ' '); i > 0 {
+			keyword, params = line[:i], line[i+1:]
+		}
+		s.Extensions[strings.ToUpper(keyword)] = params
+	}
+	return nil
+}
+
+// returns true if the server announced the extension in EHLO response
+func (s *Session) Extension(name string) bool {
+	_, ok := s.Extensions[strings.ToUpper(name)]
+	return ok
+}
+
+// upgrade connection to tls, EHLO must be sent again after that
+func (s *Session) StartTLS(tlsConfig *tls.Config) error {
+	if _, _, err := s.cmd(220, "STARTTLS"); err != nil {
+		return errors.Wrap(err, "STARTTLS")
+	}
+	tlsConn := tls.Client(s.conn, tlsConfig)
+	if err := tlsConn.Handshake(); err != nil {
+		return errors.Wrap(err, "tls handshake")
+	}
+	s.conn = tlsConn
+	s.text = textproto.NewConn(tlsConn)
+	s.Extensions = nil
+	return nil
+}
+
+// returns tls connection state if the session is encrypted
+func (s *Session) TLSConnectionState() (*tls.ConnectionState, bool) {
+	tlsConn, ok := s.conn.(*tls.Conn)
+	if !ok {
+		return nil, false
+	}
+	state := tlsConn.ConnectionState()
+	return &state, true
+}
+
+// authenticate with PLAIN or LOGIN mechanism, whichever is announced by the server,
+// credentials are sent only over tls (implicit or after STARTTLS)
+func (s *Session) Auth(username string, password string) error {
+	if _, ok := s.TLSConnectionState(); !ok {
+		return errors.New("AUTH: refusing to send credentials over unencrypted connection")
+	}
+	mechanisms := strings.Fields(strings.ToUpper(s.Extensions["AUTH"]))
+	switch {
+	case contains(mechanisms, "PLAIN"):
+		credentials := base64.StdEncoding.EncodeToString([]byte("\x00" + username + "\x00" + password))
+		if _, _, err := s.cmd(235, "AUTH PLAIN %s", credentials); err != nil {
+			return errors.Wrap(err, "AUTH PLAIN")
+		}
+	case contains(mechanisms, "LOGIN"):
+		if _, _, err := s.cmd(334, "AUTH LOGIN"); err != nil {
+			return errors.Wrap(err, "AUTH LOGIN")
+		}
+		if _, _, err := s.cmd(334, "%s", base64.StdEncoding.EncodeToString([]byte(username))); err != nil {
+			return errors.Wrap(err, "AUTH LOGIN")
+		}
+		if _, _, err := s.cmd(235, "%s", base64.StdEncoding.EncodeToString([]byte(password))); err != nil {
+			return errors.Wrap(err, "AUTH LOGIN")
+		}
+	default:
+		return fmt.Errorf("AUTH: server does not offer PLAIN or LOGIN mechanism, offered: %q", s.Extensions["AUTH"])
+	}
+	return nil
+}
+
+func (s *Session) Mail(from string) error {
+	if _, _, err := s.cmd(250, "MAIL FROM:<%s>", from); err != nil {
+		return errors.Wrap(err, "MAIL FROM")
+	}
+	return nil
+}
+
+func (s *Session) Rcpt(to string) error {
+	// 251 - user not local, will forward
+	code, msg, err := s.cmd(25, "RCPT TO:<%s>", to)
+	if err != nil {
+		return errors.Wrap(err, "RCPT TO")
+	}
+	if code != 250 && code != 251 {
+		return fmt.Errorf("RCPT TO: %d %s", code, msg)
+	}
+	return nil
+}
+
+// send message body, message must contain headers and use CRLF line endings
+func (s *Session) Data(message []byte) error {
+	if _, _, err := s.cmd(354, "DATA"); err != nil {
+		return errors.Wrap(err, "DATA")
+	}
+	w := s.text.DotWriter()
+	if _, err := w.Write(message); err != nil {
+		return errors.Wrap(err, "DATA")
+	}
+	if err := w.Close(); err != nil {
+		return errors.Wrap(err, "DATA")
+	}
+	if _, _, err := s.text.ReadResponse(250); err != nil {
+		return errors.Wrap(err, "DATA")
+	}
+	return nil
+}
+
+// abort current mail transaction
+func (s *Session) Reset() error {
+	if _, _, err := s.cmd(250, "RSET"); err != nil {
+		return errors.Wrap(err, "RSET")
+	}
+	return nil
+}
+
+// politely end the session and close connection
+func (s *Session) Quit() error {
+	_, _, err := s.cmd(221, "QUIT")
+	s.Close()
+	if err != nil {
+		return errors.Wrap(err, "QUIT")
+	}
+	return nil
+}
+
+func (s *Session) Close() error {
+	return s.text.Close()
+}
+
+// send command and read response
+func (s *Session) cmd(expectCode int, format string, args ...interface{}) (int, string, error) {
+	return Cmd(s.text, expectCode, format, args...)
+}
+
+// send command over text protocol connection and read response, shared with starttls negotiation of other checks
+func Cmd(text *textproto.Conn, expectCode int, format string, args ...interface{}) (int, string, error) {
+	id, err := text.Cmd(format, args...)
+	if err != nil {
+		return 0, "", err
+	}
+	text.StartResponse(id)
+	defer text.EndResponse(id)
+	return text.ReadResponse(expectCode)
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
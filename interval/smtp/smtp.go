@@ -0,0 +1,315 @@
+package smtp
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/exmonitor/exclient/database"
+	"github.com/exmonitor/exlogger"
+	"github.com/pkg/errors"
+
+	"github.com/exmonitor/watcher/interval/secret"
+	"github.com/exmonitor/watcher/interval/spec"
+	"github.com/exmonitor/watcher/interval/status"
+	"github.com/exmonitor/watcher/key"
+)
+
+const (
+	msgSuccess               = "success"
+	msgFailedToConnect       = "failed to open smtp session"
+	msgFailedBanner          = "failed - unexpected banner"
+	msgFailedHello           = "failed - EHLO rejected"
+	msgFailedMissingStartTLS = "failed - STARTTLS is not offered"
+	msgFailedStartTLS        = "failed - STARTTLS"
+	msgFailedMissingExt      = "failed - missing extension"
+	msgFailedAuth            = "failed - authentication"
+	msgFailedTransaction     = "failed - mail transaction rejected"
+
+	msgInternalFailedSecret = "INTERNAL: failed to load smtp password"
+
+	testMessageSubject = "watcher smtp test"
+)
+
+type CheckConfig struct {
+	Id            int
+	FailThreshold int
+	Interval      int
+	Target        string
+	Port          int
+	Timeout       time.Duration
+
+	// protocol options
+	HeloName           string // default is hostname of the watcher
+	BannerCheckString  string // string which must be in the banner
+	RequiredExtensions []string
+	ImplicitTls        bool // tls from the start of connection (port 465)
+	StartTls           bool
+	TlsSkipVerify      bool
+	AuthEnabled        bool
+	AuthUsername       string
+	AuthPassword       string // password or secret reference
+
+	// mail transaction options
+	TransactionEnabled bool
+	MailFrom           string
+	RcptTo             string
+	SendData           bool // send the message, otherwise transaction is reset after RCPT TO
+
+	//db client
+	DBClient database.ClientInterface
+	Logger   *exlogger.Logger
+}
+
+type Check struct {
+	id            int
+	failThreshold int
+	interval      int
+	requestId     string
+	target        string
+	port          int
+	timeout       time.Duration
+
+	// protocol options
+	heloName           string
+	bannerCheckString  string
+	requiredExtensions []string
+	implicitTls        bool
+	startTls           bool
+	tlsSkipVerify      bool
+	authEnabled        bool
+	authUsername       string
+	authPassword       string
+
+	// mail transaction options
+	transactionEnabled bool
+	mailFrom           string
+	rcptTo             string
+	sendData           bool
+
+	// db client
+	dbClient database.ClientInterface
+	// logger
+	log *exlogger.Logger
+
+	// internals
+	spec.CheckInterface
+}
+
+func NewCheck(conf CheckConfig) (*Check, error) {
+	if conf.Id == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.id must not be zero")
+	}
+	if conf.FailThreshold == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.FailThreshold must not be zero")
+	}
+	if conf.Interval == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.Interval must not be zero")
+	}
+	if conf.Target == "" {
+		return nil, errors.Wrap(invalidConfigError, "conf.Target must not be empty")
+	}
+	if conf.Port == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.Port must not be zero")
+	}
+	if conf.Timeout == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.Timeout must not be zero")
+	}
+	if conf.ImplicitTls && conf.StartTls {
+		return nil, errors.Wrap(invalidConfigError, "conf.ImplicitTls and conf.StartTls cannot be enabled together")
+	}
+	if conf.AuthEnabled && conf.AuthUsername == "" {
+		return nil, errors.Wrap(invalidConfigError, "conf.AuthUsername must not be empty, when auth is enabled")
+	}
+	if conf.AuthEnabled && !conf.ImplicitTls && !conf.StartTls {
+		return nil, errors.Wrap(invalidConfigError, "conf.ImplicitTls or conf.StartTls must be enabled, when auth is enabled")
+	}
+	if conf.TransactionEnabled && (conf.MailFrom == "" || conf.RcptTo == "") {
+		return nil, errors.Wrap(invalidConfigError, "conf.MailFrom and conf.RcptTo must not be empty, when transaction is enabled")
+	}
+	if conf.SendData && !conf.TransactionEnabled {
+		return nil, errors.Wrap(invalidConfigError, "conf.SendData can be used only when transaction is enabled")
+	}
+	if conf.DBClient == nil {
+		return nil, errors.Wrap(invalidConfigError, "conf.DBClient must not be nil")
+	}
+	if conf.Logger == nil {
+		return nil, errors.Wrap(invalidConfigError, "conf.Logger must not be nil")
+	}
+	if conf.HeloName == "" {
+		conf.HeloName = HelloName()
+	}
+
+	newCheck := &Check{
+		id:            conf.Id,
+		failThreshold: conf.FailThreshold,
+		interval:      conf.Interval,
+		timeout:       conf.Timeout,
+		port:          conf.Port,
+		target:        conf.Target,
+
+		heloName:           conf.HeloName,
+		bannerCheckString:  conf.BannerCheckString,
+		requiredExtensions: conf.RequiredExtensions,
+		implicitTls:        conf.ImplicitTls,
+		startTls:           conf.StartTls,
+		tlsSkipVerify:      conf.TlsSkipVerify,
+		authEnabled:        conf.AuthEnabled,
+		authUsername:       conf.AuthUsername,
+		authPassword:       conf.AuthPassword,
+
+		transactionEnabled: conf.TransactionEnabled,
+		mailFrom:           conf.MailFrom,
+		rcptTo:             conf.RcptTo,
+		sendData:           conf.SendData,
+
+		dbClient: conf.DBClient,
+		log:      conf.Logger,
+	}
+
+	return newCheck, nil
+}
+
+// wrapper function used to run in separate thread (goroutine)
+func (c *Check) RunCheck() {
+	// generate unique request ID
+	c.requestId = key.GenerateReqId(c.id)
+	// run monitoring check
+	s := c.doCheck()
+	c.LogResult(s)
+
+	// save result to database
+	s.SaveToDB()
+}
+
+func (c *Check) doCheck() *status.Status {
+	statusConfig := status.Config{
+		Id:            c.id,
+		ReqId:         c.requestId,
+		Interval:      c.interval,
+		FailThreshold: c.failThreshold,
+		DBClient:      c.dbClient,
+	}
+	s, err := status.New(statusConfig)
+	if err != nil {
+		c.LogRunError(err, fmt.Sprintf("failed to init new status for SMTP service ID %d", c.id))
+	}
+	tStart := time.Now()
+	defer func() {
+		s.Duration = time.Since(tStart)
+	}()
+
+	tlsConfig := &tls.Config{
+		ServerName:         c.target,
+		InsecureSkipVerify: c.tlsSkipVerify,
+	}
+	var implicitTlsConfig *tls.Config
+	if c.implicitTls {
+		implicitTlsConfig = tlsConfig
+	}
+
+	session, err := Dial(net.JoinHostPort(c.target, strconv.Itoa(c.port)), c.timeout, implicitTlsConfig)
+	if err != nil {
+		s.Set(false, err, msgFailedToConnect)
+		return s
+	}
+	defer session.Close()
+
+	// banner
+	if c.bannerCheckString != "" && !strings.Contains(session.Banner, c.bannerCheckString) {
+		s.Set(false, nil, fmt.Sprintf("%s, %q does not contain %q", msgFailedBanner, session.Banner, c.bannerCheckString))
+		return s
+	}
+	if err := session.Hello(c.heloName); err != nil {
+		s.Set(false, err, msgFailedHello)
+		return s
+	}
+
+	// upgrade to tls
+	if c.startTls {
+		if !session.Extension("STARTTLS") {
+			s.Set(false, nil, msgFailedMissingStartTLS)
+			return s
+		}
+		if err := session.StartTLS(tlsConfig); err != nil {
+			s.Set(false, err, msgFailedStartTLS)
+			return s
+		}
+		if err := session.Hello(c.heloName); err != nil {
+			s.Set(false, err, msgFailedHello)
+			return s
+		}
+	}
+
+	// capabilities
+	for _, ext := range c.requiredExtensions {
+		if !session.Extension(ext) {
+			s.Set(false, nil, fmt.Sprintf("%s %s", msgFailedMissingExt, ext))
+			return s
+		}
+	}
+
+	// authentication
+	if c.authEnabled {
+		password, err := secret.Value(c.authPassword)
+		if err != nil {
+			c.LogRunError(err, msgInternalFailedSecret)
+			s.Set(false, err, msgInternalFailedSecret)
+			return s
+		}
+		if err := session.Auth(c.authUsername, password); err != nil {
+			s.Set(false, err, msgFailedAuth)
+			return s
+		}
+	}
+
+	// mail transaction
+	if c.transactionEnabled {
+		if err := c.transaction(session); err != nil {
+			s.Set(false, err, msgFailedTransaction)
+			return s
+		}
+	}
+
+	session.Quit()
+	s.Set(true, nil, msgSuccess)
+	return s
+}
+
+func (c *Check) transaction(session *Session) error {
+	if err := session.Mail(c.mailFrom); err != nil {
+		return err
+	}
+	if err := session.Rcpt(c.rcptTo); err != nil {
+		return err
+	}
+	if !c.sendData {
+		return session.Reset()
+	}
+	return session.Data(NewMessage(c.mailFrom, c.rcptTo, testMessageSubject, c.requestId))
+}
+
+// name used in EHLO when its not configured, hostname of the watcher
+func HelloName() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		return "localhost"
+	}
+	return hostname
+}
+
+func (c *Check) GetStringPort() string {
+	return fmt.Sprintf(":%d", c.port)
+}
+
+func (c *Check) LogResult(s *status.Status) {
+	c.log.Log("check-SMTP|id %d|reqID %s|target %s|port %d|latency %sms|result '%t'|msg: %s", c.id, c.requestId, c.target, c.port, key.MsFromDuration(s.Duration), s.Result, s.Message)
+}
+
+func (c *Check) LogRunError(err error, message string) {
+	c.log.LogError(err, "running check id:%d reqID:%s type:smtp target:%s:%d failed, reason: %s", c.id, c.requestId, c.target, c.port, message)
+}
@@ -0,0 +1,7 @@
+package ssh
+
+import "errors"
+
+var invalidConfigError error = errors.New("invalid check config")
+
+var hostKeyMismatchError error = errors.New("host key does not match pinned fingerprint")
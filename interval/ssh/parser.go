@@ -0,0 +1,85 @@
+package ssh
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/exmonitor/exclient/database"
+	"github.com/exmonitor/exclient/database/spec/service"
+	"github.com/exmonitor/exlogger"
+	"github.com/pkg/errors"
+)
+
+/*
+Example metadata:
+{
+	"id": 12,
+	"target": "server.domain.cz",
+	"port": 22,
+	"timeout": 10,
+	"bannerCheckString": "OpenSSH_9",
+	"hostKeyFingerprints": ["SHA256:Z3ZU7BNNKzPjJ6wBRsi2V2XD2UvHIVdE/3HHw8o7xUY"],
+	"hostKeyAlgorithms": ["ssh-ed25519"],
+	"authEnabled": true,
+	"username": "monitoring",
+	"privateKey": "secret:ssh-monitoring-key",
+	"privateKeyPassphrase": "",
+	"password": "",
+	"command": "systemctl is-active nginx",
+	"expectedExitStatus": 0,
+	"outputCheckString": "active"
+}
+*/
+
+type RawCheck struct {
+	Id                   int      `json:"id"`
+	Target               string   `json:"target"`
+	Port                 int      `json:"port"`
+	Timeout              int      `json:"timeout"`
+	BannerCheckString    string   `json:"bannerCheckString"`
+	HostKeyFingerprints  []string `json:"hostKeyFingerprints"`
+	HostKeyAlgorithms    []string `json:"hostKeyAlgorithms"`
+	AuthEnabled          bool     `json:"authEnabled"`
+	Username             string   `json:"username"`
+	PrivateKey           string   `json:"privateKey"`
+	PrivateKeyPassphrase string   `json:"privateKeyPassphrase"`
+	Password             string   `json:"password"`
+	Command              string   `json:"command"`
+	ExpectedExitStatus   int      `json:"expectedExitStatus"`
+	OutputCheckString    string   `json:"outputCheckString"`
+}
+
+func ParseCheck(service *service.Service, dbClient database.ClientInterface, logger *exlogger.Logger) (*Check, error) {
+	var rawCheck RawCheck
+	err := json.Unmarshal([]byte(service.Metadata), &rawCheck)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to parse SSH json metadata for check id %d", service.ID))
+	} else {
+		logger.LogDebug("Successfully parsed SSH json metadata for check id %d", service.ID)
+	}
+
+	checkConfig := CheckConfig{
+		Id:                   service.ID,
+		FailThreshold:        service.FailThreshold,
+		Interval:             service.Interval,
+		Target:               rawCheck.Target,
+		Port:                 rawCheck.Port,
+		Timeout:              time.Second * time.Duration(rawCheck.Timeout),
+		BannerCheckString:    rawCheck.BannerCheckString,
+		HostKeyFingerprints:  rawCheck.HostKeyFingerprints,
+		HostKeyAlgorithms:    rawCheck.HostKeyAlgorithms,
+		AuthEnabled:          rawCheck.AuthEnabled,
+		Username:             rawCheck.Username,
+		PrivateKey:           rawCheck.PrivateKey,
+		PrivateKeyPassphrase: rawCheck.PrivateKeyPassphrase,
+		Password:             rawCheck.Password,
+		Command:              rawCheck.Command,
+		ExpectedExitStatus:   rawCheck.ExpectedExitStatus,
+		OutputCheckString:    rawCheck.OutputCheckString,
+		Logger:               logger,
+		DBClient:             dbClient,
+	}
+
+	return NewCheck(checkConfig)
+}
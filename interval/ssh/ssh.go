@@ -0,0 +1,418 @@
+package ssh
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/exmonitor/exclient/database"
+	"github.com/exmonitor/exlogger"
+	"github.com/pkg/errors"
+	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/exmonitor/watcher/interval/secret"
+	"github.com/exmonitor/watcher/interval/spec"
+	"github.com/exmonitor/watcher/interval/status"
+	"github.com/exmonitor/watcher/key"
+)
+
+const (
+	msgSuccess           = "success"
+	msgFailedToConnect   = "failed to open tcp connection"
+	msgFailedHandshake   = "failed - ssh handshake"
+	msgFailedHostKey     = "failed - host key mismatch"
+	msgFailedBanner      = "failed - unexpected server version"
+	msgFailedAuth        = "failed - authentication"
+	msgFailedCommand     = "failed - command"
+	msgFailedExitStatus  = "failed - unexpected exit status"
+	msgFailedOutput      = "failed - command output does not contain"
+	msgInternalFailedKey = "INTERNAL: failed to load ssh credentials"
+
+	DefaultPort = 22
+	// username used only for reaching the authentication phase when auth is disabled
+	probeUsername = "watcher"
+	clientVersion = "SSH-2.0-watcher"
+	// server can send other lines before the version line (RFC 4253 4.2)
+	maxVersionCapture = 8192
+	// max length of command output in the message
+	maxOutputLog = 128
+)
+
+type CheckConfig struct {
+	Id            int
+	FailThreshold int
+	Interval      int
+	Target        string
+	Port          int // default 22
+	Timeout       time.Duration
+
+	// server identity
+	BannerCheckString   string   // string which must be in server version, ie: OpenSSH_9
+	HostKeyFingerprints []string // pinned SHA256 fingerprints, any of them must match
+	HostKeyAlgorithms   []string // restrict host key algorithms so pinned key type is negotiated
+
+	// authentication and command
+	AuthEnabled          bool
+	Username             string
+	PrivateKey           string // secret reference or path to private key
+	PrivateKeyPassphrase string // passphrase or secret reference
+	Password             string // password or secret reference
+	Command              string // command is run only if set
+	ExpectedExitStatus   int
+	OutputCheckString    string // string which must be in command stdout
+
+	//db client
+	DBClient database.ClientInterface
+	Logger   *exlogger.Logger
+}
+
+type Check struct {
+	id            int
+	failThreshold int
+	interval      int
+	requestId     string
+	target        string
+	port          int
+	timeout       time.Duration
+
+	bannerCheckString   string
+	hostKeyFingerprints []string
+	hostKeyAlgorithms   []string
+
+	authEnabled          bool
+	username             string
+	privateKey           string
+	privateKeyPassphrase string
+	password             string
+	command              string
+	expectedExitStatus   int
+	outputCheckString    string
+
+	// db client
+	dbClient database.ClientInterface
+	// logger
+	log *exlogger.Logger
+
+	// internals
+	spec.CheckInterface
+}
+
+func NewCheck(conf CheckConfig) (*Check, error) {
+	if conf.Id == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.id must not be zero")
+	}
+	if conf.FailThreshold == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.FailThreshold must not be zero")
+	}
+	if conf.Interval == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.Interval must not be zero")
+	}
+	if conf.Target == "" {
+		return nil, errors.Wrap(invalidConfigError, "conf.Target must not be empty")
+	}
+	if conf.Timeout == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.Timeout must not be zero")
+	}
+	for _, fingerprint := range conf.HostKeyFingerprints {
+		if !strings.HasPrefix(fingerprint, "SHA256:") {
+			return nil, errors.Wrapf(invalidConfigError, "conf.HostKeyFingerprints %q must be SHA256 fingerprint, ie: output of ssh-keygen -lf", fingerprint)
+		}
+	}
+	if conf.AuthEnabled {
+		if conf.Username == "" {
+			return nil, errors.Wrap(invalidConfigError, "conf.Username must not be empty when auth is enabled")
+		}
+		if conf.PrivateKey == "" && conf.Password == "" {
+			return nil, errors.Wrap(invalidConfigError, "conf.PrivateKey or conf.Password must be set when auth is enabled")
+		}
+		// credentials must not be offered to an unknown server
+		if len(conf.HostKeyFingerprints) == 0 {
+			return nil, errors.Wrap(invalidConfigError, "conf.HostKeyFingerprints must be set when auth is enabled")
+		}
+	} else if conf.Command != "" {
+		return nil, errors.Wrap(invalidConfigError, "conf.Command requires auth to be enabled")
+	}
+	if conf.DBClient == nil {
+		return nil, errors.Wrap(invalidConfigError, "conf.DBClient must not be nil")
+	}
+	if conf.Logger == nil {
+		return nil, errors.Wrap(invalidConfigError, "conf.Logger must not be nil")
+	}
+	if conf.Port == 0 {
+		conf.Port = DefaultPort
+	}
+
+	newCheck := &Check{
+		id:            conf.Id,
+		failThreshold: conf.FailThreshold,
+		interval:      conf.Interval,
+		timeout:       conf.Timeout,
+		port:          conf.Port,
+		target:        conf.Target,
+
+		bannerCheckString:   conf.BannerCheckString,
+		hostKeyFingerprints: conf.HostKeyFingerprints,
+		hostKeyAlgorithms:   conf.HostKeyAlgorithms,
+
+		authEnabled:          conf.AuthEnabled,
+		username:             conf.Username,
+		privateKey:           conf.PrivateKey,
+		privateKeyPassphrase: conf.PrivateKeyPassphrase,
+		password:             conf.Password,
+		command:              conf.Command,
+		expectedExitStatus:   conf.ExpectedExitStatus,
+		outputCheckString:    conf.OutputCheckString,
+
+		dbClient: conf.DBClient,
+		log:      conf.Logger,
+	}
+
+	return newCheck, nil
+}
+
+// wrapper function used to run in separate thread (goroutine)
+func (c *Check) RunCheck() {
+	// generate unique request ID
+	c.requestId = key.GenerateReqId(c.id)
+	// run monitoring check
+	s := c.doCheck()
+	c.LogResult(s)
+
+	// save result to database
+	s.SaveToDB()
+}
+
+func (c *Check) doCheck() *status.Status {
+	statusConfig := status.Config{
+		Id:            c.id,
+		ReqId:         c.requestId,
+		Interval:      c.interval,
+		FailThreshold: c.failThreshold,
+		DBClient:      c.dbClient,
+	}
+	s, err := status.New(statusConfig)
+	if err != nil {
+		c.LogRunError(err, fmt.Sprintf("failed to init new status for SSH service ID %d", c.id))
+	}
+	tStart := time.Now()
+	defer func() {
+		s.Duration = time.Since(tStart)
+	}()
+
+	auth, err := c.authMethods()
+	if err != nil {
+		c.LogRunError(err, msgInternalFailedKey)
+		s.Set(false, err, msgInternalFailedKey)
+		return s
+	}
+
+	addr := net.JoinHostPort(c.target, strconv.Itoa(c.port))
+	tcpConn, err := net.DialTimeout("tcp", addr, c.timeout)
+	if err != nil {
+		s.Set(false, err, msgFailedToConnect)
+		return s
+	}
+	defer tcpConn.Close()
+	// deadline covers handshake, authentication and the command
+	tcpConn.SetDeadline(time.Now().Add(c.timeout))
+	conn := &versionConn{Conn: tcpConn}
+
+	hostKey := &hostKeyResult{}
+	username := c.username
+	if !c.authEnabled {
+		username = probeUsername
+	}
+	config := &gossh.ClientConfig{
+		User:              username,
+		Auth:              auth,
+		HostKeyCallback:   c.hostKeyCallback(hostKey),
+		HostKeyAlgorithms: c.hostKeyAlgorithms,
+		ClientVersion:     clientVersion,
+		Timeout:           c.timeout,
+	}
+	sshConn, chans, reqs, err := gossh.NewClientConn(conn, addr, config)
+	if err != nil {
+		switch {
+		case hostKey.mismatch:
+			s.Set(false, nil, fmt.Sprintf("%s, server presented %s %s", msgFailedHostKey, hostKey.keyType, hostKey.fingerprint))
+			return s
+		case !hostKey.verified:
+			s.Set(false, err, msgFailedHandshake)
+			return s
+		case c.authEnabled:
+			s.Set(false, err, msgFailedAuth)
+			return s
+		case !isNoneAuthRejected(err):
+			// server stopped responding or closed connection after key exchange
+			s.Set(false, err, msgFailedHandshake)
+			return s
+		}
+		// without credentials the handshake is finished when server rejected "none" method
+	} else {
+		defer sshConn.Close()
+	}
+
+	version := conn.serverVersion()
+	if c.bannerCheckString != "" && !strings.Contains(version, c.bannerCheckString) {
+		s.Set(false, nil, fmt.Sprintf("%s, %q does not contain %q", msgFailedBanner, version, c.bannerCheckString))
+		return s
+	}
+	msg := fmt.Sprintf("%s, server %s, host key %s %s", msgSuccess, version, hostKey.keyType, hostKey.fingerprint)
+
+	if c.command != "" {
+		client := gossh.NewClient(sshConn, chans, reqs)
+		exitStatus, output, err := runCommand(client, c.command)
+		if err != nil {
+			s.Set(false, err, msgFailedCommand)
+			return s
+		}
+		if exitStatus != c.expectedExitStatus {
+			s.Set(false, nil, fmt.Sprintf("%s %d, expected %d, output %q", msgFailedExitStatus, exitStatus, c.expectedExitStatus, truncate(output)))
+			return s
+		}
+		if c.outputCheckString != "" && !strings.Contains(output, c.outputCheckString) {
+			s.Set(false, nil, fmt.Sprintf("%s %q, output %q", msgFailedOutput, c.outputCheckString, truncate(output)))
+			return s
+		}
+		msg = fmt.Sprintf("%s, command exit status %d", msg, exitStatus)
+	}
+
+	s.Set(true, nil, msg)
+	return s
+}
+
+// returns true if the error is the rejection of "none" method sent when auth is disabled
+func isNoneAuthRejected(err error) bool {
+	return strings.Contains(err.Error(), "unable to authenticate") && strings.Contains(err.Error(), "no supported methods remain")
+}
+
+// load credentials, without auth the client offers only "none" method
+func (c *Check) authMethods() ([]gossh.AuthMethod, error) {
+	if !c.authEnabled {
+		return nil, nil
+	}
+	var methods []gossh.AuthMethod
+	if c.privateKey != "" {
+		keyPEM, err := secret.Resolve(c.privateKey)
+		if err != nil {
+			return nil, err
+		}
+		passphrase, err := secret.Value(c.privateKeyPassphrase)
+		if err != nil {
+			return nil, err
+		}
+		var signer gossh.Signer
+		if passphrase != "" {
+			signer, err = gossh.ParsePrivateKeyWithPassphrase(keyPEM, []byte(passphrase))
+		} else {
+			signer, err = gossh.ParsePrivateKey(keyPEM)
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse private key")
+		}
+		methods = append(methods, gossh.PublicKeys(signer))
+	}
+	if c.password != "" {
+		password, err := secret.Value(c.password)
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, gossh.Password(password))
+	}
+	return methods, nil
+}
+
+// host key presented by the server
+type hostKeyResult struct {
+	keyType     string
+	fingerprint string
+	verified    bool
+	mismatch    bool
+}
+
+// compare host key with pinned fingerprints, any key is accepted if there is no pinned fingerprint
+func (c *Check) hostKeyCallback(result *hostKeyResult) gossh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, hostKey gossh.PublicKey) error {
+		result.keyType = hostKey.Type()
+		result.fingerprint = gossh.FingerprintSHA256(hostKey)
+		if len(c.hostKeyFingerprints) == 0 {
+			result.verified = true
+			return nil
+		}
+		for _, fingerprint := range c.hostKeyFingerprints {
+			if fingerprint == result.fingerprint {
+				result.verified = true
+				return nil
+			}
+		}
+		result.mismatch = true
+		return errors.Wrapf(hostKeyMismatchError, "%s %s", result.keyType, result.fingerprint)
+	}
+}
+
+// run command and return its exit status and stdout
+func runCommand(client *gossh.Client, command string) (int, string, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return 0, "", err
+	}
+	defer session.Close()
+
+	var stdout bytes.Buffer
+	session.Stdout = &stdout
+	err = session.Run(command)
+	if exitErr, ok := err.(*gossh.ExitError); ok {
+		return exitErr.ExitStatus(), stdout.String(), nil
+	}
+	if err != nil {
+		return 0, stdout.String(), err
+	}
+	return 0, stdout.String(), nil
+}
+
+// connection which remembers first bytes sent by server, used for getting server version
+// even when authentication fails
+type versionConn struct {
+	net.Conn
+	captured []byte
+}
+
+func (c *versionConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if len(c.captured) < maxVersionCapture {
+		c.captured = append(c.captured, b[:n]...)
+	}
+	return n, err
+}
+
+func (c *versionConn) serverVersion() string {
+	for _, line := range strings.Split(string(c.captured), "\n") {
+		if strings.HasPrefix(line, "SSH-") {
+			return strings.TrimRight(line, "\r")
+		}
+	}
+	return ""
+}
+
+func truncate(output string) string {
+	output = strings.TrimSpace(output)
+	if len(output) > maxOutputLog {
+		return output[:maxOutputLog] + "..."
+	}
+	return output
+}
+
+func (c *Check) GetStringPort() string {
+	return fmt.Sprintf(":%d", c.port)
+}
+
+func (c *Check) LogResult(s *status.Status) {
+	c.log.Log("check-SSH|id %d|reqID %s|target %s|port %d|latency %sms|result '%t'|msg: %s", c.id, c.requestId, c.target, c.port, key.MsFromDuration(s.Duration), s.Result, s.Message)
+}
+
+func (c *Check) LogRunError(err error, message string) {
+	c.log.LogError(err, "running check id:%d reqID:%s type:ssh target:%s:%d failed, reason: %s", c.id, c.requestId, c.target, c.port, message)
+}
@@ -70,6 +70,29 @@ func (s *Status) Set(result bool, err error, msg string) {
 	}
 }
 
+// combine results of partial checks (ie: one per address family) into single status
+// result is successful only if all partial results are successful
+func Combine(names []string, partials []*Status) *Status {
+	combined := *partials[0]
+	combined.Result = true
+	combined.Duration = 0
+	combined.Message = ""
+
+	for i, partial := range partials {
+		combined.Result = combined.Result && partial.Result
+		// checks run in parallel, so the slowest one is the duration of the whole check
+		if partial.Duration > combined.Duration {
+			combined.Duration = partial.Duration
+		}
+		if i > 0 {
+			combined.Message += "; "
+		}
+		combined.Message += names[i] + ": " + partial.Message
+	}
+
+	return &combined
+}
+
 func (s *Status) SaveToDB() {
 	// init  db structure for saving data about status
 	serviceStatus := &status.ServiceStatus{
@@ -2,17 +2,24 @@ package tcp
 
 import (
 	"fmt"
-	"net"
 	"time"
 
 	"github.com/exmonitor/exclient/database"
 	"github.com/exmonitor/exlogger"
+	"github.com/exmonitor/watcher/interval/family"
+	"github.com/exmonitor/watcher/interval/proxy"
 	"github.com/exmonitor/watcher/interval/spec"
 	"github.com/exmonitor/watcher/interval/status"
+	"github.com/exmonitor/watcher/interval/traceroute"
 	"github.com/exmonitor/watcher/key"
 	"github.com/pkg/errors"
 )
 
+const (
+	msgFailedToConnect = "failed to open tcp connection"
+	msgFailedProxy     = "failed - proxy error"
+)
+
 type CheckConfig struct {
 	Id            int
 	FailThreshold int
@@ -20,6 +27,11 @@ type CheckConfig struct {
 	Target        string
 	Port          int
 	Timeout       time.Duration
+	Proxy         string // proxy url, empty for global proxy
+	IpFamily      string // ipv4, ipv6 or both, empty to let resolver decide
+	// path diagnostics attached to failed results, probes use the port of the check
+	Traceroute        string // icmp or tcp, empty to disable
+	TracerouteMaxHops int    // zero for default
 
 	//db client
 	DBClient database.ClientInterface
@@ -34,6 +46,9 @@ type Check struct {
 	target        string
 	port          int
 	timeout       time.Duration
+	dialer        *proxy.Dialer
+	ipFamily      string
+	trace         traceroute.Config
 
 	// db client
 	dbClient database.ClientInterface
@@ -69,6 +84,22 @@ func NewCheck(conf CheckConfig) (*Check, error) {
 	if conf.Logger == nil {
 		return nil, errors.Wrap(invalidConfigError, "conf.Logger must not be nil")
 	}
+	if err := family.Validate(conf.IpFamily); err != nil {
+		return nil, errors.Wrap(invalidConfigError, err.Error())
+	}
+	trace := traceroute.Config{
+		Method:  conf.Traceroute,
+		Port:    conf.Port,
+		MaxHops: conf.TracerouteMaxHops,
+		Timeout: conf.Timeout,
+	}
+	if err := traceroute.Validate(trace); err != nil {
+		return nil, errors.Wrap(invalidConfigError, err.Error())
+	}
+	dialer, err := proxy.New(conf.Proxy, conf.Timeout)
+	if err != nil {
+		return nil, errors.Wrap(err, "conf.Proxy is not valid")
+	}
 
 	newCheck := &Check{
 		id:            conf.Id,
@@ -77,6 +108,9 @@ func NewCheck(conf CheckConfig) (*Check, error) {
 		timeout:       conf.Timeout,
 		port:          conf.Port,
 		target:        conf.Target,
+		dialer:        dialer,
+		ipFamily:      conf.IpFamily,
+		trace:         trace,
 
 		dbClient: conf.DBClient,
 		log:      conf.Logger,
@@ -97,7 +131,12 @@ func (c *Check) RunCheck() {
 	s.SaveToDB()
 }
 
+// run monitoring check, in dual-stack mode each address family is checked separately
 func (c *Check) doCheck() *status.Status {
+	return family.Run(c.ipFamily, c.doFamilyCheck)
+}
+
+func (c *Check) doFamilyCheck(ipFamily string) *status.Status {
 	statusConfig := status.Config{
 		Id:            c.id,
 		ReqId:         c.requestId,
@@ -111,10 +150,20 @@ func (c *Check) doCheck() *status.Status {
 	}
 	tStart := time.Now()
 
-	conn, err := net.DialTimeout("tcp", tcpTargetAddress(c.target, c.port), c.timeout)
+	conn, err := c.dialer.Dial(family.Network("tcp", ipFamily), tcpTargetAddress(c.target, c.port))
 	if err != nil {
-		s.Set(false, err, "failed to open tcp connection")
+		if proxy.IsProxyError(err) {
+			s.Set(false, err, msgFailedProxy)
+		} else if c.dialer.Enabled() {
+			s.Set(false, err, msgFailedToConnect+" via proxy "+c.dialer.String())
+		} else {
+			s.Set(false, err, msgFailedToConnect)
+		}
 		s.Duration = time.Since(tStart)
+		// path from watcher says nothing about connections made by the proxy
+		if !c.dialer.Enabled() {
+			traceroute.Diagnose(c.trace, c.id, c.interval, c.target, ipFamily, s)
+		}
 		return s
 	} else {
 		defer conn.Close()
@@ -125,6 +174,10 @@ func (c *Check) doCheck() *status.Status {
 	}
 
 	s.Duration = time.Since(tStart)
+	// success is recorded too, so the next failure is diagnosed
+	if !c.dialer.Enabled() {
+		traceroute.Diagnose(c.trace, c.id, c.interval, c.target, ipFamily, s)
+	}
 	return s
 }
 
@@ -137,7 +190,7 @@ func (c *Check) GetStringPort() string {
 }
 
 func (c *Check) LogResult(s *status.Status) {
-	c.log.Log("check-TCP|id %d|reqID %s|target %s|port %d|latency %sms|result '%t'|msg: %s", c.id, c.requestId, c.target, c.port, key.MsFromDuration(s.Duration), s.Result, s.Message)
+	c.log.Log("check-TCP|id %d|reqID %s|target %s|port %d|proxy %s|family %s|latency %sms|result '%t'|msg: %s", c.id, c.requestId, c.target, c.port, c.dialer, c.ipFamily, key.MsFromDuration(s.Duration), s.Result, s.Message)
 }
 
 func (c *Check) LogRunError(err error, message string) {
@@ -0,0 +1,87 @@
+package tlscheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/exmonitor/exclient/database"
+	"github.com/exmonitor/exclient/database/spec/service"
+	"github.com/exmonitor/exlogger"
+	"github.com/pkg/errors"
+
+	"github.com/exmonitor/watcher/interval/certs"
+)
+
+/*
+Example metadata:
+{
+	"id": 4,
+	"target": "mail.domain.cz",
+	"port": 25,
+	"timeout": 5,
+	"startTls": "smtp",
+	"serverName": "mail.domain.cz",
+	"caBundle": "file:/etc/watcher/tls/internal-ca.pem",
+	"certExpirationThreshold": 14,
+	"revocationCheckEnabled": true,
+	"requireOcspStaple": false,
+	"auditEnabled": true,
+	"minVersion": "TLS1.2",
+	"disallowedCiphers": ["TLS_RSA_WITH_RC4_128_SHA"],
+	"allowSelfSigned": false
+}
+*/
+
+type RawCheck struct {
+	Id                      int      `json:"id"`
+	Target                  string   `json:"target"`
+	Port                    int      `json:"port"`
+	Timeout                 int      `json:"timeout"`
+	StartTls                string   `json:"startTls"`
+	ServerName              string   `json:"serverName"`
+	CaBundle                string   `json:"caBundle"`
+	CertExpirationThreshold int      `json:"certExpirationThreshold"`
+	RevocationCheckEnabled  bool     `json:"revocationCheckEnabled"`
+	RequireOcspStaple       bool     `json:"requireOcspStaple"`
+	AuditEnabled            bool     `json:"auditEnabled"`
+	MinVersion              string   `json:"minVersion"`
+	DisallowedCiphers       []string `json:"disallowedCiphers"`
+	AllowSelfSigned         bool     `json:"allowSelfSigned"`
+}
+
+func ParseCheck(service *service.Service, dbClient database.ClientInterface, logger *exlogger.Logger) (*Check, error) {
+	var rawCheck RawCheck
+	err := json.Unmarshal([]byte(service.Metadata), &rawCheck)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to parse TLS json metadata for check id %d", service.ID))
+	} else {
+		logger.LogDebug("Successfully parsed TLS json metadata for check id %d", service.ID)
+	}
+
+	checkConfig := CheckConfig{
+		Id:            service.ID,
+		FailThreshold: service.FailThreshold,
+		Interval:      service.Interval,
+		Target:        rawCheck.Target,
+		Port:          rawCheck.Port,
+		Timeout:       time.Second * time.Duration(rawCheck.Timeout),
+		StartTLS:      rawCheck.StartTls,
+		ServerName:    rawCheck.ServerName,
+		CABundle:      rawCheck.CaBundle,
+
+		CertExpirationThreshold: time.Hour * 24 * time.Duration(rawCheck.CertExpirationThreshold), // convert to days
+		RevocationCheckEnabled:  rawCheck.RevocationCheckEnabled,
+		RequireOcspStaple:       rawCheck.RequireOcspStaple,
+		AuditEnabled:            rawCheck.AuditEnabled,
+		AuditOptions: certs.AuditOptions{
+			MinVersion:        rawCheck.MinVersion,
+			DisallowedCiphers: rawCheck.DisallowedCiphers,
+			AllowSelfSigned:   rawCheck.AllowSelfSigned,
+		},
+		Logger:   logger,
+		DBClient: dbClient,
+	}
+
+	return NewCheck(checkConfig)
+}
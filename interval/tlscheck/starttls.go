@@ -0,0 +1,167 @@
+package tlscheck
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/exmonitor/watcher/interval/smtp"
+)
+
+// protocols supporting upgrade of plain connection to tls
+const (
+	StartTLSNone = "" // implicit tls, handshake starts right after connect
+	StartTLSSmtp = "smtp"
+	StartTLSImap = "imap"
+	StartTLSPop3 = "pop3"
+	StartTLSFtp  = "ftp"
+	StartTLSXmpp = "xmpp"
+)
+
+// max size of xmpp stream features we are willing to read
+const xmppMaxFeaturesSize = 64 * 1024
+
+var startTLSFuncs = map[string]func(conn net.Conn, serverName string) error{
+	StartTLSNone: func(conn net.Conn, serverName string) error { return nil },
+	StartTLSSmtp: startTLSSmtp,
+	StartTLSImap: startTLSImap,
+	StartTLSPop3: startTLSPop3,
+	StartTLSFtp:  startTLSFtp,
+	StartTLSXmpp: startTLSXmpp,
+}
+
+// negotiate tls upgrade over plain connection, after successful return the connection is ready for tls handshake
+func startTLS(protocol string, conn net.Conn, serverName string) error {
+	f, ok := startTLSFuncs[protocol]
+	if !ok {
+		return errors.Wrapf(invalidConfigError, "starttls protocol %s is not supported", protocol)
+	}
+	return f(conn, serverName)
+}
+
+// RFC 3207
+func startTLSSmtp(conn net.Conn, serverName string) error {
+	text := textproto.NewConn(conn)
+	if _, _, err := text.ReadResponse(220); err != nil {
+		return errors.Wrap(err, "smtp banner")
+	}
+	if _, _, err := smtp.Cmd(text, 250, "EHLO %s", smtp.HelloName()); err != nil {
+		return errors.Wrap(err, "smtp EHLO")
+	}
+	if _, _, err := smtp.Cmd(text, 220, "STARTTLS"); err != nil {
+		return errors.Wrap(err, "smtp STARTTLS")
+	}
+	return nil
+}
+
+// RFC 2595
+func startTLSImap(conn net.Conn, serverName string) error {
+	text := textproto.NewConn(conn)
+	greeting, err := text.ReadLine()
+	if err != nil {
+		return errors.Wrap(err, "imap greeting")
+	}
+	if !strings.HasPrefix(greeting, "* OK") {
+		return fmt.Errorf("imap greeting: unexpected response %q", greeting)
+	}
+	if err := text.PrintfLine("a001 STARTTLS"); err != nil {
+		return errors.Wrap(err, "imap STARTTLS")
+	}
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return errors.Wrap(err, "imap STARTTLS")
+		}
+		// skip untagged responses
+		if strings.HasPrefix(line, "* ") {
+			continue
+		}
+		if !strings.HasPrefix(line, "a001 OK") {
+			return fmt.Errorf("imap STARTTLS: unexpected response %q", line)
+		}
+		return nil
+	}
+}
+
+// RFC 2595
+func startTLSPop3(conn net.Conn, serverName string) error {
+	text := textproto.NewConn(conn)
+	greeting, err := text.ReadLine()
+	if err != nil {
+		return errors.Wrap(err, "pop3 greeting")
+	}
+	if !strings.HasPrefix(greeting, "+OK") {
+		return fmt.Errorf("pop3 greeting: unexpected response %q", greeting)
+	}
+	if err := text.PrintfLine("STLS"); err != nil {
+		return errors.Wrap(err, "pop3 STLS")
+	}
+	line, err := text.ReadLine()
+	if err != nil {
+		return errors.Wrap(err, "pop3 STLS")
+	}
+	if !strings.HasPrefix(line, "+OK") {
+		return fmt.Errorf("pop3 STLS: unexpected response %q", line)
+	}
+	return nil
+}
+
+// RFC 4217
+func startTLSFtp(conn net.Conn, serverName string) error {
+	text := textproto.NewConn(conn)
+	if _, _, err := text.ReadResponse(220); err != nil {
+		return errors.Wrap(err, "ftp banner")
+	}
+	if _, _, err := smtp.Cmd(text, 234, "AUTH TLS"); err != nil {
+		return errors.Wrap(err, "ftp AUTH TLS")
+	}
+	return nil
+}
+
+// RFC 6120
+func startTLSXmpp(conn net.Conn, serverName string) error {
+	header := fmt.Sprintf("<?xml version='1.0'?><stream:stream to='%s' xmlns='jabber:client' xmlns:stream='http://etherx.jabber.org/streams' version='1.0'>", serverName)
+	if _, err := conn.Write([]byte(header)); err != nil {
+		return errors.Wrap(err, "xmpp stream")
+	}
+	reader := bufio.NewReader(conn)
+	if err := readUntil(reader, "</stream:features>"); err != nil {
+		return errors.Wrap(err, "xmpp stream features")
+	}
+	if _, err := conn.Write([]byte("<starttls xmlns='urn:ietf:params:xml:ns:xmpp-tls'/>")); err != nil {
+		return errors.Wrap(err, "xmpp starttls")
+	}
+	if err := readUntil(reader, "<proceed"); err != nil {
+		return errors.Wrap(err, "xmpp starttls")
+	}
+	// read rest of the proceed element, tls handshake starts right after it
+	if err := readUntil(reader, ">"); err != nil {
+		return errors.Wrap(err, "xmpp starttls")
+	}
+	return nil
+}
+
+// read data from the reader until the marker is found
+func readUntil(reader *bufio.Reader, marker string) error {
+	var data []byte
+	for !bytes.HasSuffix(data, []byte(marker)) {
+		if len(data) > xmppMaxFeaturesSize {
+			return fmt.Errorf("%s not found in first %d bytes", marker, xmppMaxFeaturesSize)
+		}
+		b, err := reader.ReadByte()
+		if err != nil {
+			return err
+		}
+		data = append(data, b)
+		// server refused the request
+		if bytes.HasSuffix(data, []byte("<failure")) {
+			return fmt.Errorf("server responded with failure")
+		}
+	}
+	return nil
+}
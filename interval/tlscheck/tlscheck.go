@@ -0,0 +1,273 @@
+package tlscheck
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/exmonitor/exclient/database"
+	"github.com/exmonitor/exlogger"
+	"github.com/pkg/errors"
+
+	"github.com/exmonitor/watcher/interval/certs"
+	"github.com/exmonitor/watcher/interval/spec"
+	"github.com/exmonitor/watcher/interval/status"
+	"github.com/exmonitor/watcher/key"
+)
+
+const (
+	msgSuccess           = "success"
+	msgFailedToConnect   = "failed to open tcp connection"
+	msgFailedStartTLS    = "failed - starttls negotiation"
+	msgFailedToHandshake = "failed - tls handshake"
+	msgFailedTLSAudit    = "failed - tls audit"
+	msgFailedRevocation  = "failed - certificate revocation issue"
+
+	msgInternalFailedTLSMaterial = "INTERNAL: failed to load CA bundle"
+)
+
+type CheckConfig struct {
+	Id            int
+	FailThreshold int
+	Interval      int
+	Target        string
+	Port          int
+	Timeout       time.Duration
+
+	// tls options
+	StartTLS                string // protocol used for upgrade to tls, empty for implicit tls
+	ServerName              string // SNI and expected hostname, default is target
+	CABundle                string // secret reference, system roots are used if empty
+	CertExpirationThreshold time.Duration
+	AuditEnabled            bool
+	AuditOptions            certs.AuditOptions
+	RevocationCheckEnabled  bool
+	RequireOcspStaple       bool
+
+	//db client
+	DBClient database.ClientInterface
+	Logger   *exlogger.Logger
+}
+
+type Check struct {
+	id            int
+	failThreshold int
+	interval      int
+	requestId     string
+	target        string
+	port          int
+	timeout       time.Duration
+
+	// tls options
+	startTLS                string
+	serverName              string
+	tlsMaterial             certs.Config
+	certExpirationThreshold time.Duration
+	auditor                 *certs.Auditor // nil if audit is disabled
+	revocationCheckEnabled  bool
+	requireOcspStaple       bool
+
+	// db client
+	dbClient database.ClientInterface
+	// logger
+	log *exlogger.Logger
+
+	// internals
+	spec.CheckInterface
+}
+
+func NewCheck(conf CheckConfig) (*Check, error) {
+	if conf.Id == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.id must not be zero")
+	}
+	if conf.FailThreshold == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.FailThreshold must not be zero")
+	}
+	if conf.Interval == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.Interval must not be zero")
+	}
+	if conf.Target == "" {
+		return nil, errors.Wrap(invalidConfigError, "conf.Target must not be empty")
+	}
+	if conf.Port == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.Port must not be zero")
+	}
+	if conf.Timeout == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.Timeout must not be zero")
+	}
+	if conf.DBClient == nil {
+		return nil, errors.Wrap(invalidConfigError, "conf.DBClient must not be nil")
+	}
+	if conf.Logger == nil {
+		return nil, errors.Wrap(invalidConfigError, "conf.Logger must not be nil")
+	}
+	if conf.ServerName == "" {
+		conf.ServerName = conf.Target
+	}
+	if _, ok := startTLSFuncs[conf.StartTLS]; !ok {
+		return nil, errors.Wrapf(invalidConfigError, "starttls protocol %s is not supported", conf.StartTLS)
+	}
+	if conf.CertExpirationThreshold == 0 && !conf.AuditEnabled && !conf.RevocationCheckEnabled {
+		return nil, errors.Wrap(invalidConfigError, "conf.CertExpirationThreshold must not be zero, when audit and revocation check are disabled")
+	}
+	if conf.RequireOcspStaple && !conf.RevocationCheckEnabled {
+		return nil, errors.Wrap(invalidConfigError, "conf.RequireOcspStaple can be used only when RevocationCheckEnabled is enabled")
+	}
+	var auditor *certs.Auditor
+	if conf.AuditEnabled {
+		var err error
+		auditor, err = certs.NewAuditor(conf.AuditOptions)
+		if err != nil {
+			return nil, errors.Wrap(err, "conf.AuditOptions are not valid")
+		}
+	}
+
+	newCheck := &Check{
+		id:            conf.Id,
+		failThreshold: conf.FailThreshold,
+		interval:      conf.Interval,
+		timeout:       conf.Timeout,
+		port:          conf.Port,
+		target:        conf.Target,
+
+		startTLS:                conf.StartTLS,
+		serverName:              conf.ServerName,
+		tlsMaterial:             certs.Config{CABundle: conf.CABundle},
+		certExpirationThreshold: conf.CertExpirationThreshold,
+		auditor:                 auditor,
+		revocationCheckEnabled:  conf.RevocationCheckEnabled,
+		requireOcspStaple:       conf.RequireOcspStaple,
+
+		dbClient: conf.DBClient,
+		log:      conf.Logger,
+	}
+
+	return newCheck, nil
+}
+
+// wrapper function used to run in separate thread (goroutine)
+func (c *Check) RunCheck() {
+	// generate unique request ID
+	c.requestId = key.GenerateReqId(c.id)
+	// run monitoring check
+	s := c.doCheck()
+	c.LogResult(s)
+
+	// save result to database
+	s.SaveToDB()
+}
+
+func (c *Check) doCheck() *status.Status {
+	statusConfig := status.Config{
+		Id:            c.id,
+		ReqId:         c.requestId,
+		Interval:      c.interval,
+		FailThreshold: c.failThreshold,
+		DBClient:      c.dbClient,
+	}
+	s, err := status.New(statusConfig)
+	if err != nil {
+		c.LogRunError(err, fmt.Sprintf("failed to init new status for TLS service ID %d", c.id))
+	}
+	tStart := time.Now()
+
+	// custom CA bundle
+	tlsMaterial := &certs.Material{}
+	if c.tlsMaterial.Enabled() {
+		tlsMaterial, err = certs.Load(c.id, c.tlsMaterial)
+		if err != nil {
+			c.LogRunError(err, msgInternalFailedTLSMaterial)
+			s.Set(false, err, msgInternalFailedTLSMaterial)
+			return s
+		}
+	}
+
+	// audit verifies chain and hostname itself and reports them as findings,
+	// without audit the handshake fails on untrusted chain or hostname mismatch
+	tlsConfig := &tls.Config{
+		ServerName:         c.serverName,
+		InsecureSkipVerify: c.auditor != nil,
+	}
+	tlsMaterial.Apply(tlsConfig)
+	conn, err := c.dial()
+	if err != nil {
+		s.Set(false, err, msgFailedToConnect)
+		s.Duration = time.Since(tStart)
+		return s
+	}
+	conn.SetDeadline(time.Now().Add(c.timeout))
+	if err := startTLS(c.startTLS, conn, c.serverName); err != nil {
+		conn.Close()
+		s.Set(false, err, msgFailedStartTLS)
+		s.Duration = time.Since(tStart)
+		return s
+	}
+	state, err := certs.Handshake(conn, tlsConfig, c.timeout)
+	if err != nil {
+		s.Set(false, err, msgFailedToHandshake)
+		s.Duration = time.Since(tStart)
+		return s
+	}
+	s.Duration = time.Since(tStart)
+
+	// check certificates expiration
+	if c.certExpirationThreshold > 0 {
+		certsOK, message := certs.CheckExpiration(state, c.certExpirationThreshold)
+		if !certsOK {
+			s.Set(false, nil, message)
+			return s
+		}
+	}
+
+	// check that server certificate is not revoked
+	if c.revocationCheckEnabled {
+		notRevoked, message := certs.CheckRevocation(state, c.requireOcspStaple, c.timeout)
+		if !notRevoked {
+			s.Set(false, nil, fmt.Sprintf("%s, %s", msgFailedRevocation, message))
+			return s
+		}
+	}
+
+	// audit tls configuration of the server
+	if c.auditor != nil {
+		// new connection for each probe
+		handshake := func(probeConfig *tls.Config) (*tls.ConnectionState, error) {
+			conn, err := c.dial()
+			if err != nil {
+				return nil, err
+			}
+			conn.SetDeadline(time.Now().Add(c.timeout))
+			if err := startTLS(c.startTLS, conn, c.serverName); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			return certs.Handshake(conn, probeConfig, c.timeout)
+		}
+		findings := c.auditor.Audit(state, c.serverName, tlsMaterial.RootCAs, handshake)
+		if len(findings) > 0 {
+			s.Set(false, nil, fmt.Sprintf("%s, %s", msgFailedTLSAudit, certs.FormatFindings(findings)))
+			return s
+		}
+	}
+
+	s.Set(true, nil, msgSuccess)
+	return s
+}
+
+func (c *Check) dial() (net.Conn, error) {
+	return net.DialTimeout("tcp", net.JoinHostPort(c.target, strconv.Itoa(c.port)), c.timeout)
+}
+
+func (c *Check) GetStringPort() string {
+	return fmt.Sprintf(":%d", c.port)
+}
+
+func (c *Check) LogResult(s *status.Status) {
+	c.log.Log("check-TLS|id %d|reqID %s|target %s|port %d|starttls %s|latency %sms|result '%t'|msg: %s", c.id, c.requestId, c.target, c.port, c.startTLS, key.MsFromDuration(s.Duration), s.Result, s.Message)
+}
+
+func (c *Check) LogRunError(err error, message string) {
+	c.log.LogError(err, "running check id:%d reqID:%s type:tls target:%s:%d failed, reason: %s", c.id, c.requestId, c.target, c.port, message)
+}
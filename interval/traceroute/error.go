@@ -0,0 +1,5 @@
+package traceroute
+
+import "errors"
+
+var invalidConfigError error = errors.New("invalid traceroute config")
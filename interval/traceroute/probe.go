@@ -0,0 +1,249 @@
+package traceroute
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"net"
+	"strconv"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// ip protocol numbers
+const (
+	protocolICMP   = 1
+	protocolTCP    = 6
+	protocolICMPv6 = 58
+)
+
+// send icmp echo request with increasing ttl, routers answer with time exceeded and the target with echo reply,
+// responses are read while probes are sent, so pacing does not add to their round trip time
+func (t *tracer) traceICMP() error {
+	id := rand.Intn(0xffff) + 1
+	t.conn.SetReadDeadline(time.Now().Add(time.Duration(t.conf.MaxHops-1)*t.conf.Pacing + t.conf.Timeout))
+
+	sendErr := make(chan error, 1)
+	go func() {
+		err := t.sendEcho(id)
+		if err != nil {
+			// stop reading
+			t.conn.SetReadDeadline(time.Now())
+		}
+		sendErr <- err
+	}()
+
+	t.receive(func(m *icmp.Message, peer net.Addr, now time.Time) {
+		if echo, ok := m.Body.(*icmp.Echo); ok {
+			if (m.Type == ipv4.ICMPTypeEchoReply || m.Type == ipv6.ICMPTypeEchoReply) && echo.ID == id {
+				t.record(echo.Seq, peer, true, false, now)
+			}
+			return
+		}
+		protocol, header, unreachable, ok := t.quotedPacket(m)
+		if !ok || protocol != t.proto || len(header) < 8 {
+			return
+		}
+		if int(binary.BigEndian.Uint16(header[4:6])) == id {
+			t.record(int(binary.BigEndian.Uint16(header[6:8])), peer, false, unreachable, now)
+		}
+	})
+	return <-sendErr
+}
+
+// send echo requests with the id and ttl as sequence number, sending stops once the whole path is known
+func (t *tracer) sendEcho(id int) error {
+	var echoType icmp.Type = ipv4.ICMPTypeEcho
+	if t.ipv6 {
+		echoType = ipv6.ICMPTypeEchoRequest
+	}
+	for ttl := 1; ttl <= t.conf.MaxHops; ttl++ {
+		if ttl > 1 && t.conf.Pacing > 0 {
+			time.Sleep(t.conf.Pacing)
+		}
+		if t.done() {
+			return nil
+		}
+		if err := t.setTTL(ttl); err != nil {
+			return err
+		}
+		msg := icmp.Message{Type: echoType, Body: &icmp.Echo{ID: id, Seq: ttl, Data: []byte("watcher")}}
+		b, err := msg.Marshal(nil)
+		if err != nil {
+			return err
+		}
+		t.markSent(ttl, time.Now())
+		if _, err := t.conn.WriteTo(b, &net.IPAddr{IP: t.dst}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// open tcp connection with increasing ttl, routers answer with time exceeded and the target with syn-ack or reset,
+// probes are identified by their source port quoted in icmp errors
+func (t *tracer) traceTCP() error {
+	deadline := time.Now().Add(t.conf.Timeout)
+	t.conn.SetReadDeadline(deadline)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	ports := make(map[int]int)
+	errs := make(chan error, t.conf.MaxHops)
+	for ttl := 1; ttl <= t.conf.MaxHops; ttl++ {
+		go func(ttl int) {
+			errs <- t.probeTCP(ctx, ttl, ports)
+		}(ttl)
+	}
+
+	t.receive(func(m *icmp.Message, peer net.Addr, now time.Time) {
+		protocol, header, unreachable, ok := t.quotedPacket(m)
+		if !ok || protocol != protocolTCP || len(header) < 2 {
+			return
+		}
+		t.Lock()
+		ttl, ok := ports[int(binary.BigEndian.Uint16(header[0:2]))]
+		t.Unlock()
+		if ok {
+			t.record(ttl, peer, false, unreachable, now)
+		}
+	})
+
+	cancel()
+	var err error
+	for i := 0; i < t.conf.MaxHops; i++ {
+		if probeErr := <-errs; probeErr != nil && err == nil {
+			err = probeErr
+		}
+	}
+	return err
+}
+
+// connect with the given ttl, only failures to prepare the socket are returned
+func (t *tracer) probeTCP(ctx context.Context, ttl int, ports map[int]int) error {
+	var prepareErr error
+	dialer := net.Dialer{
+		Control: func(network string, address string, c syscall.RawConn) error {
+			if err := c.Control(func(fd uintptr) {
+				prepareErr = t.prepareSocket(int(fd), ttl, ports)
+			}); err != nil {
+				return err
+			}
+			return prepareErr
+		},
+	}
+	network := "tcp4"
+	if t.ipv6 {
+		network = "tcp6"
+	}
+	conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(t.dst.String(), strconv.Itoa(t.conf.Port)))
+	now := time.Now()
+	if err == nil {
+		conn.Close()
+		t.record(ttl, &net.IPAddr{IP: t.dst}, true, false, now)
+	} else if errors.Is(err, syscall.ECONNREFUSED) {
+		// reset from the target, port is closed but the path works
+		t.record(ttl, &net.IPAddr{IP: t.dst}, true, false, now)
+	}
+	return prepareErr
+}
+
+// set ttl and bind the socket before connect, so the source port is known before syn is sent
+func (t *tracer) prepareSocket(fd int, ttl int, ports map[int]int) error {
+	var local syscall.Sockaddr
+	if t.ipv6 {
+		if err := syscall.SetsockoptInt(fd, syscall.IPPROTO_IPV6, syscall.IPV6_UNICAST_HOPS, ttl); err != nil {
+			return err
+		}
+		local = &syscall.SockaddrInet6{}
+	} else {
+		if err := syscall.SetsockoptInt(fd, syscall.IPPROTO_IP, syscall.IP_TTL, ttl); err != nil {
+			return err
+		}
+		local = &syscall.SockaddrInet4{}
+	}
+	if err := syscall.Bind(fd, local); err != nil {
+		return err
+	}
+	sa, err := syscall.Getsockname(fd)
+	if err != nil {
+		return err
+	}
+	var port int
+	switch a := sa.(type) {
+	case *syscall.SockaddrInet4:
+		port = a.Port
+	case *syscall.SockaddrInet6:
+		port = a.Port
+	}
+
+	t.Lock()
+	ports[port] = ttl
+	t.sent[ttl] = time.Now()
+	t.Unlock()
+	return nil
+}
+
+func (t *tracer) setTTL(ttl int) error {
+	if t.ipv6 {
+		return t.conn.IPv6PacketConn().SetHopLimit(ttl)
+	}
+	return t.conn.IPv4PacketConn().SetTTL(ttl)
+}
+
+// read icmp messages until the read deadline, which is moved to now once the path is complete
+func (t *tracer) receive(handle func(m *icmp.Message, peer net.Addr, now time.Time)) {
+	buf := make([]byte, 1500)
+	for {
+		n, peer, err := t.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		now := time.Now()
+		m, err := icmp.ParseMessage(t.proto, buf[:n])
+		if err != nil {
+			continue
+		}
+		handle(m, peer, now)
+	}
+}
+
+// returns protocol and transport header of our probe quoted in icmp time exceeded or destination unreachable message
+func (t *tracer) quotedPacket(m *icmp.Message) (protocol int, header []byte, unreachable bool, ok bool) {
+	var data []byte
+	switch body := m.Body.(type) {
+	case *icmp.TimeExceeded:
+		data = body.Data
+	case *icmp.DstUnreach:
+		data, unreachable = body.Data, true
+	default:
+		return 0, nil, false, false
+	}
+
+	var dst net.IP
+	if t.ipv6 {
+		if len(data) < ipv6.HeaderLen {
+			return 0, nil, false, false
+		}
+		protocol, dst, header = int(data[6]), net.IP(data[24:40]), data[ipv6.HeaderLen:]
+	} else {
+		if len(data) < ipv4.HeaderLen {
+			return 0, nil, false, false
+		}
+		headerLen := int(data[0]&0x0f) * 4
+		if len(data) < headerLen {
+			return 0, nil, false, false
+		}
+		protocol, dst, header = int(data[9]), net.IP(data[16:20]), data[headerLen:]
+	}
+	// other traces may run at the same time
+	if !dst.Equal(t.dst) {
+		return 0, nil, false, false
+	}
+	return protocol, header, unreachable, true
+}
@@ -0,0 +1,23 @@
+package traceroute
+
+import (
+	"time"
+
+	"github.com/exmonitor/watcher/interval/checkstate"
+)
+
+// last result of each check
+var lastResults = checkstate.NewStore()
+
+// record the result and return true if it differs from the previous one,
+// the first result of the check after watcher start is considered a change
+func stateChanged(key string, result bool, ttl time.Duration) bool {
+	changed := true
+	lastResults.Update(key, ttl, func(value interface{}) interface{} {
+		if last, ok := value.(bool); ok {
+			changed = last != result
+		}
+		return result
+	})
+	return changed
+}
@@ -0,0 +1,250 @@
+package traceroute
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/icmp"
+
+	"github.com/exmonitor/watcher/interval/checkstate"
+	"github.com/exmonitor/watcher/interval/family"
+	"github.com/exmonitor/watcher/interval/status"
+	"github.com/exmonitor/watcher/key"
+)
+
+// probe methods
+const (
+	MethodICMP = "icmp" // icmp echo requests
+	MethodTCP  = "tcp"  // tcp syn packets, passes firewalls which drop icmp echo
+)
+
+const (
+	DefaultMaxHops = 30
+	maxMaxHops     = 64
+
+	msgFailedTrace = "traceroute failed"
+)
+
+type Config struct {
+	Method  string        // icmp or tcp, empty to disable diagnostics
+	Port    int           // destination port of tcp probes
+	MaxHops int           // zero for default
+	Timeout time.Duration // time to wait for responses after the last probe
+	Pacing  time.Duration // delay between probes of consecutive ttls (icmp only), zero probes all hops at once
+}
+
+// single hop of the path
+type Hop struct {
+	TTL         int
+	Addr        string // address of the responding router, empty if the probe timed out
+	RTT         time.Duration
+	Reached     bool // response came from the target
+	Unreachable bool // router reported the target as unreachable
+}
+
+func (h Hop) String() string {
+	if h.Addr == "" {
+		return fmt.Sprintf("%d *", h.TTL)
+	}
+	s := fmt.Sprintf("%d %s %sms", h.TTL, h.Addr, key.MsFromDuration(h.RTT))
+	if h.Unreachable {
+		s += " (unreachable)"
+	}
+	return s
+}
+
+// check if the traceroute options are supported
+func Validate(conf Config) error {
+	switch conf.Method {
+	case "", MethodICMP:
+	case MethodTCP:
+		if conf.Port <= 0 || conf.Port > 65535 {
+			return errors.Wrapf(invalidConfigError, "port %d is not valid for tcp traceroute", conf.Port)
+		}
+	default:
+		return errors.Wrapf(invalidConfigError, "traceroute method %s is not supported", conf.Method)
+	}
+	if conf.Method == MethodTCP && conf.Pacing != 0 {
+		return errors.Wrap(invalidConfigError, "pacing is supported only by icmp traceroute")
+	}
+	if conf.MaxHops < 0 || conf.MaxHops > maxMaxHops {
+		return errors.Wrapf(invalidConfigError, "traceroute max hops must be between 1 and %d", maxMaxHops)
+	}
+	return nil
+}
+
+// trace path to the target on the first failure after state change and attach the hops to the failed status,
+// diagnostics run at most once per state change, so a target which is down does not get traced every interval
+func Diagnose(conf Config, id int, interval int, target string, ipFamily string, s *status.Status) {
+	if conf.Method == "" {
+		return
+	}
+	if !stateChanged(fmt.Sprintf("%d/%s", id, ipFamily), s.Result, checkstate.Expiration(interval)) || s.Result {
+		return
+	}
+
+	ip, err := family.ResolveIPAddr(target, ipFamily)
+	if err != nil {
+		s.Set(false, nil, fmt.Sprintf(", %s: %s", msgFailedTrace, err))
+		return
+	}
+	hops, err := Trace(conf, ip.IP)
+	if err != nil {
+		s.Set(false, nil, fmt.Sprintf(", %s: %s", msgFailedTrace, err))
+		return
+	}
+	s.Set(false, nil, fmt.Sprintf(", %s path: %s", conf.Method, Format(hops)))
+}
+
+// probe all hops towards the destination (at once unless pacing is set) and wait for the responses,
+// hops after the first one which reached the target or reported it as unreachable are dropped
+func Trace(conf Config, dst net.IP) ([]Hop, error) {
+	if conf.MaxHops == 0 {
+		conf.MaxHops = DefaultMaxHops
+	}
+	t, err := newTracer(conf, dst)
+	if err != nil {
+		return nil, err
+	}
+	defer t.conn.Close()
+
+	switch conf.Method {
+	case MethodTCP:
+		err = t.traceTCP()
+	default:
+		err = t.traceICMP()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t.result(), nil
+}
+
+// format hops on single line, trailing timeouts are collapsed, ie: "1 192.0.2.1 0.45ms, 2 *, 3-30 *"
+func Format(hops []Hop) string {
+	last := len(hops)
+	for last > 0 && hops[last-1].Addr == "" {
+		last--
+	}
+	parts := make([]string, 0, last+1)
+	for _, hop := range hops[:last] {
+		parts = append(parts, hop.String())
+	}
+	switch len(hops) - last {
+	case 0:
+	case 1:
+		parts = append(parts, hops[last].String())
+	default:
+		parts = append(parts, fmt.Sprintf("%d-%d *", hops[last].TTL, hops[len(hops)-1].TTL))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// state of single trace, probes are identified by their ttl
+type tracer struct {
+	conf  Config
+	dst   net.IP
+	ipv6  bool
+	proto int // icmp protocol number used for parsing messages
+	conn  *icmp.PacketConn
+
+	sync.Mutex
+	sent []time.Time // send time of each probe indexed by ttl
+	hops []Hop       // indexed by ttl-1
+}
+
+func newTracer(conf Config, dst net.IP) (*tracer, error) {
+	t := &tracer{
+		conf: conf,
+		dst:  dst,
+		ipv6: dst.To4() == nil,
+		sent: make([]time.Time, conf.MaxHops+1),
+		hops: make([]Hop, conf.MaxHops),
+	}
+	for i := range t.hops {
+		t.hops[i].TTL = i + 1
+	}
+
+	var err error
+	if t.ipv6 {
+		t.proto = protocolICMPv6
+		t.conn, err = icmp.ListenPacket("ip6:ipv6-icmp", "::")
+	} else {
+		t.proto = protocolICMP
+		t.conn, err = icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open raw icmp socket")
+	}
+	return t, nil
+}
+
+// store the first response of the probe, reading is interrupted once the whole path is known
+func (t *tracer) record(ttl int, peer net.Addr, reached bool, unreachable bool, now time.Time) {
+	t.Lock()
+	defer t.Unlock()
+	if ttl < 1 || ttl > t.conf.MaxHops || t.sent[ttl].IsZero() || t.hops[ttl-1].Addr != "" {
+		return
+	}
+	hop := &t.hops[ttl-1]
+	hop.Addr = addrIP(peer)
+	hop.RTT = now.Sub(t.sent[ttl])
+	hop.Reached = reached
+	hop.Unreachable = unreachable
+
+	if t.complete() {
+		t.conn.SetReadDeadline(now)
+	}
+}
+
+// returns true if the target responded and all hops before it are known
+func (t *tracer) complete() bool {
+	for _, hop := range t.hops {
+		if hop.Addr == "" {
+			return false
+		}
+		if hop.Reached || hop.Unreachable {
+			return true
+		}
+	}
+	return false
+}
+
+// returns true if the whole path is known, no more probes are needed
+func (t *tracer) done() bool {
+	t.Lock()
+	defer t.Unlock()
+	return t.complete()
+}
+
+func (t *tracer) markSent(ttl int, now time.Time) {
+	t.Lock()
+	defer t.Unlock()
+	t.sent[ttl] = now
+}
+
+// returns hops up to the first one which ended the path
+func (t *tracer) result() []Hop {
+	t.Lock()
+	defer t.Unlock()
+	for i, hop := range t.hops {
+		if hop.Reached || hop.Unreachable {
+			return append([]Hop(nil), t.hops[:i+1]...)
+		}
+	}
+	return append([]Hop(nil), t.hops...)
+}
+
+func addrIP(addr net.Addr) string {
+	switch a := addr.(type) {
+	case *net.IPAddr:
+		return a.IP.String()
+	case *net.TCPAddr:
+		return a.IP.String()
+	}
+	return addr.String()
+}
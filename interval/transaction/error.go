@@ -0,0 +1,5 @@
+package transaction
+
+import "errors"
+
+var invalidConfigError error = errors.New("invalid check config")
@@ -0,0 +1,208 @@
+package transaction
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// variable extracted from step response, exactly one of Header, JsonPath or Cookie can be set,
+// Regex alone is applied to response body, with other source its applied to their value
+type Extract struct {
+	Name     string `json:"name"`
+	Regex    string `json:"regex"`    // first capture group or whole match is used
+	Header   string `json:"header"`   // response header name
+	JsonPath string `json:"jsonPath"` // ie: $.data.items[0].id
+	Cookie   string `json:"cookie"`   // cookie set by the response
+
+	regex *regexp.Regexp
+	path  []interface{} // parsed json path, string keys and int indexes
+}
+
+var variablePattern = regexp.MustCompile(`\{\{([A-Za-z0-9_.-]+)\}\}`)
+
+func (e *Extract) init() error {
+	if e.Name == "" {
+		return errors.New("extract name must not be empty")
+	}
+	sources := 0
+	for _, source := range []string{e.Header, e.JsonPath, e.Cookie} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources > 1 || (sources == 0 && e.Regex == "") {
+		return errors.Errorf("extract %s must have exactly one of header, jsonPath or cookie, or only regex", e.Name)
+	}
+	if e.Regex != "" {
+		regex, err := regexp.Compile(e.Regex)
+		if err != nil {
+			return errors.Wrapf(err, "extract %s has invalid regex", e.Name)
+		}
+		e.regex = regex
+	}
+	if e.JsonPath != "" {
+		path, err := parseJsonPath(e.JsonPath)
+		if err != nil {
+			return errors.Wrapf(err, "extract %s has invalid jsonPath", e.Name)
+		}
+		e.path = path
+	}
+	return nil
+}
+
+// get the variable value from the response
+func (e *Extract) value(resp *http.Response, jar http.CookieJar, body []byte) (string, error) {
+	var value string
+	switch {
+	case e.Header != "":
+		value = resp.Header.Get(e.Header)
+		if value == "" {
+			return "", errors.Errorf("header %s not found", e.Header)
+		}
+	case e.Cookie != "":
+		for _, cookie := range resp.Cookies() {
+			if cookie.Name == e.Cookie {
+				value = cookie.Value
+			}
+		}
+		// cookie set by redirect response (ie: login form) is only stored in the jar
+		if value == "" && jar != nil {
+			for _, cookie := range jar.Cookies(resp.Request.URL) {
+				if cookie.Name == e.Cookie {
+					value = cookie.Value
+				}
+			}
+		}
+		if value == "" {
+			return "", errors.Errorf("cookie %s not found", e.Cookie)
+		}
+	case e.path != nil:
+		var document interface{}
+		if err := json.Unmarshal(body, &document); err != nil {
+			return "", errors.Wrap(err, "response is not valid json")
+		}
+		var err error
+		value, err = lookupJsonPath(document, e.path)
+		if err != nil {
+			return "", errors.Wrapf(err, "jsonPath %s", e.JsonPath)
+		}
+	default:
+		value = string(body)
+	}
+
+	if e.regex != nil {
+		match := e.regex.FindStringSubmatch(value)
+		if match == nil {
+			return "", errors.Errorf("regex %s does not match", e.Regex)
+		}
+		if len(match) > 1 {
+			return match[1], nil
+		}
+		return match[0], nil
+	}
+	return value, nil
+}
+
+// parse json path subset: $ root, .key and [index] or ["key"]
+func parseJsonPath(path string) ([]interface{}, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, errors.New("path must start with $")
+	}
+	var parsed []interface{}
+	rest := path[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end == -1 {
+				end = len(rest) - 1
+			}
+			name := rest[1 : end+1]
+			if name == "" {
+				return nil, errors.Errorf("empty key in %s", path)
+			}
+			parsed = append(parsed, name)
+			rest = rest[end+1:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end == -1 {
+				return nil, errors.Errorf("missing ] in %s", path)
+			}
+			selector := rest[1:end]
+			if unquoted, err := strconv.Unquote(selector); err == nil {
+				parsed = append(parsed, unquoted)
+			} else if index, err := strconv.Atoi(selector); err == nil {
+				parsed = append(parsed, index)
+			} else {
+				return nil, errors.Errorf("invalid selector [%s] in %s", selector, path)
+			}
+			rest = rest[end+1:]
+		default:
+			return nil, errors.Errorf("unexpected %q in %s", rest[0], path)
+		}
+	}
+	return parsed, nil
+}
+
+// walk the json document, scalar result is returned as string, objects and arrays as json
+func lookupJsonPath(document interface{}, path []interface{}) (string, error) {
+	current := document
+	for _, selector := range path {
+		switch s := selector.(type) {
+		case string:
+			object, ok := current.(map[string]interface{})
+			if !ok {
+				return "", errors.Errorf("key %s used on non-object", s)
+			}
+			if current, ok = object[s]; !ok {
+				return "", errors.Errorf("key %s not found", s)
+			}
+		case int:
+			array, ok := current.([]interface{})
+			if !ok {
+				return "", errors.Errorf("index %d used on non-array", s)
+			}
+			if s < 0 || s >= len(array) {
+				return "", errors.Errorf("index %d out of range", s)
+			}
+			current = array[s]
+		}
+	}
+
+	switch v := current.(type) {
+	case nil:
+		return "", errors.New("value is null")
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	default:
+		data, err := json.Marshal(v)
+		return string(data), err
+	}
+}
+
+// replace {{name}} with variable values, unknown variables are reported as error
+func expand(value string, variables map[string]string) (string, error) {
+	var missing []string
+	expanded := variablePattern.ReplaceAllStringFunc(value, func(match string) string {
+		name := variablePattern.FindStringSubmatch(match)[1]
+		if v, ok := variables[name]; ok {
+			return v
+		}
+		missing = append(missing, name)
+		return match
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("unknown variable %s", strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
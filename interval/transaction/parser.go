@@ -0,0 +1,107 @@
+package transaction
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/exmonitor/exclient/database"
+	"github.com/exmonitor/exclient/database/spec/service"
+	"github.com/exmonitor/exlogger"
+	"github.com/pkg/errors"
+
+	"github.com/exmonitor/watcher/interval/http"
+)
+
+/*
+Example metadata:
+{
+	"id": 15,
+	"port": 443,
+	"target": "shop.domain.cz",
+	"timeout": 5,
+	"proto": "https",
+	"ipFamily": "both",
+	"tlsCheckCertificates": true,
+	"tlsCertExpirationThreshold": 10,
+	"steps": [
+		{
+			"name": "login form",
+			"method": "GET",
+			"query": "login",
+			"contentCheckString": "Sign in",
+			"extract": [
+				{
+					"name": "csrf",
+					"regex": "name=\"csrf\" value=\"([^\"]+)\""
+				}
+			]
+		},
+		{
+			"name": "login",
+			"method": "POST",
+			"query": "login",
+			"postData": [
+				{"name": "csrf", "value": "{{csrf}}"},
+				{"name": "username", "value": "monitoring"},
+				{"name": "password", "value": "secret:shop-password"}
+			],
+			"allowedHttpStatusCodes": [200],
+			"extract": [
+				{
+					"name": "session",
+					"cookie": "SESSIONID"
+				}
+			]
+		},
+		{
+			"name": "api token",
+			"method": "POST",
+			"query": "api/token",
+			"body": "{\"scope\": \"orders\"}",
+			"extraHeaders": [
+				{"name": "Content-Type", "value": "application/json"}
+			],
+			"extract": [
+				{
+					"name": "token",
+					"jsonPath": "$.data.token"
+				}
+			]
+		},
+		{
+			"name": "orders",
+			"method": "GET",
+			"query": "api/orders?limit=1",
+			"extraHeaders": [
+				{"name": "Authorization", "value": "Bearer {{token}}"}
+			],
+			"contentCheckString": "\"orders\""
+		}
+	]
+}
+
+connection, shared header, auth, redirect and tls options are the same as for http check,
+{{name}} in query, body, post data and header values is replaced by extracted variable
+*/
+
+type RawCheck struct {
+	http.RawCheck
+	Steps []Step `json:"steps"`
+}
+
+func ParseCheck(service *service.Service, dbClient database.ClientInterface, logger *exlogger.Logger) (*Check, error) {
+	var rawCheck RawCheck
+	err := json.Unmarshal([]byte(service.Metadata), &rawCheck)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to parse TRANSACTION json metadata for check id %d", service.ID))
+	} else {
+		logger.LogDebug("Successfully parsed TRANSACTION json metadata for check id %d", service.ID)
+	}
+
+	checkConfig := CheckConfig{
+		HTTP:  rawCheck.RawCheck.ToConfig(service, dbClient, logger),
+		Steps: rawCheck.Steps,
+	}
+
+	return NewCheck(checkConfig)
+}
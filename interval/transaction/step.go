@@ -0,0 +1,160 @@
+package transaction
+
+import (
+	"fmt"
+	"io"
+	nethttp "net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/exmonitor/watcher/interval/http"
+	"github.com/exmonitor/watcher/interval/secret"
+)
+
+// max size of response body which is read for content check and extraction
+const maxBodySize = 10 << 20
+
+// single request of the transaction
+type Step struct {
+	Name                   string              `json:"name"`
+	Method                 string              `json:"method"`
+	Query                  string              `json:"query"`    // path and query of the url
+	PostData               []http.HTTPKeyValue `json:"postData"` // form values, can be secret references
+	Body                   string              `json:"body"`     // raw body, used instead of post data
+	ExtraHeaders           []http.HTTPKeyValue `json:"extraHeaders"`
+	AllowedHttpStatusCodes []int               `json:"allowedHttpStatusCodes"`
+	ContentCheckString     string              `json:"contentCheckString"`
+	Extract                []Extract           `json:"extract"`
+
+	// resolved secret references of post data indexed by position, they are never expanded
+	secrets map[int]string
+}
+
+// validate the step and prepare extracts, defined contains variables extracted by previous steps
+func (s *Step) init(index int, defined map[string]bool) error {
+	if s.Name == "" {
+		s.Name = fmt.Sprintf("step %d", index+1)
+	}
+	if s.Method == "" {
+		s.Method = nethttp.MethodGet
+	}
+	s.Method = strings.ToUpper(s.Method)
+	if s.Body != "" && len(s.PostData) > 0 {
+		return errors.Errorf("step %q cannot have both body and postData", s.Name)
+	}
+	if len(s.AllowedHttpStatusCodes) == 0 {
+		s.AllowedHttpStatusCodes = http.DefaultAllowedStatusCodes
+	}
+
+	// variables must be extracted before they are used
+	templates := []string{s.Query, s.Body}
+	for _, kv := range append(append([]http.HTTPKeyValue{}, s.PostData...), s.ExtraHeaders...) {
+		templates = append(templates, kv.Value)
+	}
+	for _, template := range templates {
+		for _, match := range variablePattern.FindAllStringSubmatch(template, -1) {
+			if !defined[match[1]] {
+				return errors.Errorf("step %q uses variable %s which is not extracted by any previous step", s.Name, match[1])
+			}
+		}
+	}
+
+	// secrets are resolved from configured values only, never from text expanded with response data
+	s.secrets = make(map[int]string)
+	for i, item := range s.PostData {
+		if !secret.IsReference(item.Value) || variablePattern.MatchString(item.Value) {
+			continue
+		}
+		value, err := secret.Value(item.Value)
+		if err != nil {
+			return errors.Wrapf(err, "step %q post data %s", s.Name, item.Name)
+		}
+		s.secrets[i] = value
+	}
+
+	for i := range s.Extract {
+		if err := s.Extract[i].init(); err != nil {
+			return errors.Wrapf(err, "step %q", s.Name)
+		}
+		defined[s.Extract[i].Name] = true
+	}
+	return nil
+}
+
+// create request with variables replaced by their values
+func (s *Step) request(baseUrl string, variables map[string]string) (*nethttp.Request, error) {
+	query, err := expand(s.Query, variables)
+	if err != nil {
+		return nil, err
+	}
+
+	var body io.Reader
+	contentType := ""
+	if len(s.PostData) > 0 {
+		form := url.Values{}
+		for i, item := range s.PostData {
+			// passwords for login forms dont have to be stored in metadata
+			if value, ok := s.secrets[i]; ok {
+				form.Add(item.Name, value)
+				continue
+			}
+			value, err := expand(item.Value, variables)
+			if err != nil {
+				return nil, err
+			}
+			form.Add(item.Name, value)
+		}
+		body = strings.NewReader(form.Encode())
+		contentType = "application/x-www-form-urlencoded"
+	} else if s.Body != "" {
+		expanded, err := expand(s.Body, variables)
+		if err != nil {
+			return nil, err
+		}
+		body = strings.NewReader(expanded)
+	}
+
+	req, err := nethttp.NewRequest(s.Method, baseUrl+query, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for _, header := range s.ExtraHeaders {
+		value, err := expand(header.Value, variables)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(header.Name, value)
+	}
+	return req, nil
+}
+
+// check response of the step and extract variables, returns reason of the failure
+func (s *Step) evaluate(resp *nethttp.Response, jar nethttp.CookieJar, variables map[string]string) (string, error) {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		return "failed to read response", err
+	}
+
+	if reason := http.StatusCodeFailure(resp.StatusCode, s.AllowedHttpStatusCodes); reason != "" {
+		return "bad http status code, " + reason, nil
+	}
+	if s.ContentCheckString != "" {
+		if reason := http.ContentFailure(body, s.ContentCheckString); reason != "" {
+			return reason, nil
+		}
+	}
+
+	for _, extract := range s.Extract {
+		value, err := extract.value(resp, jar, body)
+		if err != nil {
+			return fmt.Sprintf("failed to extract %s", extract.Name), err
+		}
+		variables[extract.Name] = value
+	}
+	return "", nil
+}
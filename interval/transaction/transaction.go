@@ -0,0 +1,225 @@
+package transaction
+
+import (
+	"fmt"
+	"net"
+	nethttp "net/http"
+	"net/http/cookiejar"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/exmonitor/exclient/database"
+	"github.com/exmonitor/exlogger"
+	"github.com/pkg/errors"
+
+	"github.com/exmonitor/watcher/interval/certs"
+	"github.com/exmonitor/watcher/interval/family"
+	"github.com/exmonitor/watcher/interval/http"
+	"github.com/exmonitor/watcher/interval/proxy"
+	"github.com/exmonitor/watcher/interval/spec"
+	"github.com/exmonitor/watcher/interval/status"
+	"github.com/exmonitor/watcher/key"
+)
+
+const (
+	msgSuccess                   = "success"
+	msgFailedStep                = "failed at step"
+	msgFailedCertExpired         = "failed - certificate expiration issue"
+	msgInternalFailedTLSMaterial = "INTERNAL: failed to load tls client certificate or CA bundle"
+)
+
+type CheckConfig struct {
+	// connection, shared header, auth, redirect and tls options shared with http check
+	HTTP  http.CheckConfig
+	Steps []Step
+}
+
+type Check struct {
+	id            int
+	failThreshold int
+	interval      int
+	requestId     string
+	target        string
+	port          int
+	proto         string
+	ipFamily      string
+	steps         []Step
+
+	tlsCheckCertificates       bool
+	tlsCertExpirationThreshold time.Duration
+
+	// shared http options (client, tls config, headers)
+	httpCheck *http.Check
+
+	// db client
+	dbClient database.ClientInterface
+	// logger
+	log *exlogger.Logger
+
+	// internals
+	spec.CheckInterface
+}
+
+func NewCheck(conf CheckConfig) (*Check, error) {
+	httpConf := conf.HTTP
+	if httpConf.Method != "" || httpConf.Query != "" || len(httpConf.PostData) > 0 || len(httpConf.AllowedHttpStatusCodes) > 0 || httpConf.ContentCheckEnabled {
+		return nil, errors.Wrap(invalidConfigError, "method, query, post data, status codes and content check are set per step")
+	}
+	if httpConf.FinalUrlCheckEnabled || httpConf.TlsAuditEnabled || httpConf.TlsRevocationCheckEnabled {
+		return nil, errors.Wrap(invalidConfigError, "final url, tls audit and revocation options are not supported by transaction check")
+	}
+	if httpConf.Proto != "http" && httpConf.Proto != "https" {
+		return nil, errors.Wrapf(invalidConfigError, "conf.Proto %q is not supported", httpConf.Proto)
+	}
+	if len(conf.Steps) == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.Steps must not be empty")
+	}
+	// method is required by http check validation, steps use their own
+	httpConf.Method = nethttp.MethodGet
+	httpCheck, err := http.New(httpConf)
+	if err != nil {
+		return nil, err
+	}
+
+	steps := make([]Step, len(conf.Steps))
+	copy(steps, conf.Steps)
+	defined := make(map[string]bool)
+	for i := range steps {
+		if err := steps[i].init(i, defined); err != nil {
+			return nil, errors.Wrap(invalidConfigError, err.Error())
+		}
+	}
+
+	newCheck := &Check{
+		id:            httpConf.Id,
+		failThreshold: httpConf.FailThreshold,
+		interval:      httpConf.Interval,
+		target:        httpConf.Target,
+		port:          httpConf.Port,
+		proto:         httpConf.Proto,
+		ipFamily:      httpConf.IpFamily,
+		steps:         steps,
+
+		tlsCheckCertificates:       httpConf.TlsCheckCertificates,
+		tlsCertExpirationThreshold: httpConf.TlsCertExpirationThreshold,
+
+		httpCheck: httpCheck,
+
+		dbClient: httpConf.DBClient,
+		log:      httpConf.Logger,
+	}
+
+	return newCheck, nil
+}
+
+// wrapper function used to run in separate thread (goroutine)
+func (c *Check) RunCheck() {
+	// generate unique request ID
+	c.requestId = key.GenerateReqId(c.id)
+	// run monitoring check
+	s := c.doCheck()
+	c.LogResult(s)
+
+	// save result to database
+	s.SaveToDB()
+}
+
+// run monitoring check, in dual-stack mode each address family is checked separately
+func (c *Check) doCheck() *status.Status {
+	return family.Run(c.ipFamily, c.doFamilyCheck)
+}
+
+// run all steps in order with shared cookie jar, stop on first failed step
+func (c *Check) doFamilyCheck(ipFamily string) *status.Status {
+	statusConfig := status.Config{
+		Id:            c.id,
+		ReqId:         c.requestId,
+		Interval:      c.interval,
+		FailThreshold: c.failThreshold,
+		DBClient:      c.dbClient,
+	}
+	s, err := status.New(statusConfig)
+	if err != nil {
+		c.LogRunError(err, fmt.Sprintf("failed to init new status for TRANSACTION service ID %d", c.id))
+	}
+	tStart := time.Now()
+	defer func() {
+		s.Duration = time.Since(tStart)
+	}()
+
+	tlsConfig, clientCert, err := c.httpCheck.TLSConfig()
+	if err != nil {
+		c.LogRunError(err, msgInternalFailedTLSMaterial)
+		s.Set(false, err, msgInternalFailedTLSMaterial)
+		return s
+	}
+	// expired client certificate would only cause confusing handshake error
+	if clientCert != nil && time.Now().After(clientCert.NotAfter) {
+		s.Set(false, nil, fmt.Sprintf("%s, client certificate %s expired on %s", msgFailedCertExpired, clientCert.Subject.CommonName, clientCert.NotAfter.Format(time.RFC3339)))
+		return s
+	}
+	client := c.httpCheck.Client(ipFamily, tlsConfig)
+	client.Jar, _ = cookiejar.New(nil)
+	defer client.CloseIdleConnections()
+
+	variables := make(map[string]string)
+	var timings []string
+	baseUrl := fmt.Sprintf("%s://%s/", c.proto, net.JoinHostPort(c.target, strconv.Itoa(c.port)))
+	for i := range c.steps {
+		step := &c.steps[i]
+		tStep := time.Now()
+		reason, err := c.runStep(client, step, baseUrl, variables)
+		timings = append(timings, fmt.Sprintf("%s %sms", step.Name, key.MsFromDuration(time.Since(tStep))))
+		if reason != "" {
+			s.Set(false, err, fmt.Sprintf("%s %d '%s' - %s, steps: %s", msgFailedStep, i+1, step.Name, reason, strings.Join(timings, ", ")))
+			return s
+		}
+	}
+
+	s.Set(true, nil, fmt.Sprintf("%s, steps: %s", msgSuccess, strings.Join(timings, ", ")))
+	return s
+}
+
+// execute single step, returns reason of the failure
+func (c *Check) runStep(client *nethttp.Client, step *Step, baseUrl string, variables map[string]string) (string, error) {
+	req, err := step.request(baseUrl, variables)
+	if err != nil {
+		return "failed to prepare request", err
+	}
+	// shared auth, Host header and headers, step headers take precedence
+	stepHeaders := req.Header.Clone()
+	c.httpCheck.PrepareRequest(req)
+	for name, values := range stepHeaders {
+		req.Header[name] = values
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		if proxy.IsProxyError(err) {
+			return "proxy error", err
+		}
+		return "failed to execute http request", err
+	}
+	defer resp.Body.Close()
+
+	if c.tlsCheckCertificates && resp.TLS != nil {
+		if certsOK, message := certs.CheckExpiration(resp.TLS, c.tlsCertExpirationThreshold); !certsOK {
+			return message, nil
+		}
+	}
+
+	return step.evaluate(resp, client.Jar, variables)
+}
+
+func (c *Check) GetStringPort() string {
+	return fmt.Sprintf(":%d", c.port)
+}
+
+func (c *Check) LogResult(s *status.Status) {
+	c.log.Log("check-TRANSACTION|id %d|reqID %s|target %s|proto %s|port %d|steps %d|family %s|latency %sms|result '%t'|msg: %s", c.id, c.requestId, c.target, c.proto, c.port, len(c.steps), c.ipFamily, key.MsFromDuration(s.Duration), s.Result, s.Message)
+}
+
+func (c *Check) LogRunError(err error, message string) {
+	c.log.LogError(err, "running check id:%d reqID:%s type:transaction target:%s:%d failed, reason: %s", c.id, c.requestId, c.target, c.port, message)
+}
@@ -0,0 +1,5 @@
+package udp
+
+import "errors"
+
+var invalidConfigError error = errors.New("invalid check config")
@@ -0,0 +1,70 @@
+package udp
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/exmonitor/exclient/database"
+	"github.com/exmonitor/exclient/database/spec/service"
+	"github.com/exmonitor/exlogger"
+	"github.com/pkg/errors"
+)
+
+/*
+Example metadata:
+{
+	"id": 10,
+	"target": "game.domain.cz",
+	"port": 27015,
+	"timeout": 5,
+	"payload": "",
+	"payloadHex": "ffffffff54536f7572636520456e67696e6520517565727900",
+	"expectResponse": true,
+	"responsePattern": "^ffffffff49",
+	"responseHex": true,
+	"ipFamily": "ipv4"
+}
+*/
+
+type RawCheck struct {
+	Id              int    `json:"id"`
+	Target          string `json:"target"`
+	Port            int    `json:"port"`
+	Timeout         int    `json:"timeout"`
+	Payload         string `json:"payload"`
+	PayloadHex      string `json:"payloadHex"`
+	ExpectResponse  bool   `json:"expectResponse"`
+	ResponsePattern string `json:"responsePattern"`
+	ResponseHex     bool   `json:"responseHex"`
+	IpFamily        string `json:"ipFamily"`
+}
+
+func ParseCheck(service *service.Service, dbClient database.ClientInterface, logger *exlogger.Logger) (*Check, error) {
+	var rawCheck RawCheck
+	err := json.Unmarshal([]byte(service.Metadata), &rawCheck)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to parse UDP json metadata for check id %d", service.ID))
+	} else {
+		logger.LogDebug("Successfully parsed UDP json metadata for check id %d", service.ID)
+	}
+
+	checkConfig := CheckConfig{
+		Id:              service.ID,
+		FailThreshold:   service.FailThreshold,
+		Interval:        service.Interval,
+		Target:          rawCheck.Target,
+		Port:            rawCheck.Port,
+		Timeout:         time.Second * time.Duration(rawCheck.Timeout),
+		Payload:         rawCheck.Payload,
+		PayloadHex:      rawCheck.PayloadHex,
+		ExpectResponse:  rawCheck.ExpectResponse,
+		ResponsePattern: rawCheck.ResponsePattern,
+		ResponseHex:     rawCheck.ResponseHex,
+		IpFamily:        rawCheck.IpFamily,
+		Logger:          logger,
+		DBClient:        dbClient,
+	}
+
+	return NewCheck(checkConfig)
+}
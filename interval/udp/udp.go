@@ -0,0 +1,263 @@
+package udp
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/exmonitor/exclient/database"
+	"github.com/exmonitor/exlogger"
+	"github.com/pkg/errors"
+
+	"github.com/exmonitor/watcher/interval/family"
+	"github.com/exmonitor/watcher/interval/spec"
+	"github.com/exmonitor/watcher/interval/status"
+	"github.com/exmonitor/watcher/key"
+)
+
+const (
+	msgSuccess               = "success"
+	msgSuccessNoResponse     = "success, no response and port is not reported as unreachable"
+	msgFailedToConnect       = "failed to open udp socket"
+	msgFailedToSend          = "failed to send payload"
+	msgFailedPortUnreachable = "failed - port unreachable"
+	msgFailedNoResponse      = "failed - no response within timeout"
+	msgFailedToRead          = "failed to read response"
+	msgFailedResponse        = "failed - response does not match pattern"
+
+	// max size of udp datagram
+	maxResponseSize = 65535
+	// max length of response in the message
+	maxResponseLog = 64
+)
+
+type CheckConfig struct {
+	Id            int
+	FailThreshold int
+	Interval      int
+	Target        string
+	Port          int
+	Timeout       time.Duration
+	IpFamily      string // ipv4, ipv6 or both, empty to let resolver decide
+
+	// probe options
+	Payload         string // payload as text
+	PayloadHex      string // payload as hex string, used instead of text payload
+	ExpectResponse  bool   // response is required even if pattern is not set
+	ResponsePattern string // regexp which must match the response
+	ResponseHex     bool   // match pattern against hex encoded response
+
+	//db client
+	DBClient database.ClientInterface
+	Logger   *exlogger.Logger
+}
+
+type Check struct {
+	id            int
+	failThreshold int
+	interval      int
+	requestId     string
+	target        string
+	port          int
+	timeout       time.Duration
+	ipFamily      string
+
+	payload         []byte
+	expectResponse  bool
+	responsePattern *regexp.Regexp
+	responseHex     bool
+
+	// db client
+	dbClient database.ClientInterface
+	// logger
+	log *exlogger.Logger
+
+	// internals
+	spec.CheckInterface
+}
+
+func NewCheck(conf CheckConfig) (*Check, error) {
+	if conf.Id == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.id must not be zero")
+	}
+	if conf.FailThreshold == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.FailThreshold must not be zero")
+	}
+	if conf.Interval == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.Interval must not be zero")
+	}
+	if conf.Target == "" {
+		return nil, errors.Wrap(invalidConfigError, "conf.Target must not be empty")
+	}
+	if conf.Port == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.Port must not be zero")
+	}
+	if conf.Timeout == 0 {
+		return nil, errors.Wrap(invalidConfigError, "conf.Timeout must not be zero")
+	}
+	if conf.DBClient == nil {
+		return nil, errors.Wrap(invalidConfigError, "conf.DBClient must not be nil")
+	}
+	if conf.Logger == nil {
+		return nil, errors.Wrap(invalidConfigError, "conf.Logger must not be nil")
+	}
+	if err := family.Validate(conf.IpFamily); err != nil {
+		return nil, errors.Wrap(invalidConfigError, err.Error())
+	}
+	if conf.Payload != "" && conf.PayloadHex != "" {
+		return nil, errors.Wrap(invalidConfigError, "conf.Payload and conf.PayloadHex cannot be set together")
+	}
+	payload := []byte(conf.Payload)
+	if conf.PayloadHex != "" {
+		var err error
+		payload, err = hex.DecodeString(conf.PayloadHex)
+		if err != nil {
+			return nil, errors.Wrapf(invalidConfigError, "conf.PayloadHex is not valid hex string: %s", err)
+		}
+	}
+	var responsePattern *regexp.Regexp
+	if conf.ResponsePattern != "" {
+		var err error
+		responsePattern, err = regexp.Compile(conf.ResponsePattern)
+		if err != nil {
+			return nil, errors.Wrapf(invalidConfigError, "conf.ResponsePattern is not valid regexp: %s", err)
+		}
+	}
+
+	newCheck := &Check{
+		id:            conf.Id,
+		failThreshold: conf.FailThreshold,
+		interval:      conf.Interval,
+		timeout:       conf.Timeout,
+		port:          conf.Port,
+		target:        conf.Target,
+		ipFamily:      conf.IpFamily,
+
+		payload:         payload,
+		expectResponse:  conf.ExpectResponse || responsePattern != nil,
+		responsePattern: responsePattern,
+		responseHex:     conf.ResponseHex,
+
+		dbClient: conf.DBClient,
+		log:      conf.Logger,
+	}
+
+	return newCheck, nil
+}
+
+// wrapper function used to run in separate thread (goroutine)
+func (c *Check) RunCheck() {
+	// generate unique request ID
+	c.requestId = key.GenerateReqId(c.id)
+	// run monitoring check
+	s := c.doCheck()
+	c.LogResult(s)
+
+	// save result to database
+	s.SaveToDB()
+}
+
+// run monitoring check, in dual-stack mode each address family is checked separately
+func (c *Check) doCheck() *status.Status {
+	return family.Run(c.ipFamily, c.doFamilyCheck)
+}
+
+func (c *Check) doFamilyCheck(ipFamily string) *status.Status {
+	statusConfig := status.Config{
+		Id:            c.id,
+		ReqId:         c.requestId,
+		Interval:      c.interval,
+		FailThreshold: c.failThreshold,
+		DBClient:      c.dbClient,
+	}
+	s, err := status.New(statusConfig)
+	if err != nil {
+		c.LogRunError(err, fmt.Sprintf("failed to init new status for UDP service ID %d", c.id))
+	}
+	tStart := time.Now()
+	defer func() {
+		s.Duration = time.Since(tStart)
+	}()
+
+	// connected socket, so the kernel reports ICMP port unreachable as ECONNREFUSED
+	conn, err := net.DialTimeout(family.Network("udp", ipFamily), net.JoinHostPort(c.target, strconv.Itoa(c.port)), c.timeout)
+	if err != nil {
+		s.Set(false, err, msgFailedToConnect)
+		return s
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(c.timeout))
+
+	if _, err := conn.Write(c.payload); err != nil {
+		if isPortUnreachable(err) {
+			s.Set(false, err, msgFailedPortUnreachable)
+		} else {
+			s.Set(false, err, msgFailedToSend)
+		}
+		return s
+	}
+
+	buf := make([]byte, maxResponseSize)
+	n, err := conn.Read(buf)
+	if err != nil {
+		if isPortUnreachable(err) {
+			s.Set(false, err, msgFailedPortUnreachable)
+		} else if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			// silence is all we can get from services which dont answer
+			if c.expectResponse {
+				s.Set(false, nil, msgFailedNoResponse)
+			} else {
+				s.Set(true, nil, msgSuccessNoResponse)
+			}
+		} else {
+			s.Set(false, err, msgFailedToRead)
+		}
+		return s
+	}
+
+	response := string(buf[:n])
+	if c.responseHex {
+		response = hex.EncodeToString(buf[:n])
+	}
+	if c.responsePattern != nil && !c.responsePattern.MatchString(response) {
+		s.Set(false, nil, fmt.Sprintf("%s %q, got %q", msgFailedResponse, c.responsePattern, truncate(response)))
+		return s
+	}
+
+	s.Set(true, nil, fmt.Sprintf("%s, received %d bytes", msgSuccess, n))
+	return s
+}
+
+func isPortUnreachable(err error) bool {
+	if opErr, ok := err.(*net.OpError); ok {
+		err = opErr.Err
+	}
+	if sysErr, ok := err.(*os.SyscallError); ok {
+		err = sysErr.Err
+	}
+	return err == syscall.ECONNREFUSED
+}
+
+func truncate(response string) string {
+	if len(response) > maxResponseLog {
+		return response[:maxResponseLog] + "..."
+	}
+	return response
+}
+
+func (c *Check) GetStringPort() string {
+	return fmt.Sprintf(":%d", c.port)
+}
+
+func (c *Check) LogResult(s *status.Status) {
+	c.log.Log("check-UDP|id %d|reqID %s|target %s|port %d|family %s|latency %sms|result '%t'|msg: %s", c.id, c.requestId, c.target, c.port, c.ipFamily, key.MsFromDuration(s.Duration), s.Result, s.Message)
+}
+
+func (c *Check) LogRunError(err error, message string) {
+	c.log.LogError(err, "running check id:%d reqID:%s type:udp target:%s:%d failed, reason: %s", c.id, c.requestId, c.target, c.port, message)
+}
@@ -0,0 +1,169 @@
+package websocket
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/pkg/errors"
+)
+
+// minimal client side of the websocket protocol (RFC 6455)
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+
+	closeNormal = 1000
+
+	acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	// bigger messages are not expected from a monitored endpoint
+	maxMessageSize = 1 << 20
+)
+
+// error returned when server closes the connection instead of sending a message
+type closeError struct {
+	code   int
+	reason string
+}
+
+func (e *closeError) Error() string {
+	if e.reason == "" {
+		return fmt.Sprintf("connection closed by server with code %d", e.code)
+	}
+	return fmt.Sprintf("connection closed by server with code %d: %s", e.code, e.reason)
+}
+
+func newKey() (string, error) {
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// expected value of Sec-WebSocket-Accept header
+func acceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// write single masked frame, all client frames must be masked
+func writeFrame(conn net.Conn, opcode byte, payload []byte) error {
+	frame := []byte{0x80 | opcode}
+	switch size := len(payload); {
+	case size <= 125:
+		frame = append(frame, 0x80|byte(size))
+	case size <= 0xffff:
+		frame = append(frame, 0x80|126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(size))
+	default:
+		frame = append(frame, 0x80|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(size))
+	}
+	mask := make([]byte, 4)
+	if _, err := rand.Read(mask); err != nil {
+		return err
+	}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	_, err := conn.Write(frame)
+	return err
+}
+
+// read next data message, control frames are handled on the way
+func readMessage(conn net.Conn, r io.Reader) ([]byte, error) {
+	var message []byte
+	for {
+		fin, opcode, payload, err := readFrame(r)
+		if err != nil {
+			return nil, err
+		}
+		switch opcode {
+		case opPing:
+			if err := writeFrame(conn, opPong, payload); err != nil {
+				return nil, err
+			}
+		case opPong:
+		case opClose:
+			e := &closeError{}
+			if len(payload) >= 2 {
+				e.code = int(binary.BigEndian.Uint16(payload))
+				e.reason = string(payload[2:])
+			}
+			return nil, e
+		case opText, opBinary, opContinuation:
+			message = append(message, payload...)
+			if len(message) > maxMessageSize {
+				return nil, errors.Errorf("message is bigger than %d bytes", maxMessageSize)
+			}
+			if fin {
+				return message, nil
+			}
+		default:
+			return nil, errors.Errorf("unknown opcode %d", opcode)
+		}
+	}
+}
+
+func readFrame(r io.Reader) (bool, byte, []byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return false, 0, nil, err
+	}
+	fin := header[0]&0x80 != 0
+	opcode := header[0] & 0x0f
+	masked := header[1]&0x80 != 0
+	size := uint64(header[1] & 0x7f)
+	switch size {
+	case 126:
+		ext := make([]byte, 2)
+		if _, err := io.ReadFull(r, ext); err != nil {
+			return false, 0, nil, err
+		}
+		size = uint64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		if _, err := io.ReadFull(r, ext); err != nil {
+			return false, 0, nil, err
+		}
+		size = binary.BigEndian.Uint64(ext)
+	}
+	if size > maxMessageSize {
+		return false, 0, nil, errors.Errorf("frame is bigger than %d bytes", maxMessageSize)
+	}
+	mask := make([]byte, 4)
+	if masked {
+		if _, err := io.ReadFull(r, mask); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, opcode, payload, nil
+}
+
+// payload of close frame with status code
+func closePayload(code int) []byte {
+	payload := make([]byte, 2)
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	return payload
+}
@@ -0,0 +1,100 @@
+package websocket
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestAcceptKey(t *testing.T) {
+	// example from RFC 6455 section 1.3
+	if got := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("acceptKey() = %q", got)
+	}
+}
+
+func TestReadFrame(t *testing.T) {
+	tests := []struct {
+		name        string
+		frame       []byte
+		wantFin     bool
+		wantOpcode  byte
+		wantPayload []byte
+		wantErr     bool
+	}{
+		{name: "unmasked text", frame: []byte{0x81, 5, 'h', 'e', 'l', 'l', 'o'}, wantFin: true, wantOpcode: opText, wantPayload: []byte("hello")},
+		// masked example from RFC 6455 section 5.7
+		{name: "masked text", frame: []byte{0x81, 0x85, 0x37, 0xfa, 0x21, 0x3d, 0x7f, 0x9f, 0x4d, 0x51, 0x58}, wantFin: true, wantOpcode: opText, wantPayload: []byte("Hello")},
+		{name: "fragment", frame: []byte{0x01, 3, 'h', 'e', 'l'}, wantOpcode: opText, wantPayload: []byte("hel")},
+		{name: "16 bit length", frame: append([]byte{0x82, 126, 0x01, 0x00}, make([]byte, 256)...), wantFin: true, wantOpcode: opBinary, wantPayload: make([]byte, 256)},
+		{name: "hostile 64 bit length", frame: []byte{0x82, 127, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, wantErr: true},
+		{name: "length above limit", frame: []byte{0x82, 127, 0, 0, 0, 0, 0, 0x10, 0, 1}, wantErr: true},
+		{name: "truncated payload", frame: []byte{0x81, 5, 'h', 'e'}, wantErr: true},
+		{name: "truncated header", frame: []byte{0x81}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fin, opcode, payload, err := readFrame(bytes.NewReader(tt.frame))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readFrame() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if fin != tt.wantFin || opcode != tt.wantOpcode || !bytes.Equal(payload, tt.wantPayload) {
+				t.Errorf("readFrame() = %t %d %q, want %t %d %q", fin, opcode, payload, tt.wantFin, tt.wantOpcode, tt.wantPayload)
+			}
+		})
+	}
+}
+
+func TestReadMessage(t *testing.T) {
+	tests := []struct {
+		name      string
+		frames    []byte
+		wantPongs int
+		want      string
+		wantErr   string
+	}{
+		{name: "single frame", frames: []byte{0x81, 2, 'o', 'k'}, want: "ok"},
+		{name: "fragmented", frames: []byte{0x01, 2, 'h', 'e', 0x00, 1, 'l', 0x80, 2, 'l', 'o'}, want: "hello"},
+		{name: "ping between fragments", frames: []byte{0x01, 2, 'h', 'e', 0x89, 1, 'p', 0x80, 3, 'l', 'l', 'o'}, wantPongs: 1, want: "hello"},
+		{name: "pong is ignored", frames: []byte{0x8a, 0, 0x81, 2, 'o', 'k'}, want: "ok"},
+		{name: "close with reason", frames: []byte{0x88, 6, 0x03, 0xe9, 'b', 'y', 'e', '!'}, wantErr: "connection closed by server with code 1001: bye!"},
+		{name: "close without code", frames: []byte{0x88, 0}, wantErr: "connection closed by server with code 0"},
+		{name: "unknown opcode", frames: []byte{0x83, 0}, wantErr: "unknown opcode 3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer client.Close()
+			pongs := make(chan int)
+			go func() {
+				n := 0
+				for {
+					_, opcode, _, err := readFrame(server)
+					if err != nil {
+						pongs <- n
+						return
+					}
+					if opcode == opPong {
+						n++
+					}
+				}
+			}()
+
+			message, err := readMessage(client, bytes.NewReader(tt.frames))
+			client.Close()
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("readMessage() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil || string(message) != tt.want {
+				t.Fatalf("readMessage() = %q, %v, want %q", message, err, tt.want)
+			}
+			if n := <-pongs; n != tt.wantPongs {
+				t.Errorf("sent %d pongs, want %d", n, tt.wantPongs)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/exmonitor/exclient/database"
+	"github.com/exmonitor/exclient/database/spec/service"
+	"github.com/exmonitor/exlogger"
+	"github.com/pkg/errors"
+
+	"github.com/exmonitor/watcher/interval/http"
+)
+
+/*
+Example metadata:
+{
+	"id": 14,
+	"port": 443,
+	"target": "realtime.domain.cz",
+	"timeout": 5,
+	"proto": "wss",
+	"query": "socket?channel=status",
+	"extraHeaders": [
+		{
+			"name": "Origin",
+			"value": "https://dashboard.domain.cz"
+		}
+	],
+	"authEnabled": true,
+	"authUsername": "admin",
+	"authPassword": "adminPass",
+	"subprotocols": ["v1.dashboard"],
+	"sendMessage": "{\"type\":\"ping\"}",
+	"expectMessage": true,
+	"responsePattern": "\"type\":\"pong\"",
+	"tlsSkipVerify": false,
+	"tlsCheckCertificates": true,
+	"tlsCertExpirationThreshold": 10
+}
+
+connection, header, auth and tls options are the same as for http check
+*/
+
+type RawCheck struct {
+	http.RawCheck
+	Subprotocols    []string `json:"subprotocols"`
+	SendMessage     string   `json:"sendMessage"`
+	ExpectMessage   bool     `json:"expectMessage"`
+	ResponsePattern string   `json:"responsePattern"`
+}
+
+func ParseCheck(service *service.Service, dbClient database.ClientInterface, logger *exlogger.Logger) (*Check, error) {
+	var rawCheck RawCheck
+	err := json.Unmarshal([]byte(service.Metadata), &rawCheck)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to parse WEBSOCKET json metadata for check id %d", service.ID))
+	} else {
+		logger.LogDebug("Successfully parsed WEBSOCKET json metadata for check id %d", service.ID)
+	}
+
+	checkConfig := CheckConfig{
+		HTTP:            rawCheck.RawCheck.ToConfig(service, dbClient, logger),
+		Subprotocols:    rawCheck.Subprotocols,
+		SendMessage:     rawCheck.SendMessage,
+		ExpectMessage:   rawCheck.ExpectMessage,
+		ResponsePattern: rawCheck.ResponsePattern,
+	}
+
+	return NewCheck(checkConfig)
+}
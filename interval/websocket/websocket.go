@@ -0,0 +1,328 @@
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	nethttp "net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/exmonitor/exclient/database"
+	"github.com/exmonitor/exlogger"
+	"github.com/pkg/errors"
+
+	"github.com/exmonitor/watcher/interval/certs"
+	"github.com/exmonitor/watcher/interval/family"
+	"github.com/exmonitor/watcher/interval/http"
+	"github.com/exmonitor/watcher/interval/proxy"
+	"github.com/exmonitor/watcher/interval/spec"
+	"github.com/exmonitor/watcher/interval/status"
+	"github.com/exmonitor/watcher/key"
+)
+
+const (
+	msgSuccess                   = "success"
+	msgFailedToConnect           = "failed to open connection"
+	msgFailedProxy               = "failed - proxy error"
+	msgFailedTLS                 = "failed - tls handshake"
+	msgFailedUpgrade             = "failed - websocket upgrade"
+	msgFailedSend                = "failed to send message"
+	msgFailedNoMessage           = "failed - no message received"
+	msgFailedResponse            = "failed - message does not match pattern"
+	msgFailedCertExpired         = "failed - certificate expiration issue"
+	msgInternalFailedTLSMaterial = "INTERNAL: failed to load tls client certificate or CA bundle"
+	msgInternalFailedRequest     = "INTERNAL: failed to prepare upgrade request"
+
+	// max length of received message in the result message
+	maxMessageLog = 128
+)
+
+type CheckConfig struct {
+	// connection, header, auth and tls options shared with http check
+	// proto is ws or wss, http and https are accepted as well
+	HTTP http.CheckConfig
+
+	// websocket options
+	Subprotocols    []string
+	SendMessage     string // text message sent after upgrade, nothing is sent if empty
+	ExpectMessage   bool   // first message must be received within timeout
+	ResponsePattern string // regexp which must match the first received message
+
+	// db client and logger are in HTTP config
+}
+
+type Check struct {
+	id            int
+	failThreshold int
+	interval      int
+	requestId     string
+	target        string
+	port          int
+	timeout       time.Duration
+	secure        bool
+	query         string
+	ipFamily      string
+
+	tlsCheckCertificates       bool
+	tlsCertExpirationThreshold time.Duration
+
+	subprotocols    []string
+	sendMessage     string
+	expectMessage   bool
+	responsePattern *regexp.Regexp
+
+	// shared http options (dialing, tls config, headers)
+	httpCheck *http.Check
+
+	// db client
+	dbClient database.ClientInterface
+	// logger
+	log *exlogger.Logger
+
+	// internals
+	spec.CheckInterface
+}
+
+func NewCheck(conf CheckConfig) (*Check, error) {
+	httpConf := conf.HTTP
+	switch httpConf.Proto {
+	case "ws", "http":
+		httpConf.Proto = "http"
+	case "wss", "https":
+		httpConf.Proto = "https"
+	default:
+		return nil, errors.Wrapf(invalidConfigError, "conf.Proto %q is not supported, use ws or wss", httpConf.Proto)
+	}
+	if httpConf.Method != "" && httpConf.Method != nethttp.MethodGet {
+		return nil, errors.Wrap(invalidConfigError, "conf.Method must be GET for websocket upgrade")
+	}
+	httpConf.Method = nethttp.MethodGet
+	if httpConf.ContentCheckEnabled || httpConf.FinalUrlCheckEnabled || httpConf.TlsAuditEnabled || httpConf.TlsRevocationCheckEnabled || len(httpConf.PostData) > 0 {
+		return nil, errors.Wrap(invalidConfigError, "content, final url, post data, tls audit and revocation options are not supported by websocket check")
+	}
+	// validates all shared options
+	httpCheck, err := http.New(httpConf)
+	if err != nil {
+		return nil, err
+	}
+
+	var responsePattern *regexp.Regexp
+	if conf.ResponsePattern != "" {
+		responsePattern, err = regexp.Compile(conf.ResponsePattern)
+		if err != nil {
+			return nil, errors.Wrapf(invalidConfigError, "conf.ResponsePattern is not valid regexp: %s", err)
+		}
+	}
+
+	newCheck := &Check{
+		id:            httpConf.Id,
+		failThreshold: httpConf.FailThreshold,
+		interval:      httpConf.Interval,
+		target:        httpConf.Target,
+		port:          httpConf.Port,
+		timeout:       httpConf.Timeout,
+		secure:        httpConf.Proto == "https",
+		query:         httpConf.Query,
+		ipFamily:      httpConf.IpFamily,
+
+		tlsCheckCertificates:       httpConf.TlsCheckCertificates,
+		tlsCertExpirationThreshold: httpConf.TlsCertExpirationThreshold,
+
+		subprotocols:    conf.Subprotocols,
+		sendMessage:     conf.SendMessage,
+		expectMessage:   conf.ExpectMessage || responsePattern != nil,
+		responsePattern: responsePattern,
+
+		httpCheck: httpCheck,
+
+		dbClient: httpConf.DBClient,
+		log:      httpConf.Logger,
+	}
+
+	return newCheck, nil
+}
+
+// wrapper function used to run in separate thread (goroutine)
+func (c *Check) RunCheck() {
+	// generate unique request ID
+	c.requestId = key.GenerateReqId(c.id)
+	// run monitoring check
+	s := c.doCheck()
+	c.LogResult(s)
+
+	// save result to database
+	s.SaveToDB()
+}
+
+// run monitoring check, in dual-stack mode each address family is checked separately
+func (c *Check) doCheck() *status.Status {
+	return family.Run(c.ipFamily, c.doFamilyCheck)
+}
+
+func (c *Check) doFamilyCheck(ipFamily string) *status.Status {
+	statusConfig := status.Config{
+		Id:            c.id,
+		ReqId:         c.requestId,
+		Interval:      c.interval,
+		FailThreshold: c.failThreshold,
+		DBClient:      c.dbClient,
+	}
+	s, err := status.New(statusConfig)
+	if err != nil {
+		c.LogRunError(err, fmt.Sprintf("failed to init new status for WEBSOCKET service ID %d", c.id))
+	}
+	tStart := time.Now()
+	defer func() {
+		s.Duration = time.Since(tStart)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	// connect
+	conn, err := c.httpCheck.DialContext(ctx, family.Network("tcp", ipFamily), net.JoinHostPort(c.target, strconv.Itoa(c.port)))
+	if err != nil {
+		if proxy.IsProxyError(err) {
+			s.Set(false, err, msgFailedProxy)
+		} else {
+			s.Set(false, err, msgFailedToConnect)
+		}
+		return s
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(c.timeout))
+
+	if c.secure {
+		tlsConfig, clientCert, err := c.httpCheck.TLSConfig()
+		if err != nil {
+			c.LogRunError(err, msgInternalFailedTLSMaterial)
+			s.Set(false, err, msgInternalFailedTLSMaterial)
+			return s
+		}
+		tlsConfig.ServerName = c.httpCheck.ServerName()
+		// upgrade is defined only for HTTP/1.1
+		tlsConfig.NextProtos = []string{"http/1.1"}
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			s.Set(false, err, msgFailedTLS)
+			return s
+		}
+		conn = tlsConn
+
+		if c.tlsCheckCertificates {
+			state := tlsConn.ConnectionState()
+			certsOK, message := certs.CheckExpiration(&state, c.tlsCertExpirationThreshold)
+			if !certsOK {
+				s.Set(false, nil, message)
+				return s
+			}
+			if clientCert != nil && time.Now().Add(c.tlsCertExpirationThreshold).After(clientCert.NotAfter) {
+				s.Set(false, nil, fmt.Sprintf("%s, client certificate %s will expire in less than %.0f hours", msgFailedCertExpired, clientCert.Subject.CommonName, c.tlsCertExpirationThreshold.Hours()))
+				return s
+			}
+		}
+	}
+
+	// upgrade
+	wsKey, err := newKey()
+	if err != nil {
+		c.LogRunError(err, msgInternalFailedRequest)
+		s.Set(false, err, msgInternalFailedRequest)
+		return s
+	}
+	req, err := nethttp.NewRequest(nethttp.MethodGet, c.url(), nil)
+	if err != nil {
+		c.LogRunError(err, msgInternalFailedRequest)
+		s.Set(false, err, msgInternalFailedRequest)
+		return s
+	}
+	c.httpCheck.PrepareRequest(req)
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", wsKey)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if len(c.subprotocols) > 0 {
+		req.Header.Set("Sec-WebSocket-Protocol", strings.Join(c.subprotocols, ", "))
+	}
+	if err := req.Write(conn); err != nil {
+		s.Set(false, err, msgFailedUpgrade)
+		return s
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := nethttp.ReadResponse(reader, req)
+	if err != nil {
+		s.Set(false, err, msgFailedUpgrade)
+		return s
+	}
+	resp.Body.Close()
+	if resp.StatusCode != nethttp.StatusSwitchingProtocols {
+		s.Set(false, nil, fmt.Sprintf("%s, server responded with HTTP code %d", msgFailedUpgrade, resp.StatusCode))
+		return s
+	}
+	if !strings.EqualFold(resp.Header.Get("Upgrade"), "websocket") || resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(wsKey) {
+		s.Set(false, nil, fmt.Sprintf("%s, invalid Upgrade or Sec-WebSocket-Accept header in response", msgFailedUpgrade))
+		return s
+	}
+	msg := msgSuccess
+	if protocol := resp.Header.Get("Sec-WebSocket-Protocol"); protocol != "" {
+		msg = fmt.Sprintf("%s, subprotocol %s", msg, protocol)
+	}
+	// say goodbye properly, servers often log abnormal closures
+	defer writeFrame(conn, opClose, closePayload(closeNormal))
+
+	// message exchange
+	if c.sendMessage != "" {
+		if err := writeFrame(conn, opText, []byte(c.sendMessage)); err != nil {
+			s.Set(false, err, msgFailedSend)
+			return s
+		}
+	}
+	if c.expectMessage {
+		message, err := readMessage(conn, reader)
+		if err != nil {
+			s.Set(false, err, msgFailedNoMessage)
+			return s
+		}
+		if c.responsePattern != nil && !c.responsePattern.Match(message) {
+			s.Set(false, nil, fmt.Sprintf("%s %q, got %q", msgFailedResponse, c.responsePattern, truncate(message)))
+			return s
+		}
+		msg = fmt.Sprintf("%s, received %d bytes", msg, len(message))
+	}
+
+	s.Set(true, nil, msg)
+	return s
+}
+
+// upgrade request url, http scheme is used as the request is sent by hand
+func (c *Check) url() string {
+	scheme := "http"
+	if c.secure {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s/%s", scheme, net.JoinHostPort(c.target, strconv.Itoa(c.port)), c.query)
+}
+
+func truncate(message []byte) string {
+	if len(message) > maxMessageLog {
+		return string(message[:maxMessageLog]) + "..."
+	}
+	return string(message)
+}
+
+func (c *Check) GetStringPort() string {
+	return fmt.Sprintf(":%d", c.port)
+}
+
+func (c *Check) LogResult(s *status.Status) {
+	c.log.Log("check-WEBSOCKET|id %d|reqID %s|target %s|port %d|secure %t|family %s|latency %sms|result '%t'|msg: %s", c.id, c.requestId, c.target, c.port, c.secure, c.ipFamily, key.MsFromDuration(s.Duration), s.Result, s.Message)
+}
+
+func (c *Check) LogRunError(err error, message string) {
+	c.log.LogError(err, "running check id:%d reqID:%s type:websocket target:%s:%d failed, reason: %s", c.id, c.requestId, c.target, c.port, message)
+}
@@ -8,9 +8,28 @@ import (
 
 const (
 	// same as in table `service_type`
-	ServiceTypeHttp = 1
-	ServiceTypeTcp  = 2
-	ServiceTypeIcmp = 3
+	ServiceTypeHttp        = 1
+	ServiceTypeTcp         = 2
+	ServiceTypeIcmp        = 3
+	ServiceTypeTls         = 4
+	ServiceTypeSmtp        = 5
+	ServiceTypeEmail       = 6
+	ServiceTypeMysql       = 7
+	ServiceTypePostgres    = 8
+	ServiceTypeRedis       = 9
+	ServiceTypeUdp         = 10
+	ServiceTypeNtp         = 11
+	ServiceTypeSsh         = 12
+	ServiceTypeGrpc        = 13
+	ServiceTypeWebsocket   = 14
+	ServiceTypeTransaction = 15
+	ServiceTypeScript      = 16
+	ServiceTypeCommand     = 17
+	ServiceTypeHeartbeat   = 18
+	ServiceTypeDomain      = 19
+	ServiceTypeDnsbl       = 20
+	ServiceTypeDns         = 21
+	ServiceTypeMtr         = 22
 )
 
 func MsFromDuration(d time.Duration) string {